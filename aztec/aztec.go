@@ -0,0 +1,482 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package aztec encodes Aztec codes.
+
+The encoder writes upper case letters, lower case letters, digits,
+and spaces in the corresponding character modes and everything else
+with binary shifts.  It picks the smallest compact or full-range
+symbol that leaves room for check bits amounting to a third of the
+data bits, plus 11 more, which is about the 23% of the symbol plus
+three codewords that ISO/IEC 24778 recommends.
+*/
+package aztec
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+
+	"code.google.com/p/rsc/barcode"
+)
+
+// A Code is an Aztec symbol: a square grid of modules.
+// Like qr.Code, it implements image.Image and PNG encoding.
+// Aztec codes need no quiet zone, so the images have none.
+type Code struct {
+	Bitmap  []byte // 1 is black, 0 is white
+	Size    int    // number of modules on a side
+	Stride  int    // number of bytes per row
+	Scale   int    // number of image pixels per module
+	Compact bool   // compact symbol (1 to 4 layers)
+	Layers  int    // number of data layers
+}
+
+// Black returns true if the module at (x,y) is black.
+func (c *Code) Black(x, y int) bool {
+	return 0 <= x && x < c.Size && 0 <= y && y < c.Size &&
+		c.Bitmap[y*c.Stride+x/8]&(1<<uint(7-x&7)) != 0
+}
+
+// Bounds returns the extent of the code in modules.
+// Together with Black and Metadata, it implements barcode.Barcode.
+func (c *Code) Bounds() image.Rectangle {
+	return image.Rect(0, 0, c.Size, c.Size)
+}
+
+var _ barcode.Barcode = (*Code)(nil)
+
+// Metadata describes the code for package barcode.
+func (c *Code) Metadata() barcode.Metadata {
+	return barcode.Metadata{Symbology: "Aztec"}
+}
+
+func (c *Code) set(x, y int) {
+	c.Bitmap[y*c.Stride+x/8] |= 1 << uint(7-x&7)
+}
+
+// Image returns an image of the code.
+func (c *Code) Image() image.Image {
+	return codeImage{c}
+}
+
+type codeImage struct {
+	*Code
+}
+
+func (c codeImage) Bounds() image.Rectangle {
+	d := c.Size * c.scale()
+	return image.Rect(0, 0, d, d)
+}
+
+func (c codeImage) At(x, y int) color.Color {
+	s := c.scale()
+	if c.Black(x/s, y/s) {
+		return color.Gray{0x00}
+	}
+	return color.Gray{0xFF}
+}
+
+func (c codeImage) ColorModel() color.Model {
+	return color.GrayModel
+}
+
+func (c *Code) scale() int {
+	if c.Scale <= 0 {
+		return 1
+	}
+	return c.Scale
+}
+
+// PNG returns a PNG image displaying the code.
+func (c *Code) PNG() []byte {
+	var b bytes.Buffer
+	if err := png.Encode(&b, c.Image()); err != nil {
+		panic("aztec: " + err.Error())
+	}
+	return b.Bytes()
+}
+
+// minCheck is the minimum number of check bits,
+// as a percentage of the data bits.
+const minCheck = 33
+
+// Encode returns an encoding of text in the smallest symbol that holds it.
+// The text must not be empty: the mode message cannot describe a symbol
+// with no data words.
+func Encode(text string) (*Code, error) {
+	if text == "" {
+		return nil, errors.New("aztec: cannot encode empty text")
+	}
+	var b bits
+	encodeText(&b, text)
+
+	check := b.n*minCheck/100 + 11
+	var stuffed bits
+	var layers, wordSize int
+	var compact bool
+	for i := 0; ; i++ {
+		if i > 32 {
+			return nil, errors.New("aztec: text too long to encode")
+		}
+		// Full-range symbols with 1 to 3 layers are
+		// never smaller than the compact equivalents.
+		compact = i < 4
+		layers = i
+		if compact {
+			layers = i + 1
+		}
+		total := layerBits(layers, compact)
+		if b.n+check > total {
+			continue
+		}
+		if ws := wordSizes[layers]; ws != wordSize {
+			wordSize = ws
+			stuffed = stuff(&b, wordSize)
+		}
+		if compact && stuffed.n > 64*wordSize {
+			continue // mode message has 6 bits for the word count
+		}
+		if stuffed.n+check <= total-total%wordSize {
+			break
+		}
+	}
+
+	total := layerBits(layers, compact)
+	words := stuffed.n / wordSize
+	data := addCheck(&stuffed, total, wordSize)
+
+	var mode bits
+	if compact {
+		mode.write(layers-1, 2)
+		mode.write(words-1, 6)
+		mode = *addCheck(&mode, 28, 4)
+	} else {
+		mode.write(layers-1, 5)
+		mode.write(words-1, 11)
+		mode = *addCheck(&mode, 40, 4)
+	}
+	return layout(data, &mode, layers, compact), nil
+}
+
+// wordSizes gives the codeword size in bits for each number of layers.
+var wordSizes = [33]int{
+	4, 6, 6, 8, 8, 8, 8, 8, 8, 10, 10, 10, 10, 10, 10, 10, 10,
+	10, 10, 10, 10, 10, 10, 12, 12, 12, 12, 12, 12, 12, 12, 12, 12,
+}
+
+// layerBits returns the number of bits in the given number of data layers.
+func layerBits(layers int, compact bool) int {
+	base := 112
+	if compact {
+		base = 88
+	}
+	return (base + 16*layers) * layers
+}
+
+// A bits is a bit string, most significant bit first.
+type bits struct {
+	b []byte
+	n int
+}
+
+func (b *bits) write(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if b.n%8 == 0 {
+			b.b = append(b.b, 0)
+		}
+		if v&(1<<uint(i)) != 0 {
+			b.b[b.n/8] |= 1 << uint(7-b.n%8)
+		}
+		b.n++
+	}
+}
+
+func (b *bits) get(i int) bool {
+	return i < b.n && b.b[i/8]&(1<<uint(7-i%8)) != 0
+}
+
+// Character modes and their codes.
+const (
+	upper = iota
+	lower
+	digit
+)
+
+const (
+	latchLower       = 28 // from upper
+	latchDigit       = 30 // from upper or lower
+	latchUpper       = 14 // from digit
+	shiftUpper       = 28 // from lower
+	shiftBinary      = 31 // from upper or lower
+	maxBinary        = 31 + 2047
+	upperLowerSpace  = 1
+	digitSpace       = 1
+	digitComma       = 12
+	digitPeriod      = 13
+	upperLowerLetter = 2 // code for A or a
+	digitZero        = 2
+)
+
+// class returns the mode that holds c, or -1 if none does.
+// Space is in every mode.
+func class(c byte) int {
+	switch {
+	case 'A' <= c && c <= 'Z':
+		return upper
+	case 'a' <= c && c <= 'z':
+		return lower
+	case '0' <= c && c <= '9', c == ',', c == '.':
+		return digit
+	}
+	return -1
+}
+
+// encodeText appends the encoding of text to b.
+func encodeText(b *bits, text string) {
+	mode := upper
+	for i := 0; i < len(text); {
+		c := text[i]
+		if c == ' ' {
+			if mode == digit {
+				b.write(digitSpace, 4)
+			} else {
+				b.write(upperLowerSpace, 5)
+			}
+			i++
+			continue
+		}
+		switch cl := class(c); {
+		case cl < 0:
+			// Binary shift a run of unencodable bytes.
+			j := i
+			for j < len(text) && j-i < maxBinary && class(text[j]) < 0 && text[j] != ' ' {
+				j++
+			}
+			if mode == digit {
+				b.write(latchUpper, 4)
+				mode = upper
+			}
+			b.write(shiftBinary, 5)
+			if n := j - i; n <= 31 {
+				b.write(n, 5)
+			} else {
+				b.write(0, 5)
+				b.write(n-31, 11)
+			}
+			for ; i < j; i++ {
+				b.write(int(text[i]), 8)
+			}
+			continue
+		case cl == mode:
+		case cl == upper && mode == lower && (i+1 == len(text) || class(text[i+1]) != upper):
+			b.write(shiftUpper, 5)
+			b.write(upperLowerLetter+int(c-'A'), 5)
+			i++
+			continue
+		case cl == upper && mode == lower:
+			b.write(latchDigit, 5)
+			b.write(latchUpper, 4)
+		case cl == upper && mode == digit:
+			b.write(latchUpper, 4)
+		case cl == lower && mode == digit:
+			b.write(latchUpper, 4)
+			b.write(latchLower, 5)
+		case cl == lower:
+			b.write(latchLower, 5)
+		case cl == digit:
+			b.write(latchDigit, 5)
+		}
+		mode = class(c)
+		switch {
+		case mode == upper:
+			b.write(upperLowerLetter+int(c-'A'), 5)
+		case mode == lower:
+			b.write(upperLowerLetter+int(c-'a'), 5)
+		case c == ',':
+			b.write(digitComma, 4)
+		case c == '.':
+			b.write(digitPeriod, 4)
+		default:
+			b.write(digitZero+int(c-'0'), 4)
+		}
+		i++
+	}
+}
+
+// stuff splits b into words of n bits, padding the last with ones.
+// A word whose first n-1 bits are all zeros or all ones is reserved,
+// so stuff follows such n-1 bits with a complemented bit instead,
+// carrying the displaced bit into the next word.
+func stuff(b *bits, n int) bits {
+	var out bits
+	mask := 1<<uint(n) - 2
+	for i := 0; i < b.n; i += n {
+		w := 0
+		for j := 0; j < n; j++ {
+			if i+j >= b.n || b.get(i+j) {
+				w |= 1 << uint(n-1-j)
+			}
+		}
+		switch w & mask {
+		case mask:
+			out.write(w&mask, n)
+			i--
+		case 0:
+			out.write(w|1, n)
+			i--
+		default:
+			out.write(w, n)
+		}
+	}
+	return out
+}
+
+// addCheck returns the total-bit message holding the n-bit words of b
+// followed by check words, preceded by zero bits to fill total exactly.
+func addCheck(b *bits, total, n int) *bits {
+	f := fields[n]
+	nw := b.n / n
+	words := make([]int, total/n)
+	for i := 0; i < nw; i++ {
+		for j := 0; j < n; j++ {
+			if b.get(i*n + j) {
+				words[i] |= 1 << uint(n-1-j)
+			}
+		}
+	}
+	copy(words[nw:], f.ecc(words[:nw], len(words)-nw))
+	out := new(bits)
+	out.write(0, total%n)
+	for _, w := range words {
+		out.write(w, n)
+	}
+	return out
+}
+
+// layout draws the symbol.
+func layout(data, mode *bits, layers int, compact bool) *Code {
+	base := 14 + 4*layers
+	if compact {
+		base = 11 + 4*layers
+	}
+	// Full-range symbols have a reference grid line every 16 modules
+	// from the center; align maps a position in the symbol without
+	// them to the position in the symbol with them.
+	align := make([]int, base)
+	size := base
+	if compact {
+		for i := range align {
+			align[i] = i
+		}
+	} else {
+		size = base + 1 + 2*((base/2-1)/15)
+		oc, c := base/2, size/2
+		for i := 0; i < oc; i++ {
+			off := i + i/15
+			align[oc-i-1] = c - off - 1
+			align[oc+i] = c + off + 1
+		}
+	}
+	c := &Code{Size: size, Stride: (size + 7) / 8, Compact: compact, Layers: layers}
+	c.Bitmap = make([]byte, c.Stride*c.Size)
+
+	// Data layers spiral inward counterclockwise from the outside,
+	// each side a band two modules thick.
+	off := 0
+	for i := 0; i < layers; i++ {
+		n := (layers-i)*4 + 9
+		if !compact {
+			n += 3
+		}
+		for j := 0; j < n; j++ {
+			col := j * 2
+			for k := 0; k < 2; k++ {
+				if data.get(off + col + k) {
+					c.set(align[i*2+k], align[i*2+j])
+				}
+				if data.get(off + n*2 + col + k) {
+					c.set(align[i*2+j], align[base-1-i*2-k])
+				}
+				if data.get(off + n*4 + col + k) {
+					c.set(align[base-1-i*2-k], align[base-1-i*2-j])
+				}
+				if data.get(off + n*6 + col + k) {
+					c.set(align[base-1-i*2-j], align[i*2+k])
+				}
+			}
+		}
+		off += n * 8
+	}
+
+	center := size / 2
+	if compact {
+		for i := 0; i < 7; i++ {
+			o := center - 3 + i
+			if mode.get(i) {
+				c.set(o, center-5)
+			}
+			if mode.get(i + 7) {
+				c.set(center+5, o)
+			}
+			if mode.get(20 - i) {
+				c.set(o, center+5)
+			}
+			if mode.get(27 - i) {
+				c.set(center-5, o)
+			}
+		}
+		c.bullseye(center, 5)
+	} else {
+		for i := 0; i < 10; i++ {
+			o := center - 5 + i + i/5
+			if mode.get(i) {
+				c.set(o, center-7)
+			}
+			if mode.get(i + 10) {
+				c.set(center+7, o)
+			}
+			if mode.get(29 - i) {
+				c.set(o, center+7)
+			}
+			if mode.get(39 - i) {
+				c.set(center-7, o)
+			}
+		}
+		c.bullseye(center, 7)
+
+		// Reference grid.
+		for i, j := 0, 0; i < base/2-1; i, j = i+15, j+16 {
+			for k := center & 1; k < size; k += 2 {
+				c.set(center-j, k)
+				c.set(center+j, k)
+				c.set(k, center-j)
+				c.set(k, center+j)
+			}
+		}
+	}
+	return c
+}
+
+// bullseye draws the finder pattern of the given radius
+// and the orientation marks at its corners.
+func (c *Code) bullseye(center, r int) {
+	for i := 0; i < r; i += 2 {
+		for j := center - i; j <= center+i; j++ {
+			c.set(j, center-i)
+			c.set(j, center+i)
+			c.set(center-i, j)
+			c.set(center+i, j)
+		}
+	}
+	c.set(center-r, center-r)
+	c.set(center-r+1, center-r)
+	c.set(center-r, center-r+1)
+	c.set(center+r, center-r)
+	c.set(center+r, center-r+1)
+	c.set(center+r, center+r-1)
+}
@@ -0,0 +1,157 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package aztec
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestField(t *testing.T) {
+	for n, f := range fields {
+		seen := make(map[int]bool)
+		for i := 0; i < f.size-1; i++ {
+			x := f.exp[i]
+			if x <= 0 || x >= f.size || seen[x] || f.log[x] != i {
+				t.Fatalf("GF(2^%d): bad exp/log at %d", n, i)
+			}
+			seen[x] = true
+		}
+	}
+}
+
+func TestECC(t *testing.T) {
+	// Check words make the codeword polynomial vanish at α¹…αⁿ.
+	for n, f := range fields {
+		data := []int{1, 2, 3, f.size - 1, 0, 5}
+		const nc = 7
+		cw := append(data, f.ecc(data, nc)...)
+		for i := 1; i <= nc; i++ {
+			a := f.exp[i]
+			s := 0
+			for _, c := range cw {
+				s = f.mul(s, a) ^ c
+			}
+			if s != 0 {
+				t.Errorf("GF(2^%d): syndrome %d = %d", n, i, s)
+			}
+		}
+	}
+}
+
+var stuffTests = []struct {
+	in, out string
+}{
+	{"101010", "101010"},
+	{"000000", "000001" + "011111"},
+	{"111110", "111110" + "011111"},
+	{"11111", "111110"},
+}
+
+func TestStuff(t *testing.T) {
+	for _, tt := range stuffTests {
+		var b bits
+		for _, c := range tt.in {
+			b.write(int(c-'0'), 1)
+		}
+		out := stuff(&b, 6)
+		var s []byte
+		for i := 0; i < out.n; i++ {
+			s = append(s, '0')
+			if out.get(i) {
+				s[i] = '1'
+			}
+		}
+		if string(s) != tt.out {
+			t.Errorf("stuff(%s) = %s, want %s", tt.in, s, tt.out)
+		}
+	}
+}
+
+var textTests = []struct {
+	text string
+	want string // bits
+}{
+	{"A", "00010"},
+	{"a", "11100" + "00010"},
+	{"aB", "11100" + "00010" + "11100" + "00011"},
+	{"12", "11110" + "0011" + "0100"},
+	{"1a", "11110" + "0011" + "1110" + "11100" + "00010"},
+	{"A!", "00010" + "11111" + "00001" + "00100001"},
+}
+
+func TestEncodeText(t *testing.T) {
+	for _, tt := range textTests {
+		var b bits
+		encodeText(&b, tt.text)
+		var s []byte
+		for i := 0; i < b.n; i++ {
+			if b.get(i) {
+				s = append(s, '1')
+			} else {
+				s = append(s, '0')
+			}
+		}
+		if string(s) != tt.want {
+			t.Errorf("encodeText(%q) = %s, want %s", tt.text, s, tt.want)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	for _, tt := range []struct {
+		text    string
+		compact bool
+		size    int
+	}{
+		{"Code 2D!", true, 19},
+		{"This is an example Aztec symbol for Wikipedia.", true, 23},
+		{strings.Repeat("Aztec ", 40), false, 49},
+	} {
+		c, err := Encode(tt.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Compact != tt.compact || c.Size != tt.size {
+			t.Errorf("%.20q: compact=%v size %d, want %v, %d", tt.text, c.Compact, c.Size, tt.compact, tt.size)
+			continue
+		}
+		// Bull's-eye: alternating square rings around the center.
+		r := 5
+		if !c.Compact {
+			r = 7
+		}
+		m := c.Size / 2
+		for d := 0; d < r; d++ {
+			for _, p := range [][2]int{{m - d, m}, {m + d, m}, {m, m - d}, {m, m + d}, {m - d, m - d}, {m + d, m + d}} {
+				if c.Black(p[0], p[1]) != (d%2 == 0) {
+					t.Errorf("%.20q: bull's-eye wrong at %v", tt.text, p)
+				}
+			}
+		}
+	}
+
+	c, err := Encode("hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Scale = 2
+	m, err := png.Decode(bytes.NewReader(c.PNG()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := m.Bounds(); b.Dx() != 2*c.Size {
+		t.Errorf("PNG bounds %v, want %d wide", b, 2*c.Size)
+	}
+
+	if _, err := Encode(string(make([]byte, 4000))); err == nil {
+		t.Errorf("encoding 4000 bytes succeeded")
+	}
+	if c, err := Encode(""); err == nil {
+		t.Errorf("encoding empty text succeeded: %d layers", c.Layers)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package aztec
+
+// A field is the Galois field GF(2ⁿ) for an Aztec codeword size n.
+// Package gf256 handles only 8-bit codewords, so Aztec, which uses
+// 4-, 6-, 8-, 10-, and 12-bit codewords, has its own tables.
+type field struct {
+	size int // 2ⁿ
+	exp  []int
+	log  []int
+}
+
+// fields maps each codeword size to its field, generated by 2.
+var fields = map[int]*field{
+	4:  newField(0x13, 4),
+	6:  newField(0x43, 6),
+	8:  newField(0x12d, 8),
+	10: newField(0x409, 10),
+	12: newField(0x1069, 12),
+}
+
+func newField(poly, n int) *field {
+	f := &field{size: 1 << uint(n)}
+	f.exp = make([]int, 2*f.size)
+	f.log = make([]int, f.size)
+	x := 1
+	for i := 0; i < f.size-1; i++ {
+		f.exp[i] = x
+		f.exp[i+f.size-1] = x
+		f.log[x] = i
+		x <<= 1
+		if x&f.size != 0 {
+			x ^= poly
+		}
+	}
+	return f
+}
+
+func (f *field) mul(x, y int) int {
+	if x == 0 || y == 0 {
+		return 0
+	}
+	return f.exp[f.log[x]+f.log[y]]
+}
+
+// ecc returns the n Reed-Solomon check words for data,
+// using the generator polynomial with roots α¹ through αⁿ.
+func (f *field) ecc(data []int, n int) []int {
+	gen := []int{1}
+	for i := 1; i <= n; i++ {
+		a := f.exp[i%(f.size-1)]
+		next := make([]int, len(gen)+1)
+		for j, g := range gen {
+			next[j] ^= g
+			next[j+1] ^= f.mul(g, a)
+		}
+		gen = next
+	}
+	rem := make([]int, n)
+	for _, d := range data {
+		q := d ^ rem[0]
+		copy(rem, rem[1:])
+		rem[n-1] = 0
+		for j := range rem {
+			rem[j] ^= f.mul(q, gen[j+1])
+		}
+	}
+	return rem
+}
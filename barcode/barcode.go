@@ -0,0 +1,96 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package barcode defines the interface shared by the symbologies
+(qr, datamatrix, aztec, pdf417, and linear) and renders any of them,
+so that output code need not know which kind of code it draws.
+*/
+package barcode
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// A Barcode is a grid of modules.
+type Barcode interface {
+	// Bounds returns the extent of the symbol in modules,
+	// not including its quiet zone.
+	Bounds() image.Rectangle
+
+	// Black reports whether the module at (x, y) is dark.
+	// Modules outside Bounds are light.
+	Black(x, y int) bool
+
+	// Metadata describes the symbol: its symbology and
+	// the quiet zone and bar height to draw it with.
+	Metadata() Metadata
+}
+
+// Metadata describes a symbol.
+type Metadata struct {
+	Symbology string // such as "QR", "Data Matrix", or "EAN-13"
+	QuietZone int    // minimum light margin on each side, in modules
+
+	// BarHeight is the height of a linear code's bars, in modules.
+	// It is zero for two-dimensional codes, whose Bounds give
+	// their height.
+	BarHeight int
+}
+
+// Image returns an image of b with scale pixels per module,
+// including the quiet zone.
+func Image(b Barcode, scale int) image.Image {
+	if scale <= 0 {
+		scale = 1
+	}
+	return &codeImage{b, b.Metadata(), b.Bounds(), scale}
+}
+
+// PNG returns a PNG image of b with scale pixels per module.
+func PNG(b Barcode, scale int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, Image(b, scale)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type codeImage struct {
+	b     Barcode
+	m     Metadata
+	r     image.Rectangle
+	scale int
+}
+
+func (c *codeImage) Bounds() image.Rectangle {
+	q := c.m.QuietZone
+	h := c.r.Dy()
+	if c.m.BarHeight > 0 {
+		h = c.m.BarHeight
+	}
+	return image.Rect(0, 0, (c.r.Dx()+2*q)*c.scale, (h+2*q)*c.scale)
+}
+
+func (c *codeImage) At(x, y int) color.Color {
+	q := c.m.QuietZone
+	mx, my := x/c.scale-q, y/c.scale-q
+	if c.m.BarHeight > 0 {
+		if my < 0 || my >= c.m.BarHeight {
+			return color.Gray{0xFF}
+		}
+		my = 0
+	}
+	if c.b.Black(c.r.Min.X+mx, c.r.Min.Y+my) {
+		return color.Gray{0x00}
+	}
+	return color.Gray{0xFF}
+}
+
+func (c *codeImage) ColorModel() color.Model {
+	return color.GrayModel
+}
@@ -0,0 +1,61 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package barcode
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+)
+
+// checker is a 3×2 code of alternating modules.
+type checker struct{}
+
+func (checker) Bounds() image.Rectangle { return image.Rect(0, 0, 3, 2) }
+func (checker) Black(x, y int) bool     { return x >= 0 && x < 3 && y >= 0 && y < 2 && (x+y)%2 == 0 }
+func (checker) Metadata() Metadata      { return Metadata{Symbology: "checker", QuietZone: 1} }
+
+// bars is a linear code of alternating bars.
+type bars struct{}
+
+func (bars) Bounds() image.Rectangle { return image.Rect(0, 0, 4, 1) }
+func (bars) Black(x, y int) bool     { return x >= 0 && x < 4 && y == 0 && x%2 == 0 }
+func (bars) Metadata() Metadata      { return Metadata{Symbology: "bars", BarHeight: 5} }
+
+func TestImage(t *testing.T) {
+	m := Image(checker{}, 2)
+	if b := m.Bounds(); b != image.Rect(0, 0, 10, 8) {
+		t.Fatalf("checker bounds %v", b)
+	}
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 10; x++ {
+			r, _, _, _ := m.At(x, y).RGBA()
+			mx, my := x/2-1, y/2-1
+			if want := (checker{}).Black(mx, my); (r == 0) != want {
+				t.Errorf("checker pixel %d,%d black=%v, want %v", x, y, r == 0, want)
+			}
+		}
+	}
+
+	m = Image(bars{}, 1)
+	if b := m.Bounds(); b != image.Rect(0, 0, 4, 5) {
+		t.Fatalf("bars bounds %v", b)
+	}
+	for y := 0; y < 5; y++ {
+		r, _, _, _ := m.At(0, y).RGBA()
+		if r != 0 {
+			t.Errorf("bar missing at row %d", y)
+		}
+	}
+
+	data, err := PNG(checker{}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,396 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package datamatrix encodes Data Matrix (ECC 200) codes.
+
+The encoder uses ASCII encodation, packing pairs of digits into
+single codewords and writing bytes above 127 with an upper shift.
+The other encodations (C40, Text, X12, EDIFACT, Base 256) can be
+denser for some inputs, but every reader accepts ASCII.
+*/
+package datamatrix
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+
+	"code.google.com/p/rsc/barcode"
+	"code.google.com/p/rsc/gf256"
+)
+
+// A Shape selects the symbol sizes Encode may choose from.
+type Shape int
+
+const (
+	Square    Shape = iota // square symbols, 10×10 to 144×144
+	Rectangle              // rectangular symbols, 8×18 to 16×48
+	AnyShape               // whichever symbol has the smallest area
+)
+
+// A size describes one symbol size.
+type size struct {
+	rows, cols int // modules, including finder patterns
+	rr, rc     int // rows and columns in each data region
+	data       int // data codewords
+	check      int // check codewords
+	blocks     int // interleaved Reed-Solomon blocks
+}
+
+// sizes lists the ECC 200 symbol sizes, from ISO/IEC 16022 Table 7.
+var sizes = []size{
+	{10, 10, 8, 8, 3, 5, 1},
+	{12, 12, 10, 10, 5, 7, 1},
+	{14, 14, 12, 12, 8, 10, 1},
+	{16, 16, 14, 14, 12, 12, 1},
+	{18, 18, 16, 16, 18, 14, 1},
+	{20, 20, 18, 18, 22, 18, 1},
+	{22, 22, 20, 20, 30, 20, 1},
+	{24, 24, 22, 22, 36, 24, 1},
+	{26, 26, 24, 24, 44, 28, 1},
+	{32, 32, 14, 14, 62, 36, 1},
+	{36, 36, 16, 16, 86, 42, 1},
+	{40, 40, 18, 18, 114, 48, 1},
+	{44, 44, 20, 20, 144, 56, 1},
+	{48, 48, 22, 22, 174, 68, 1},
+	{52, 52, 24, 24, 204, 84, 2},
+	{64, 64, 14, 14, 280, 112, 2},
+	{72, 72, 16, 16, 368, 144, 4},
+	{80, 80, 18, 18, 456, 192, 4},
+	{88, 88, 20, 20, 576, 224, 4},
+	{96, 96, 22, 22, 696, 272, 4},
+	{104, 104, 24, 24, 816, 336, 6},
+	{120, 120, 18, 18, 1050, 408, 6},
+	{132, 132, 20, 20, 1304, 496, 8},
+	{144, 144, 22, 22, 1558, 620, 10},
+
+	{8, 18, 6, 16, 5, 7, 1},
+	{8, 32, 6, 14, 10, 11, 1},
+	{12, 26, 10, 24, 16, 14, 1},
+	{12, 36, 10, 16, 22, 18, 1},
+	{16, 36, 14, 16, 32, 24, 1},
+	{16, 48, 14, 22, 49, 28, 1},
+}
+
+// Field is the field used for Data Matrix error correction:
+// GF(256) with polynomial x⁸+x⁵+x³+x²+1 and generator 2.
+var Field = gf256.NewField(0x12d, 2)
+
+// A Code is a Data Matrix symbol: a grid of modules,
+// Cols wide and Rows high.  Like qr.Code, it implements
+// image.Image and PNG encoding.
+type Code struct {
+	Bitmap []byte // 1 is black, 0 is white
+	Rows   int    // number of modules from top to bottom
+	Cols   int    // number of modules from left to right
+	Stride int    // number of bytes per row
+	Scale  int    // number of image pixels per module
+}
+
+// Black returns true if the module at (x,y) is black.
+func (c *Code) Black(x, y int) bool {
+	return 0 <= x && x < c.Cols && 0 <= y && y < c.Rows &&
+		c.Bitmap[y*c.Stride+x/8]&(1<<uint(7-x&7)) != 0
+}
+
+// Bounds returns the extent of the code in modules.
+// Together with Black and Metadata, it implements barcode.Barcode.
+func (c *Code) Bounds() image.Rectangle {
+	return image.Rect(0, 0, c.Cols, c.Rows)
+}
+
+var _ barcode.Barcode = (*Code)(nil)
+
+// Metadata describes the code for package barcode.
+func (c *Code) Metadata() barcode.Metadata {
+	return barcode.Metadata{Symbology: "Data Matrix", QuietZone: 1}
+}
+
+func (c *Code) set(x, y int) {
+	c.Bitmap[y*c.Stride+x/8] |= 1 << uint(7-x&7)
+}
+
+// Image returns an image of the code with a one-module quiet zone,
+// the minimum that ISO/IEC 16022 allows.
+func (c *Code) Image() image.Image {
+	return codeImage{c}
+}
+
+type codeImage struct {
+	*Code
+}
+
+func (c codeImage) Bounds() image.Rectangle {
+	return image.Rect(0, 0, (c.Cols+2)*c.scale(), (c.Rows+2)*c.scale())
+}
+
+func (c codeImage) At(x, y int) color.Color {
+	s := c.scale()
+	if c.Black(x/s-1, y/s-1) {
+		return color.Gray{0x00}
+	}
+	return color.Gray{0xFF}
+}
+
+func (c codeImage) ColorModel() color.Model {
+	return color.GrayModel
+}
+
+func (c *Code) scale() int {
+	if c.Scale <= 0 {
+		return 1
+	}
+	return c.Scale
+}
+
+// PNG returns a PNG image displaying the code.
+func (c *Code) PNG() []byte {
+	var b bytes.Buffer
+	if err := png.Encode(&b, c.Image()); err != nil {
+		panic("datamatrix: " + err.Error())
+	}
+	return b.Bytes()
+}
+
+// Encode returns an encoding of text in the smallest symbol
+// of the given shape that holds it.
+func Encode(text string, shape Shape) (*Code, error) {
+	data := encodeASCII(text)
+	var best *size
+	for i := range sizes {
+		sz := &sizes[i]
+		rect := sz.rows != sz.cols
+		if shape == Square && rect || shape == Rectangle && !rect {
+			continue
+		}
+		if sz.data < len(data) {
+			continue
+		}
+		if best == nil || sz.rows*sz.cols < best.rows*best.cols {
+			best = sz
+		}
+	}
+	if best == nil {
+		return nil, errors.New("datamatrix: text too long to encode")
+	}
+	return best.encode(data), nil
+}
+
+// encodeASCII returns the ASCII encodation of text.
+func encodeASCII(text string) []byte {
+	var b []byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case isDigit(c) && i+1 < len(text) && isDigit(text[i+1]):
+			b = append(b, 130+(c-'0')*10+(text[i+1]-'0'))
+			i++
+		case c < 128:
+			b = append(b, c+1)
+		default:
+			b = append(b, 235, c-127) // upper shift
+		}
+	}
+	return b
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// encode lays out the data codewords in a symbol of size sz.
+func (sz *size) encode(data []byte) *Code {
+	// Pad: 129 once, then pseudo-random values that readers skip.
+	cw := make([]byte, sz.data+sz.check)
+	copy(cw, data)
+	for i := len(data); i < sz.data; i++ {
+		if i == len(data) {
+			cw[i] = 129
+			continue
+		}
+		r := 129 + (149*(i+1))%253 + 1
+		if r > 254 {
+			r -= 254
+		}
+		cw[i] = byte(r)
+	}
+
+	// Error correction, interleaving codewords among the blocks.
+	nc := sz.check / sz.blocks
+	for b := 0; b < sz.blocks; b++ {
+		var dat []byte
+		for i := b; i < sz.data; i += sz.blocks {
+			dat = append(dat, cw[i])
+		}
+		chk := ecc(dat, nc)
+		for i, c := range chk {
+			cw[sz.data+b+i*sz.blocks] = c
+		}
+	}
+
+	// Place codewords in the mapping matrix, which is the symbol
+	// without its finder patterns, then add the patterns around
+	// each data region.
+	nr := sz.rows / (sz.rr + 2) * sz.rr
+	ncol := sz.cols / (sz.rc + 2) * sz.rc
+	m := place(cw, nr, ncol)
+
+	c := &Code{Rows: sz.rows, Cols: sz.cols, Stride: (sz.cols + 7) / 8}
+	c.Bitmap = make([]byte, c.Stride*c.Rows)
+	for y := 0; y < c.Rows; y++ {
+		ry, ty := y/(sz.rr+2), y%(sz.rr+2)
+		for x := 0; x < c.Cols; x++ {
+			rx, tx := x/(sz.rc+2), x%(sz.rc+2)
+			var black bool
+			switch {
+			case tx == 0 || ty == sz.rr+1:
+				black = true // solid L
+			case ty == 0:
+				black = x%2 == 0 // top clock track
+			case tx == sz.rc+1:
+				black = y%2 == 1 // right clock track
+			default:
+				black = m[ry*sz.rr+ty-1][rx*sz.rc+tx-1]
+			}
+			if black {
+				c.set(x, y)
+			}
+		}
+	}
+	return c
+}
+
+// ecc returns the n check codewords for data.
+// Data Matrix uses the generator polynomial with roots α¹ through αⁿ,
+// unlike QR codes, which start at α⁰.
+func ecc(data []byte, n int) []byte {
+	gen := []byte{1}
+	for i := 1; i <= n; i++ {
+		// gen *= (x + αⁱ)
+		a := Field.Exp(i)
+		next := make([]byte, len(gen)+1)
+		for j, g := range gen {
+			next[j] ^= g
+			next[j+1] ^= Field.Mul(g, a)
+		}
+		gen = next
+	}
+	rem := make([]byte, n)
+	for _, d := range data {
+		f := d ^ rem[0]
+		copy(rem, rem[1:])
+		rem[n-1] = 0
+		for j := 0; j < n; j++ {
+			rem[j] ^= Field.Mul(f, gen[j+1])
+		}
+	}
+	return rem
+}
+
+// place returns the nr×nc mapping matrix holding the codewords cw,
+// following the placement algorithm of ISO/IEC 16022 Annex F.
+func place(cw []byte, nr, nc int) [][]bool {
+	m := make([][]bool, nr)
+	done := make([][]bool, nr)
+	for i := range m {
+		m[i] = make([]bool, nc)
+		done[i] = make([]bool, nc)
+	}
+
+	// module places bit (1 is most significant) of codeword k at row, col,
+	// wrapping positions that fall outside the matrix.
+	module := func(row, col, k int, bit uint) {
+		if row < 0 {
+			row += nr
+			col += 4 - (nr+4)%8
+		}
+		if col < 0 {
+			col += nc
+			row += 4 - (nc+4)%8
+		}
+		m[row][col] = cw[k]&(1<<(8-bit)) != 0
+		done[row][col] = true
+	}
+	// shape places codeword k at the eight given positions.
+	shape := func(k int, pos [8][2]int) {
+		for i, p := range pos {
+			module(p[0], p[1], k, uint(i+1))
+		}
+	}
+	utah := func(row, col, k int) {
+		shape(k, [8][2]int{
+			{row - 2, col - 2}, {row - 2, col - 1}, {row - 1, col - 2}, {row - 1, col - 1},
+			{row - 1, col}, {row, col - 2}, {row, col - 1}, {row, col},
+		})
+	}
+
+	k := 0
+	row, col := 4, 0
+	for {
+		// Corner cases.
+		switch {
+		case row == nr && col == 0:
+			shape(k, [8][2]int{{nr - 1, 0}, {nr - 1, 1}, {nr - 1, 2},
+				{0, nc - 2}, {0, nc - 1}, {1, nc - 1}, {2, nc - 1}, {3, nc - 1}})
+			k++
+		case row == nr-2 && col == 0 && nc%4 != 0:
+			shape(k, [8][2]int{{nr - 3, 0}, {nr - 2, 0}, {nr - 1, 0},
+				{0, nc - 4}, {0, nc - 3}, {0, nc - 2}, {0, nc - 1}, {1, nc - 1}})
+			k++
+		case row == nr-2 && col == 0 && nc%8 == 4:
+			shape(k, [8][2]int{{nr - 3, 0}, {nr - 2, 0}, {nr - 1, 0},
+				{0, nc - 2}, {0, nc - 1}, {1, nc - 1}, {2, nc - 1}, {3, nc - 1}})
+			k++
+		case row == nr+4 && col == 2 && nc%8 == 0:
+			shape(k, [8][2]int{{nr - 1, 0}, {nr - 1, nc - 1},
+				{0, nc - 3}, {0, nc - 2}, {0, nc - 1}, {1, nc - 3}, {1, nc - 2}, {1, nc - 1}})
+			k++
+		}
+
+		// Sweep up and to the right.
+		for {
+			if row < nr && col >= 0 && !done[row][col] {
+				utah(row, col, k)
+				k++
+			}
+			row -= 2
+			col += 2
+			if row < 0 || col >= nc {
+				break
+			}
+		}
+		row++
+		col += 3
+
+		// Sweep down and to the left.
+		for {
+			if row >= 0 && col < nc && !done[row][col] {
+				utah(row, col, k)
+				k++
+			}
+			row += 2
+			col -= 2
+			if row >= nr || col < 0 {
+				break
+			}
+		}
+		row += 3
+		col++
+
+		if row >= nr && col >= nc {
+			break
+		}
+	}
+
+	// Some sizes leave the lower right corner unfilled;
+	// it gets a fixed pattern.
+	if !done[nr-1][nc-1] {
+		m[nr-1][nc-1] = true
+		m[nr-2][nc-2] = true
+	}
+	return m
+}
@@ -0,0 +1,137 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package datamatrix
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+)
+
+func TestECC(t *testing.T) {
+	// Example from ISO/IEC 16022 Annex O: "123456" in a 10×10 symbol.
+	data := encodeASCII("123456")
+	if want := []byte{142, 164, 186}; !bytes.Equal(data, want) {
+		t.Fatalf("encodeASCII = %v, want %v", data, want)
+	}
+	if chk, want := ecc(data, 5), []byte{114, 25, 5, 88, 102}; !bytes.Equal(chk, want) {
+		t.Errorf("ecc = %v, want %v", chk, want)
+	}
+}
+
+var asciiTests = []struct {
+	text string
+	want []byte
+}{
+	{"A", []byte{66}},
+	{"a1", []byte{98, 50}},
+	{"12345", []byte{142, 164, 54}},
+	{"\xe9", []byte{235, 106}},
+}
+
+func TestASCII(t *testing.T) {
+	for _, tt := range asciiTests {
+		if b := encodeASCII(tt.text); !bytes.Equal(b, tt.want) {
+			t.Errorf("encodeASCII(%q) = %v, want %v", tt.text, b, tt.want)
+		}
+	}
+}
+
+// TestPlace checks that place puts every bit of every
+// codeword in its own module, covering the whole matrix.
+func TestPlace(t *testing.T) {
+Sizes:
+	for _, sz := range sizes {
+		nr := sz.rows / (sz.rr + 2) * sz.rr
+		nc := sz.cols / (sz.rc + 2) * sz.rc
+		n := sz.data + sz.check
+		if nr*nc/8 != n {
+			t.Errorf("%dx%d: %d codewords in %dx%d matrix", sz.rows, sz.cols, n, nr, nc)
+			continue
+		}
+		// Sizes with nr*nc not a multiple of 8 have a fixed
+		// pattern in the 2×2 lower right corner.
+		corner := nr*nc%8 != 0
+		fixed := func(r, c int) bool { return corner && r >= nr-2 && c >= nc-2 }
+		count := make([][]int, nr)
+		for i := range count {
+			count[i] = make([]int, nc)
+		}
+		cw := make([]byte, n)
+		for k := 0; k < n; k++ {
+			cw[k] = 0xff
+			set := 0
+			for r, row := range place(cw, nr, nc) {
+				for c, b := range row {
+					if b && !fixed(r, c) {
+						count[r][c]++
+						set++
+					}
+				}
+			}
+			if set != 8 {
+				t.Errorf("%dx%d: codeword %d sets %d modules, want 8", sz.rows, sz.cols, k, set)
+				continue Sizes
+			}
+			cw[k] = 0
+		}
+		for r, row := range count {
+			for c, x := range row {
+				want := 1
+				if fixed(r, c) {
+					want = 0
+				}
+				if x != want {
+					t.Errorf("%dx%d: module %d,%d used %d times", sz.rows, sz.cols, r, c, x)
+					continue Sizes
+				}
+			}
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	c, err := Encode("123456", Square)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Rows != 10 || c.Cols != 10 {
+		t.Fatalf("123456: %dx%d symbol, want 10x10", c.Rows, c.Cols)
+	}
+	// Finder pattern: solid left and bottom, alternating top and right.
+	for i := 0; i < 10; i++ {
+		if !c.Black(0, i) || !c.Black(i, 9) || c.Black(i, 0) != (i%2 == 0) || c.Black(9, i) != (i%2 == 1) {
+			t.Fatalf("bad finder pattern at %d", i)
+		}
+	}
+
+	c, err = Encode("hello, world", Rectangle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Rows != 12 || c.Cols != 26 {
+		t.Errorf("hello, world: %dx%d rectangle, want 12x26", c.Rows, c.Cols)
+	}
+	c, err = Encode("hello, world", AnyShape)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Rows != 16 || c.Cols != 16 {
+		t.Errorf("hello, world: %dx%d symbol, want 16x16", c.Rows, c.Cols)
+	}
+
+	c.Scale = 3
+	m, err := png.Decode(bytes.NewReader(c.PNG()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := m.Bounds(); b.Dx() != 18*3 || b.Dy() != 18*3 {
+		t.Errorf("PNG bounds %v, want 54x54", b)
+	}
+
+	if _, err := Encode(string(make([]byte, 1600)), AnyShape); err == nil {
+		t.Errorf("encoding 1600 bytes succeeded")
+	}
+}
@@ -5,7 +5,10 @@
 // Package gf256 implements arithmetic over the Galois Field GF(256).
 package gf256
 
-import "strconv"
+import (
+	"errors"
+	"strconv"
+)
 
 // A Field represents an instance of GF(256) defined by a specific polynomial.
 type Field struct {
@@ -239,3 +242,155 @@ func (rs *RSEncoder) ECC(data []byte, check []byte) {
 	copy(check, p[len(data):])
 	rs.p = p
 }
+
+// An RSDecoder implements Reed-Solomon error correction
+// over a given field using a given number of error correction bytes.
+// It corrects the codes written by an RSEncoder with the same parameters.
+type RSDecoder struct {
+	f *Field
+	c int
+}
+
+// NewRSDecoder returns a new Reed-Solomon decoder
+// over the given field and number of error correction bytes.
+func NewRSDecoder(f *Field, c int) *RSDecoder {
+	return &RSDecoder{f: f, c: c}
+}
+
+// ErrTooManyErrors is returned by Correct when a block
+// has more errors than its check bytes can correct.
+var ErrTooManyErrors = errors.New("gf256: too many errors to correct")
+
+// Correct corrects errors in msg, which holds data bytes followed by
+// the check bytes for them, as written by RSEncoder.ECC.  It returns
+// the number of bytes it corrected.  A block with c check bytes can
+// have up to c/2 bytes corrected; if msg has more errors than that,
+// Correct usually detects it, returning ErrTooManyErrors and leaving
+// msg unchanged, but it may instead correct msg to the wrong block.
+func (rs *RSDecoder) Correct(msg []byte) (int, error) {
+	if len(msg) < rs.c || len(msg) > 255 {
+		panic("gf256: invalid message length")
+	}
+	f := rs.f
+	s := make([]byte, rs.c)
+	if !f.syndromes(s, msg) {
+		return 0, nil
+	}
+
+	// Find the error locator polynomial lam,
+	// stored lowest degree first, by Berlekamp-Massey.
+	lam := make([]byte, rs.c+1)
+	prev := make([]byte, rs.c+1)
+	tmp := make([]byte, rs.c+1)
+	lam[0], prev[0] = 1, 1
+	nerr, shift, b := 0, 1, byte(1)
+	for n := 0; n < rs.c; n++ {
+		d := s[n]
+		for i := 1; i <= nerr; i++ {
+			d ^= f.Mul(lam[i], s[n-i])
+		}
+		if d == 0 {
+			shift++
+			continue
+		}
+		copy(tmp, lam)
+		scale := f.Mul(d, f.Inv(b))
+		for i := 0; i+shift <= rs.c; i++ {
+			lam[i+shift] ^= f.Mul(scale, prev[i])
+		}
+		if 2*nerr <= n {
+			nerr = n + 1 - nerr
+			copy(prev, tmp)
+			b = d
+			shift = 1
+		} else {
+			shift++
+		}
+	}
+	if 2*nerr > rs.c {
+		return 0, ErrTooManyErrors
+	}
+
+	// The error evaluator polynomial is omega = s·lam mod x^c.
+	omega := make([]byte, rs.c)
+	for i := range omega {
+		for j := 0; j <= i && j <= nerr; j++ {
+			omega[i] ^= f.Mul(lam[j], s[i-j])
+		}
+	}
+
+	// Chien search: msg[i] is the coefficient of x^k for k = len(msg)-1-i,
+	// and an error there makes α^-k a root of lam.  Each error value is
+	// given by Forney's formula, α^k · omega(α^-k) / lam'(α^-k).
+	var pos []int
+	var val []byte
+	for i := range msg {
+		k := len(msg) - 1 - i
+		xinv := f.Exp(255 - k%255)
+		if f.eval(lam[:nerr+1], xinv) != 0 {
+			continue
+		}
+		var deriv byte
+		for j := 1; j <= nerr; j += 2 {
+			deriv ^= f.Mul(lam[j], f.Exp(f.Log(xinv)*(j-1)))
+		}
+		if deriv == 0 {
+			return 0, ErrTooManyErrors
+		}
+		e := f.Mul(f.Exp(k), f.Mul(f.eval(omega, xinv), f.Inv(deriv)))
+		pos = append(pos, i)
+		val = append(val, e)
+	}
+	if len(pos) != nerr {
+		return 0, ErrTooManyErrors
+	}
+	for j, i := range pos {
+		msg[i] ^= val[j]
+	}
+	return nerr, nil
+}
+
+// Syndromes returns the c syndromes of the block with the given data
+// and check bytes: the values of the block, read as a polynomial, at
+// the roots of the generator polynomial.  They are all zero when the
+// block has no errors, so checking them is a cheap way to verify a
+// block without correcting it.
+func (rs *RSDecoder) Syndromes(data, check []byte) []byte {
+	if len(check) != rs.c {
+		panic("gf256: invalid check byte length")
+	}
+	s := make([]byte, rs.c)
+	rs.f.syndromes(s, data, check)
+	return s
+}
+
+// syndromes sets s[j] to the value at α^j of the polynomial whose
+// coefficients, highest degree first, are the bytes of msg.
+// It reports whether any of them is non-zero, meaning that msg
+// has errors.
+func (f *Field) syndromes(s []byte, msg ...[]byte) bool {
+	bad := false
+	for j := range s {
+		s[j] = 0
+		x := f.Exp(j)
+		for _, m := range msg {
+			for _, c := range m {
+				s[j] = f.Mul(s[j], x) ^ c
+			}
+		}
+		if s[j] != 0 {
+			bad = true
+		}
+	}
+	return bad
+}
+
+// eval returns the value at x of the polynomial p,
+// stored lowest degree first.
+func (f *Field) eval(p []byte, x byte) byte {
+	var v byte
+	for i := len(p) - 1; i >= 0; i-- {
+		v = f.Mul(v, x) ^ p[i]
+	}
+	return v
+}
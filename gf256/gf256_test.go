@@ -7,6 +7,7 @@ package gf256
 import (
 	"bytes"
 	"fmt"
+	"math/rand"
 	"testing"
 )
 
@@ -136,6 +137,52 @@ func BenchmarkECC(b *testing.B) {
 	}
 }
 
+func TestCorrect(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, c := range []int{2, 7, 10, 17, 30} {
+		enc, dec := NewRSEncoder(f, c), NewRSDecoder(f, c)
+		for trial := 0; trial < 100; trial++ {
+			msg := make([]byte, 1+r.Intn(255-c))
+			r.Read(msg)
+			msg = append(msg, make([]byte, c)...)
+			enc.ECC(msg[:len(msg)-c], msg[len(msg)-c:])
+			want := append([]byte(nil), msg...)
+
+			nerr := r.Intn(c/2 + 1)
+			for _, i := range r.Perm(len(msg))[:nerr] {
+				msg[i] ^= byte(1 + r.Intn(255))
+			}
+			n, err := dec.Correct(msg)
+			if err != nil || n != nerr || !bytes.Equal(msg, want) {
+				t.Fatalf("c=%d: Correct with %d errors = %d, %v\nhave %x\nwant %x", c, nerr, n, err, msg, want)
+			}
+		}
+	}
+}
+
+func TestCorrectTooMany(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	const c = 10
+	enc, dec := NewRSEncoder(f, c), NewRSDecoder(f, c)
+	for trial := 0; trial < 100; trial++ {
+		msg := make([]byte, 40)
+		r.Read(msg[:40-c])
+		enc.ECC(msg[:40-c], msg[40-c:])
+		want := append([]byte(nil), msg...)
+		for _, i := range r.Perm(len(msg))[:c/2+1] {
+			msg[i] ^= byte(1 + r.Intn(255))
+		}
+		bad := append([]byte(nil), msg...)
+		n, err := dec.Correct(msg)
+		switch {
+		case err != nil && !bytes.Equal(msg, bad):
+			t.Fatalf("Correct = %v but changed msg", err)
+		case err == nil && bytes.Equal(msg, want):
+			t.Fatalf("Correct fixed %d errors with %d check bytes", n, c)
+		}
+	}
+}
+
 func TestGen(t *testing.T) {
 	for i := 0; i < 256; i++ {
 		_, lg := f.gen(i)
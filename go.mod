@@ -0,0 +1,3 @@
+module code.google.com/p/rsc
+
+go 1.18
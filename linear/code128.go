@@ -0,0 +1,147 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package linear
+
+import "fmt"
+
+// code128 gives the bar and space widths of each Code 128 symbol.
+var code128 = [107][]int{
+	{2, 1, 2, 2, 2, 2}, {2, 2, 2, 1, 2, 2}, {2, 2, 2, 2, 2, 1}, {1, 2, 1, 2, 2, 3},
+	{1, 2, 1, 3, 2, 2}, {1, 3, 1, 2, 2, 2}, {1, 2, 2, 2, 1, 3}, {1, 2, 2, 3, 1, 2},
+	{1, 3, 2, 2, 1, 2}, {2, 2, 1, 2, 1, 3}, {2, 2, 1, 3, 1, 2}, {2, 3, 1, 2, 1, 2},
+	{1, 1, 2, 2, 3, 2}, {1, 2, 2, 1, 3, 2}, {1, 2, 2, 2, 3, 1}, {1, 1, 3, 2, 2, 2},
+	{1, 2, 3, 1, 2, 2}, {1, 2, 3, 2, 2, 1}, {2, 2, 3, 2, 1, 1}, {2, 2, 1, 1, 3, 2},
+	{2, 2, 1, 2, 3, 1}, {2, 1, 3, 2, 1, 2}, {2, 2, 3, 1, 1, 2}, {3, 1, 2, 1, 3, 1},
+	{3, 1, 1, 2, 2, 2}, {3, 2, 1, 1, 2, 2}, {3, 2, 1, 2, 2, 1}, {3, 1, 2, 2, 1, 2},
+	{3, 2, 2, 1, 1, 2}, {3, 2, 2, 2, 1, 1}, {2, 1, 2, 1, 2, 3}, {2, 1, 2, 3, 2, 1},
+	{2, 3, 2, 1, 2, 1}, {1, 1, 1, 3, 2, 3}, {1, 3, 1, 1, 2, 3}, {1, 3, 1, 3, 2, 1},
+	{1, 1, 2, 3, 1, 3}, {1, 3, 2, 1, 1, 3}, {1, 3, 2, 3, 1, 1}, {2, 1, 1, 3, 1, 3},
+	{2, 3, 1, 1, 1, 3}, {2, 3, 1, 3, 1, 1}, {1, 1, 2, 1, 3, 3}, {1, 1, 2, 3, 3, 1},
+	{1, 3, 2, 1, 3, 1}, {1, 1, 3, 1, 2, 3}, {1, 1, 3, 3, 2, 1}, {1, 3, 3, 1, 2, 1},
+	{3, 1, 3, 1, 2, 1}, {2, 1, 1, 3, 3, 1}, {2, 3, 1, 1, 3, 1}, {2, 1, 3, 1, 1, 3},
+	{2, 1, 3, 3, 1, 1}, {2, 1, 3, 1, 3, 1}, {3, 1, 1, 1, 2, 3}, {3, 1, 1, 3, 2, 1},
+	{3, 3, 1, 1, 2, 1}, {3, 1, 2, 1, 1, 3}, {3, 1, 2, 3, 1, 1}, {3, 3, 2, 1, 1, 1},
+	{3, 1, 4, 1, 1, 1}, {2, 2, 1, 4, 1, 1}, {4, 3, 1, 1, 1, 1}, {1, 1, 1, 2, 2, 4},
+	{1, 1, 1, 4, 2, 2}, {1, 2, 1, 1, 2, 4}, {1, 2, 1, 4, 2, 1}, {1, 4, 1, 1, 2, 2},
+	{1, 4, 1, 2, 2, 1}, {1, 1, 2, 2, 1, 4}, {1, 1, 2, 4, 1, 2}, {1, 2, 2, 1, 1, 4},
+	{1, 2, 2, 4, 1, 1}, {1, 4, 2, 1, 1, 2}, {1, 4, 2, 2, 1, 1}, {2, 4, 1, 2, 1, 1},
+	{2, 2, 1, 1, 1, 4}, {4, 1, 3, 1, 1, 1}, {2, 4, 1, 1, 1, 2}, {1, 3, 4, 1, 1, 1},
+	{1, 1, 1, 2, 4, 2}, {1, 2, 1, 1, 4, 2}, {1, 2, 1, 2, 4, 1}, {1, 1, 4, 2, 1, 2},
+	{1, 2, 4, 1, 1, 2}, {1, 2, 4, 2, 1, 1}, {4, 1, 1, 2, 1, 2}, {4, 2, 1, 1, 1, 2},
+	{4, 2, 1, 2, 1, 1}, {2, 1, 2, 1, 4, 1}, {2, 1, 4, 1, 2, 1}, {4, 1, 2, 1, 2, 1},
+	{1, 1, 1, 1, 4, 3}, {1, 1, 1, 3, 4, 1}, {1, 3, 1, 1, 4, 1}, {1, 1, 4, 1, 1, 3},
+	{1, 1, 4, 3, 1, 1}, {4, 1, 1, 1, 1, 3}, {4, 1, 1, 3, 1, 1}, {1, 1, 3, 1, 4, 1},
+	{1, 1, 4, 1, 3, 1}, {3, 1, 1, 1, 4, 1}, {4, 1, 1, 1, 3, 1}, {2, 1, 1, 4, 1, 2},
+	{2, 1, 1, 2, 1, 4}, {2, 1, 1, 2, 3, 2}, {2, 3, 3, 1, 1, 1, 2},
+}
+
+// Code 128 code sets and special symbols.
+const (
+	setA = iota
+	setB
+	setC
+)
+
+const (
+	codeC  = 99  // switch to set C, from A or B
+	codeB  = 100 // switch to set B, from A or C
+	codeA  = 101 // switch to set A, from B or C
+	startA = 103
+	stop   = 106
+)
+
+// Code128 returns the Code 128 encoding of text, which must be ASCII.
+// It switches among code sets A, B, and C to keep the code short:
+// even runs of four or more digits use set C, which packs two digits
+// per symbol, and control characters use set A.
+func Code128(text string) (*Code, error) {
+	sym, err := code128Symbols(text)
+	if err != nil {
+		return nil, err
+	}
+	c := &Code{Quiet: 10, kind: "Code 128"}
+	for _, s := range sym {
+		c.Bars = appendWidths(c.Bars, code128[s]...)
+	}
+	return c, nil
+}
+
+// code128Symbols returns the symbols encoding text,
+// from the start symbol to the stop symbol.
+func code128Symbols(text string) ([]int, error) {
+	for i := 0; i < len(text); i++ {
+		if text[i] >= 128 {
+			return nil, fmt.Errorf("linear: non-ASCII byte %#x in Code 128 text", text[i])
+		}
+	}
+
+	var sym []int
+	set := -1
+	change := func(to int) {
+		switch {
+		case set < 0:
+			sym = append(sym, startA+to)
+		case to == setA:
+			sym = append(sym, codeA)
+		case to == setB:
+			sym = append(sym, codeB)
+		case to == setC:
+			sym = append(sym, codeC)
+		}
+		set = to
+	}
+	for i := 0; i < len(text); {
+		// An odd run of digits starts with one digit
+		// in set A or B, leaving an even run for set C.
+		n := digits(text[i:])
+		if n%2 == 0 && (n >= 4 || n >= 2 && (set == setC || i == 0 && n == len(text))) {
+			if set != setC {
+				change(setC)
+			}
+			for j := 0; j < n; j += 2 {
+				sym = append(sym, int(text[i+j]-'0')*10+int(text[i+j+1]-'0'))
+			}
+			i += n
+			continue
+		}
+		c := text[i]
+		switch {
+		case c < 32:
+			if set != setA {
+				change(setA)
+			}
+			sym = append(sym, int(c)+64)
+		case c >= 96:
+			if set != setB {
+				change(setB)
+			}
+			sym = append(sym, int(c)-32)
+		default:
+			if set != setA && set != setB {
+				change(setB)
+			}
+			sym = append(sym, int(c)-32)
+		}
+		i++
+	}
+	if set < 0 {
+		change(setB)
+	}
+
+	sum := sym[0]
+	for i, s := range sym[1:] {
+		sum += (i + 1) * s
+	}
+	return append(sym, sum%103, stop), nil
+}
+
+// digits returns the number of leading digits in s.
+func digits(s string) int {
+	n := 0
+	for n < len(s) && '0' <= s[n] && s[n] <= '9' {
+		n++
+	}
+	return n
+}
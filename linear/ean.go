@@ -0,0 +1,136 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package linear
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EAN/UPC digit patterns.  The right-hand (R) patterns are the
+// complements of the left-hand odd parity (L) patterns, and the
+// left-hand even parity (G) patterns are the R patterns reversed.
+var eanL = [10]string{
+	"0001101", "0011001", "0010011", "0111101", "0100011",
+	"0110001", "0101111", "0111011", "0110111", "0001011",
+}
+
+// eanParity gives, for each leading digit of an EAN-13 code,
+// which of the six left-hand digits use G patterns.
+var eanParity = [10]string{
+	"LLLLLL", "LLGLGG", "LLGGLG", "LLGGGL", "LGLLGG",
+	"LGGLLG", "LGGGLG", "LGLGLG", "LGLGGL", "LGGLGL",
+}
+
+func eanR(d int) string {
+	return strings.Map(func(r rune) rune { return '0' + '1' - r }, eanL[d])
+}
+
+func eanG(d int) string {
+	r := []byte(eanR(d))
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+
+// CheckDigit returns the EAN/UPC check digit for digits,
+// which must not include the check digit itself.
+// Counting from the right, digits alternate weights 3 and 1.
+func CheckDigit(digits string) (byte, error) {
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		c := digits[len(digits)-1-i]
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("linear: non-digit %q", c)
+		}
+		w := 1
+		if i%2 == 0 {
+			w = 3
+		}
+		sum += w * int(c-'0')
+	}
+	return byte('0' + (10-sum%10)%10), nil
+}
+
+// withCheck returns digits with its check digit, given n digits
+// with or without it; an included check digit must be correct.
+func withCheck(kind, digits string, n int) (string, error) {
+	if len(digits) != n-1 && len(digits) != n {
+		return "", fmt.Errorf("linear: %s needs %d or %d digits, have %d", kind, n-1, n, len(digits))
+	}
+	c, err := CheckDigit(digits[:n-1])
+	if err != nil {
+		return "", err
+	}
+	if len(digits) == n {
+		if digits[n-1] != c {
+			return "", fmt.Errorf("linear: %s check digit is %c, want %c", kind, digits[n-1], c)
+		}
+		return digits, nil
+	}
+	return digits + string(c), nil
+}
+
+// EAN13 returns the EAN-13 encoding of digits, which must be
+// 12 digits or 13 digits ending in the correct check digit.
+func EAN13(digits string) (*Code, error) {
+	d, err := withCheck("EAN-13", digits, 13)
+	if err != nil {
+		return nil, err
+	}
+	c := &Code{Quiet: 11, kind: "EAN-13"}
+	c.Bars = appendModules(c.Bars, "101")
+	parity := eanParity[d[0]-'0']
+	for i := 1; i <= 6; i++ {
+		if parity[i-1] == 'G' {
+			c.Bars = appendModules(c.Bars, eanG(int(d[i]-'0')))
+		} else {
+			c.Bars = appendModules(c.Bars, eanL[d[i]-'0'])
+		}
+	}
+	c.Bars = appendModules(c.Bars, "01010")
+	for i := 7; i <= 12; i++ {
+		c.Bars = appendModules(c.Bars, eanR(int(d[i]-'0')))
+	}
+	c.Bars = appendModules(c.Bars, "101")
+	return c, nil
+}
+
+// UPCA returns the UPC-A encoding of digits, which must be
+// 11 digits or 12 digits ending in the correct check digit.
+// A UPC-A code is an EAN-13 code with a leading zero.
+func UPCA(digits string) (*Code, error) {
+	d, err := withCheck("UPC-A", digits, 12)
+	if err != nil {
+		return nil, err
+	}
+	c, err := EAN13("0" + d)
+	if err != nil {
+		return nil, err
+	}
+	c.kind = "UPC-A"
+	return c, nil
+}
+
+// EAN8 returns the EAN-8 encoding of digits, which must be
+// 7 digits or 8 digits ending in the correct check digit.
+func EAN8(digits string) (*Code, error) {
+	d, err := withCheck("EAN-8", digits, 8)
+	if err != nil {
+		return nil, err
+	}
+	c := &Code{Quiet: 7, kind: "EAN-8"}
+	c.Bars = appendModules(c.Bars, "101")
+	for i := 0; i < 4; i++ {
+		c.Bars = appendModules(c.Bars, eanL[d[i]-'0'])
+	}
+	c.Bars = appendModules(c.Bars, "01010")
+	for i := 4; i < 8; i++ {
+		c.Bars = appendModules(c.Bars, eanR(int(d[i]-'0')))
+	}
+	c.Bars = appendModules(c.Bars, "101")
+	return c, nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package linear encodes one-dimensional barcodes:
+Code 128 and the EAN/UPC family.
+*/
+package linear
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+
+	"code.google.com/p/rsc/barcode"
+)
+
+// A Code is a linear barcode: a row of modules, each a bar or a space.
+// Like qr.Code, it implements image.Image and PNG encoding.
+type Code struct {
+	Bars []bool // true for a bar (black) module
+
+	// Scale is the number of image pixels per module, and Height
+	// the height of the bars in pixels.  If zero, they default
+	// to 1 and 50 times Scale.
+	Scale  int
+	Height int
+
+	// Quiet is the width of the quiet zone on each side, in modules.
+	Quiet int
+
+	kind string // symbology name, for Metadata
+}
+
+// Black returns true if the module at x is a bar.
+// A linear code is one module high, so y must be 0.
+func (c *Code) Black(x, y int) bool {
+	return y == 0 && 0 <= x && x < len(c.Bars) && c.Bars[x]
+}
+
+// Bounds returns the extent of the code in modules.
+// Together with Black and Metadata, it implements barcode.Barcode.
+func (c *Code) Bounds() image.Rectangle {
+	return image.Rect(0, 0, len(c.Bars), 1)
+}
+
+var _ barcode.Barcode = (*Code)(nil)
+
+// Metadata describes the code for package barcode.
+func (c *Code) Metadata() barcode.Metadata {
+	return barcode.Metadata{Symbology: c.kind, QuietZone: c.Quiet, BarHeight: 50}
+}
+
+// Image returns an image of the code, with its quiet zone.
+func (c *Code) Image() image.Image {
+	return codeImage{c}
+}
+
+type codeImage struct {
+	*Code
+}
+
+func (c *Code) scale() int {
+	if c.Scale <= 0 {
+		return 1
+	}
+	return c.Scale
+}
+
+func (c *Code) height() int {
+	if c.Height <= 0 {
+		return 50 * c.scale()
+	}
+	return c.Height
+}
+
+func (c codeImage) Bounds() image.Rectangle {
+	return image.Rect(0, 0, (len(c.Bars)+2*c.Quiet)*c.scale(), c.height())
+}
+
+func (c codeImage) At(x, y int) color.Color {
+	if c.Black(x/c.scale()-c.Quiet, 0) {
+		return color.Gray{0x00}
+	}
+	return color.Gray{0xFF}
+}
+
+func (c codeImage) ColorModel() color.Model {
+	return color.GrayModel
+}
+
+// PNG returns a PNG image displaying the code.
+func (c *Code) PNG() []byte {
+	var b bytes.Buffer
+	if err := png.Encode(&b, c.Image()); err != nil {
+		panic("linear: " + err.Error())
+	}
+	return b.Bytes()
+}
+
+// appendWidths appends alternating bars and spaces
+// of the given widths, starting with a bar.
+func appendWidths(bars []bool, widths ...int) []bool {
+	for i, w := range widths {
+		for j := 0; j < w; j++ {
+			bars = append(bars, i%2 == 0)
+		}
+	}
+	return bars
+}
+
+// appendModules appends modules given as a string of 0s and 1s.
+func appendModules(bars []bool, s string) []bool {
+	for i := 0; i < len(s); i++ {
+		bars = append(bars, s[i] == '1')
+	}
+	return bars
+}
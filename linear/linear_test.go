@@ -0,0 +1,145 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package linear
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"reflect"
+	"testing"
+)
+
+func TestCode128Table(t *testing.T) {
+	seen := make(map[string]int)
+	for i, w := range code128 {
+		sum, bars := 0, 0
+		for j, x := range w {
+			sum += x
+			if j%2 == 0 {
+				bars += x
+			}
+		}
+		want := 11
+		if i == stop {
+			want = 13
+		}
+		if sum != want || bars%2 != 0 {
+			t.Errorf("symbol %d: %v has width %d, bar width %d", i, w, sum, bars)
+		}
+		k := fmt.Sprint(w)
+		if j, ok := seen[k]; ok {
+			t.Errorf("symbols %d and %d are both %v", j, i, w)
+		}
+		seen[k] = i
+	}
+}
+
+var code128Tests = []struct {
+	text string
+	sym  []int
+}{
+	{"PJJ123C", []int{104, 48, 42, 42, 17, 18, 19, 35, 55, 106}},
+	{"1234", []int{105, 12, 34, 82, 106}},
+	{"12345", []int{104, 17, 99, 23, 45, 53, 106}},
+	{"ab\n", []int{104, 65, 66, 101, 74, 76, 106}},
+	{"", []int{104, 1, 106}},
+}
+
+func TestCode128(t *testing.T) {
+	for _, tt := range code128Tests {
+		sym, err := code128Symbols(tt.text)
+		if err != nil {
+			t.Errorf("%q: %v", tt.text, err)
+			continue
+		}
+		if !reflect.DeepEqual(sym, tt.sym) {
+			t.Errorf("%q: symbols %v, want %v", tt.text, sym, tt.sym)
+		}
+	}
+	c, err := Code128("PJJ123C")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(c.Bars); n != 11*9+13 {
+		t.Errorf("PJJ123C: %d modules, want %d", n, 11*9+13)
+	}
+	if _, err := Code128("caf\xe9"); err == nil {
+		t.Errorf("non-ASCII text succeeded")
+	}
+}
+
+func TestCheckDigit(t *testing.T) {
+	for _, tt := range []struct{ digits, check string }{
+		{"400638133393", "1"},
+		{"03600029145", "2"},
+		{"9638507", "4"},
+	} {
+		c, err := CheckDigit(tt.digits)
+		if err != nil || string(c) != tt.check {
+			t.Errorf("CheckDigit(%s) = %c, %v, want %s", tt.digits, c, err, tt.check)
+		}
+	}
+}
+
+func bits(c *Code) string {
+	var b []byte
+	for _, x := range c.Bars {
+		if x {
+			b = append(b, '1')
+		} else {
+			b = append(b, '0')
+		}
+	}
+	return string(b)
+}
+
+func TestEAN(t *testing.T) {
+	c, err := EAN13("400638133393")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Leading 4: parity LGLLGG for 006381, then R patterns for 333931.
+	want := "101" + "0001101" + "0100111" + "0101111" + "0111101" + "0001001" + "0110011" +
+		"01010" + "1000010" + "1000010" + "1000010" + "1110100" + "1000010" + "1100110" + "101"
+	if got := bits(c); got != want {
+		t.Errorf("EAN13:\nhave %s\nwant %s", got, want)
+	}
+
+	u, err := UPCA("036000291452")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := EAN13("0036000291452")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bits(u) != bits(e) {
+		t.Errorf("UPC-A differs from EAN-13 with leading zero")
+	}
+
+	c, err = EAN8("9638507")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(c.Bars); n != 67 {
+		t.Errorf("EAN8: %d modules, want 67", n)
+	}
+
+	for _, bad := range []string{"40063813339", "4006381333932", "40063813339x"} {
+		if _, err := EAN13(bad); err == nil {
+			t.Errorf("EAN13(%s) succeeded", bad)
+		}
+	}
+
+	c.Scale = 2
+	m, err := png.Decode(bytes.NewReader(c.PNG()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := m.Bounds(); b.Dx() != 2*(67+14) || b.Dy() != 100 {
+		t.Errorf("PNG bounds %v", b)
+	}
+}
@@ -0,0 +1,447 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package pdf417 implements encoding of PDF417 symbols.
+
+Encode splits the text into text, byte, and numeric compaction
+segments, adds Reed-Solomon check codewords over GF(929), and
+arranges the codewords in rows with their row indicators.
+The resulting Symbol draws each row with the bar-space patterns
+of its cluster and implements barcode.Barcode.
+
+MicroPDF417 is not supported.
+*/
+package pdf417
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"math/big"
+
+	"code.google.com/p/rsc/barcode"
+)
+
+// Auto selects the error correction level that ISO/IEC 15438
+// recommends for the amount of data.
+const Auto = -1
+
+// A Symbol is a PDF417 symbol described by its codewords.
+type Symbol struct {
+	Rows  int // 3 to 90
+	Cols  int // data columns, 1 to 30
+	Level int // error correction level, 0 to 8
+	Scale int // number of image pixels per module
+
+	// Codewords holds the rows of the symbol.  Each row
+	// is a left row indicator, Cols data codewords, and a
+	// right row indicator.  The codewords in row r are drawn
+	// using the bar-space patterns of cluster 3*(r%3).
+	Codewords [][]int
+}
+
+// Each row is rowHeight modules high, the minimum the
+// standard allows, and starts and ends with these patterns.
+const (
+	rowHeight = 3
+	start     = 0x1fea8 // 17 modules
+	stop      = 0x3fa29 // 18 modules
+)
+
+// Black returns true if the module at (x,y) is black.
+func (s *Symbol) Black(x, y int) bool {
+	if x < 0 || y < 0 || y >= s.Rows*rowHeight {
+		return false
+	}
+	row := s.Codewords[y/rowHeight]
+	i, j := x/17, x%17
+	switch {
+	case i == 0:
+		return start>>uint(16-j)&1 != 0
+	case i <= len(row):
+		return clusters[y/rowHeight%3][row[i-1]]>>uint(16-j)&1 != 0
+	case x < 17*(len(row)+1)+18:
+		return stop>>uint(17-(x-17*(len(row)+1)))&1 != 0
+	}
+	return false
+}
+
+// Bounds returns the extent of the symbol in modules.
+// Together with Black and Metadata, it implements barcode.Barcode.
+func (s *Symbol) Bounds() image.Rectangle {
+	return image.Rect(0, 0, 17*s.Cols+69, rowHeight*s.Rows)
+}
+
+var _ barcode.Barcode = (*Symbol)(nil)
+
+// Metadata describes the symbol for package barcode.
+func (s *Symbol) Metadata() barcode.Metadata {
+	return barcode.Metadata{Symbology: "PDF417", QuietZone: 2}
+}
+
+// Image returns an image of the symbol, including its quiet zone.
+func (s *Symbol) Image() image.Image {
+	return barcode.Image(s, s.Scale)
+}
+
+// PNG returns a PNG image displaying the symbol.
+func (s *Symbol) PNG() []byte {
+	b, err := barcode.PNG(s, s.Scale)
+	if err != nil {
+		panic("pdf417: " + err.Error())
+	}
+	return b
+}
+
+// Special codewords.
+const (
+	latchText    = 900
+	latchByte    = 901
+	latchNumeric = 902
+	latchByte6   = 924 // byte compaction of a multiple of 6 bytes
+	pad          = 900
+
+	maxCodewords = 928
+)
+
+// Encode returns the symbol encoding text at the given
+// error correction level, or at the recommended level if level is Auto.
+func Encode(text string, level int) (*Symbol, error) {
+	data := compact(text)
+	if level == Auto {
+		switch n := len(data) + 1; {
+		case n <= 40:
+			level = 2
+		case n <= 160:
+			level = 3
+		case n <= 320:
+			level = 4
+		default:
+			level = 5
+		}
+	}
+	if level < 0 || level > 8 {
+		return nil, fmt.Errorf("pdf417: invalid error correction level %d", level)
+	}
+	nc := 2 << uint(level)
+
+	// A symbol holds at most maxCodewords codewords,
+	// counting the length descriptor and check words.
+	n := len(data) + 1 + nc
+	if n > maxCodewords {
+		return nil, errors.New("pdf417: text too long to encode")
+	}
+
+	// Choose the column count giving the symbol's preferred
+	// proportions, about three times as wide as high.
+	const width, height, ratio = 0.357, 2.0, 3.0
+	cols, rows := 0, 0
+	best := math.Inf(1)
+	for c := 1; c <= 30; c++ {
+		r := (n + c - 1) / c
+		if r < 3 {
+			r = 3
+		}
+		if r > 90 || r*c > maxCodewords {
+			continue
+		}
+		d := math.Abs(float64(17*c+69)*width/(float64(r)*height) - ratio)
+		if d < best {
+			best, cols, rows = d, c, r
+		}
+	}
+	if cols == 0 {
+		return nil, errors.New("pdf417: text too long to encode")
+	}
+
+	// Symbol length descriptor, data, padding, check words.
+	cw := make([]int, 0, rows*cols)
+	cw = append(cw, rows*cols-nc)
+	cw = append(cw, data...)
+	for len(cw) < rows*cols-nc {
+		cw = append(cw, pad)
+	}
+	cw = append(cw, check(cw, nc)...)
+
+	s := &Symbol{Rows: rows, Cols: cols, Level: level}
+	for r := 0; r < rows; r++ {
+		base := 30 * (r / 3)
+		ind := [3]int{
+			base + (rows-1)/3,
+			base + level*3 + (rows-1)%3,
+			base + cols - 1,
+		}
+		row := []int{ind[r%3]}
+		row = append(row, cw[r*cols:(r+1)*cols]...)
+		row = append(row, ind[(r+2)%3])
+		s.Codewords = append(s.Codewords, row)
+	}
+	return s, nil
+}
+
+// check returns the nc check codewords for data.
+func check(data []int, nc int) []int {
+	// Generator polynomial ∏(x - 3ⁱ) for i = 1..nc,
+	// with g[j] the coefficient of xʲ.
+	g := []int{1}
+	a := 1
+	for i := 1; i <= nc; i++ {
+		a = a * 3 % 929
+		next := make([]int, len(g)+1)
+		for j, c := range g {
+			next[j+1] = (next[j+1] + c) % 929
+			next[j] = (next[j] + 929 - c*a%929) % 929
+		}
+		g = next
+	}
+
+	// Remainder of data·xⁿᶜ divided by g, negated.
+	e := make([]int, nc)
+	for _, d := range data {
+		t := (d + e[nc-1]) % 929
+		for j := nc - 1; j > 0; j-- {
+			e[j] = (e[j-1] + 929 - t*g[j]%929) % 929
+		}
+		e[0] = (929 - t*g[0]%929) % 929
+	}
+	out := make([]int, nc)
+	for j := range e {
+		out[nc-1-j] = (929 - e[j]) % 929
+	}
+	return out
+}
+
+// compact returns the data codewords for text.  Runs of 13 or more
+// digits use numeric compaction, runs of 5 or more text characters
+// use text compaction, and everything else uses byte compaction.
+// The symbol starts in text compaction mode.
+func compact(text string) []int {
+	var cw []int
+	mode := latchText
+	for i := 0; i < len(text); {
+		if n := digits(text[i:]); n >= 13 {
+			cw = append(cw, latchNumeric)
+			cw = appendNumeric(cw, text[i:i+n])
+			mode = latchNumeric
+			i += n
+			continue
+		}
+		if n := textRun(text[i:]); n >= 5 || n > 0 && i+n == len(text) && mode == latchText {
+			if mode != latchText {
+				cw = append(cw, latchText)
+				mode = latchText
+			}
+			cw = appendText(cw, text[i:i+n])
+			i += n
+			continue
+		}
+		j := i + 1
+		for j < len(text) && digits(text[j:]) < 13 && textRun(text[j:]) < 5 {
+			j++
+		}
+		cw = appendBytes(cw, text[i:j])
+		mode = latchByte
+		i = j
+	}
+	return cw
+}
+
+// digits returns the number of leading digits in s.
+func digits(s string) int {
+	n := 0
+	for n < len(s) && '0' <= s[n] && s[n] <= '9' {
+		n++
+	}
+	return n
+}
+
+// textRun returns the number of leading characters of s that
+// text compaction can encode, stopping before 13 digits in a row.
+func textRun(s string) int {
+	n := 0
+	for n < len(s) && isText(s[n]) {
+		if digits(s[n:]) >= 13 {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+func isText(c byte) bool {
+	return c == '\t' || c == '\n' || c == '\r' || ' ' <= c && c <= '~'
+}
+
+// appendNumeric appends the numeric compaction of the digits s:
+// each group of up to 44 digits, with a leading 1, in base 900.
+func appendNumeric(cw []int, s string) []int {
+	for len(s) > 0 {
+		n := len(s)
+		if n > 44 {
+			n = 44
+		}
+		v, _ := new(big.Int).SetString("1"+s[:n], 10)
+		var d []int
+		m := new(big.Int)
+		b900 := big.NewInt(900)
+		for v.Sign() > 0 {
+			v.DivMod(v, b900, m)
+			d = append(d, int(m.Int64()))
+		}
+		for i := len(d) - 1; i >= 0; i-- {
+			cw = append(cw, d[i])
+		}
+		s = s[n:]
+	}
+	return cw
+}
+
+// appendBytes appends the byte compaction of s:
+// each group of 6 bytes as 5 base-900 digits,
+// and any remaining bytes one per codeword.
+func appendBytes(cw []int, s string) []int {
+	if len(s)%6 == 0 {
+		cw = append(cw, latchByte6)
+	} else {
+		cw = append(cw, latchByte)
+	}
+	for ; len(s) >= 6; s = s[6:] {
+		var v int64
+		for i := 0; i < 6; i++ {
+			v = v<<8 | int64(s[i])
+		}
+		var d [5]int
+		for i := 4; i >= 0; i-- {
+			d[i] = int(v % 900)
+			v /= 900
+		}
+		cw = append(cw, d[:]...)
+	}
+	for i := 0; i < len(s); i++ {
+		cw = append(cw, int(s[i]))
+	}
+	return cw
+}
+
+// Text compaction submodes.
+const (
+	alpha = iota
+	lower
+	mixed
+	punct
+)
+
+// Submode tables: the value of each character in each submode.
+const (
+	mixedChars = "0123456789&\r\t,:#-.$/+%*=^"
+	punctChars = ";<>@[\\]_`~!\r\t,:\n-.$/\"|*()?{}'"
+)
+
+// Submode switching values.
+const (
+	textSpace  = 26
+	lowerLatch = 27 // from alpha or mixed
+	alphaShift = 27 // from lower
+	mixedLatch = 28 // from alpha or lower
+	alphaLatch = 28 // from mixed
+	punctShift = 29 // from alpha, lower, or mixed
+	punctLatch = 25 // from mixed
+	punctExit  = 29 // from punct, to alpha
+)
+
+// value returns the value of c in the given submode, or -1.
+func value(c byte, sub int) int {
+	switch sub {
+	case alpha:
+		if 'A' <= c && c <= 'Z' {
+			return int(c - 'A')
+		}
+	case lower:
+		if 'a' <= c && c <= 'z' {
+			return int(c - 'a')
+		}
+	case mixed:
+		for i := 0; i < len(mixedChars); i++ {
+			if mixedChars[i] == c {
+				return i
+			}
+		}
+	case punct:
+		for i := 0; i < len(punctChars); i++ {
+			if punctChars[i] == c {
+				return i
+			}
+		}
+	}
+	if c == ' ' && sub != punct {
+		return textSpace
+	}
+	return -1
+}
+
+// appendText appends the text compaction of s, two values per codeword.
+func appendText(cw []int, s string) []int {
+	var v []int
+	sub := alpha
+	// latch switches to submode to, by way of alpha or mixed if needed.
+	latch := func(to int) {
+		if sub == punct {
+			v = append(v, punctExit)
+			sub = alpha
+			if to == alpha {
+				return
+			}
+		}
+		switch {
+		case to == alpha && sub == lower:
+			v = append(v, mixedLatch, alphaLatch)
+		case to == alpha:
+			v = append(v, alphaLatch)
+		case to == lower:
+			v = append(v, lowerLatch)
+		case to == mixed:
+			v = append(v, mixedLatch)
+		case to == punct:
+			if sub != mixed {
+				v = append(v, mixedLatch)
+			}
+			v = append(v, punctLatch)
+		}
+		sub = to
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		var next byte
+		if i+1 < len(s) {
+			next = s[i+1]
+		}
+		switch {
+		case value(c, sub) >= 0:
+		case value(c, alpha) >= 0 && sub == lower && value(next, alpha) < 0:
+			v = append(v, alphaShift, value(c, alpha))
+			continue
+		case value(c, alpha) >= 0:
+			latch(alpha)
+		case value(c, lower) >= 0:
+			latch(lower)
+		case value(c, mixed) >= 0:
+			latch(mixed)
+		case value(next, punct) >= 0 && value(next, sub) < 0:
+			latch(punct)
+		default:
+			v = append(v, punctShift, value(c, punct))
+			continue
+		}
+		v = append(v, value(c, sub))
+	}
+	if len(v)%2 == 1 {
+		v = append(v, punctShift)
+	}
+	for i := 0; i < len(v); i += 2 {
+		cw = append(cw, 30*v[i]+v[i+1])
+	}
+	return cw
+}
@@ -0,0 +1,202 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf417
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var compactTests = []struct {
+	text string
+	want []int
+}{
+	// Text compaction: "PDF417" is alpha P D F, then mixed latch, 4 1 7.
+	{"PDF417", []int{15*30 + 3, 5*30 + 28, 4*30 + 1, 7*30 + 29}},
+	{"hello", []int{27*30 + 7, 4*30 + 11, 11*30 + 14}},
+	{"A b!", []int{0*30 + 26, 27*30 + 1, 29*30 + 10}},
+	{"000213298174000", []int{902, 1, 624, 434, 632, 282, 200}}, // ISO/IEC 15438 example
+	{"\x00\x01\x02\x03\x04\x05", []int{924, 0, 5, 844, 88, 165}},
+	{"\xff\xfe", []int{901, 255, 254}},
+}
+
+func TestCompact(t *testing.T) {
+	for _, tt := range compactTests {
+		if cw := compact(tt.text); !reflect.DeepEqual(cw, tt.want) {
+			t.Errorf("compact(%q) = %v, want %v", tt.text, cw, tt.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	// The check words make the codeword polynomial vanish at 3¹…3ⁿ.
+	data := []int{5, 453, 178, 121, 239}
+	for level := 0; level <= 8; level++ {
+		nc := 2 << uint(level)
+		cw := append(append([]int(nil), data...), check(data, nc)...)
+		a := 1
+		for i := 1; i <= nc; i++ {
+			a = a * 3 % 929
+			s := 0
+			for _, c := range cw {
+				s = (s*a + c) % 929
+			}
+			if s != 0 {
+				t.Errorf("level %d: syndrome %d = %d", level, i, s)
+				break
+			}
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	s, err := Encode("PDF417 "+strings.Repeat("test ", 20), Auto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Level != 3 || len(s.Codewords) != s.Rows {
+		t.Fatalf("level %d, %d rows, want level 3, %d rows", s.Level, len(s.Codewords), s.Rows)
+	}
+	if s.Rows < 3 || s.Rows > 90 || s.Cols < 1 || s.Cols > 30 {
+		t.Fatalf("bad dimensions %dx%d", s.Rows, s.Cols)
+	}
+	for r, row := range s.Codewords {
+		if len(row) != s.Cols+2 {
+			t.Fatalf("row %d has %d codewords, want %d", r, len(row), s.Cols+2)
+		}
+		for _, c := range row {
+			if c < 0 || c >= 929 {
+				t.Fatalf("row %d: invalid codeword %d", r, c)
+			}
+		}
+	}
+	// The row indicators encode the dimensions.
+	row0, row1, row2 := s.Codewords[0], s.Codewords[1], s.Codewords[2]
+	if row0[0] != (s.Rows-1)/3 || row1[0] != s.Level*3+(s.Rows-1)%3 || row2[0] != s.Cols-1 {
+		t.Errorf("bad left row indicators %d %d %d", row0[0], row1[0], row2[0])
+	}
+	if n := s.Codewords[0][1]; n != s.Rows*s.Cols-(2<<uint(s.Level)) {
+		t.Errorf("symbol length descriptor %d", n)
+	}
+
+	if _, err := Encode("x", 9); err == nil {
+		t.Errorf("level 9 succeeded")
+	}
+}
+
+func TestTooLong(t *testing.T) {
+	// 928 codewords: the length descriptor, 925 data words
+	// in text compaction, and 2 check words at level 0.
+	text := strings.Repeat("A", 2*925)
+	if _, err := Encode(text, 0); err != nil {
+		t.Errorf("Encode(%d bytes, 0): %v", len(text), err)
+	}
+	if _, err := Encode(text+"AA", 0); err == nil {
+		t.Errorf("Encode(%d bytes, 0) succeeded", len(text)+2)
+	}
+	if _, err := Encode(text, 1); err == nil {
+		t.Errorf("Encode(%d bytes, 1) succeeded", len(text))
+	}
+	for _, level := range []int{Auto, 0, 4, 8} {
+		s, err := Encode(strings.Repeat("x\xff", 300), level)
+		if err != nil {
+			continue
+		}
+		if s.Rows*s.Cols > 928 {
+			t.Errorf("level %d: %dx%d symbol has more than 928 codewords", level, s.Rows, s.Cols)
+		}
+	}
+}
+
+// widths returns the element widths of a 17-module pattern.
+func widths(p uint32) []int {
+	var w []int
+	prev := uint32(2)
+	for i := 16; i >= 0; i-- {
+		b := p >> uint(i) & 1
+		if b != prev {
+			w = append(w, 0)
+			prev = b
+		}
+		w[len(w)-1]++
+	}
+	return w
+}
+
+func TestClusters(t *testing.T) {
+	// Every pattern is 4 bars and 4 spaces of 1 to 6 modules,
+	// starting with a bar, and the bar widths determine the
+	// cluster: (b1 - b2 + b3 - b4) mod 9.
+	seen := make(map[uint32]bool)
+	for k := range clusters {
+		for cw, p := range clusters[k] {
+			w := widths(p)
+			if p>>16 != 1 || len(w) != 8 || seen[p] {
+				t.Fatalf("cluster %d codeword %d: bad pattern %#x", 3*k, cw, p)
+			}
+			seen[p] = true
+			for _, x := range w {
+				if x > 6 {
+					t.Fatalf("cluster %d codeword %d: bad pattern %#x", 3*k, cw, p)
+				}
+			}
+			if c := (w[0] - w[2] + w[4] - w[6] + 18) % 9; c != 3*k {
+				t.Fatalf("cluster %d codeword %d: pattern %#x is in cluster %d", 3*k, cw, p, c)
+			}
+		}
+	}
+	// ISO/IEC 15438 Annex A: codeword 0 in cluster 0 is 31111136.
+	if w := widths(clusters[0][0]); !reflect.DeepEqual(w, []int{3, 1, 1, 1, 1, 1, 3, 6}) {
+		t.Errorf("cluster 0 codeword 0 = %v, want 31111136", w)
+	}
+}
+
+func TestDraw(t *testing.T) {
+	s, err := Encode("PDF417 drawing test 1234567890123", Auto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := s.Bounds()
+	if b.Dx() != 17*s.Cols+69 || b.Dy() != 3*s.Rows {
+		t.Fatalf("bounds %v for %dx%d symbol", b, s.Rows, s.Cols)
+	}
+	// Read each row back into codewords.
+	index := make([]map[uint32]int, 3)
+	for k := range index {
+		index[k] = make(map[uint32]int)
+		for cw, p := range clusters[k] {
+			index[k][p] = cw
+		}
+	}
+	for y := 0; y < b.Dy(); y++ {
+		var bits uint64
+		for x := 0; x < b.Dx(); x++ {
+			bits <<= 1
+			if s.Black(x, y) {
+				bits |= 1
+			}
+			if x == 16 && bits != start {
+				t.Fatalf("row %d: start pattern %#x", y, bits)
+			}
+			if x >= 17 && x%17 == 16 && x < b.Dx()-18 {
+				row := s.Codewords[y/3]
+				p := uint32(bits & 0x1ffff)
+				if cw, ok := index[y/3%3][p]; !ok || cw != row[x/17-1] {
+					t.Fatalf("row %d: codeword %d drawn as %#x", y, x/17-1, p)
+				}
+			}
+		}
+		if p := bits & 0x3ffff; p != stop {
+			t.Fatalf("row %d: stop pattern %#x", y, p)
+		}
+	}
+	if s.Black(-1, 0) || s.Black(b.Dx(), 0) || s.Black(0, b.Dy()) {
+		t.Errorf("modules outside the symbol are black")
+	}
+	if m := s.Image(); m.Bounds().Dx() != b.Dx()+4 {
+		t.Errorf("image width %d, want %d", m.Bounds().Dx(), b.Dx()+4)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// A Bundle holds a code's image together with the text needed
+// to present it accessibly, such as on a web page.
+type Bundle struct {
+	PNG []byte `json:"-"`
+
+	// AltText is a short description suitable for an img alt attribute,
+	// such as "QR code linking to swtch.com".
+	AltText string `json:"altText"`
+
+	// Payload is the encoded text.
+	Payload string `json:"payload"`
+
+	// ContentType describes the kind of payload
+	// in words, such as "web address".
+	ContentType string `json:"contentType"`
+}
+
+// EncodeBundle encodes text at the given level and returns
+// the PNG image (with metadata; see PNGWithMetadata) together
+// with alt text and a description of the content.
+func EncodeBundle(text string, level Level) (*Bundle, error) {
+	c, err := Encode(text, level)
+	if err != nil {
+		return nil, err
+	}
+	kind, alt := describe(text)
+	return &Bundle{
+		PNG:         c.PNGWithMetadata(&PNGMetadata{Payload: text, Level: level}),
+		AltText:     alt,
+		Payload:     text,
+		ContentType: kind,
+	}, nil
+}
+
+// Sidecar returns the bundle's text fields as JSON,
+// for storing alongside the image.
+func (b *Bundle) Sidecar() ([]byte, error) {
+	return json.MarshalIndent(b, "", "\t")
+}
+
+// describe returns the content type and alt text for a payload.
+func describe(text string) (kind, alt string) {
+	lower := strings.ToLower(text)
+	after := func(prefix string) string {
+		return strings.TrimSpace(text[len(prefix):])
+	}
+	switch {
+	case strings.HasPrefix(lower, "http://"), strings.HasPrefix(lower, "https://"):
+		host := text
+		if u, err := url.Parse(text); err == nil && u.Host != "" {
+			host = u.Host
+		}
+		return "web address", "QR code linking to " + host
+	case strings.HasPrefix(lower, "mailto:"):
+		addr := after("mailto:")
+		if i := strings.Index(addr, "?"); i >= 0 {
+			addr = addr[:i]
+		}
+		return "email address", "QR code to send email to " + addr
+	case strings.HasPrefix(lower, "tel:"):
+		return "phone number", "QR code to call " + after("tel:")
+	case strings.HasPrefix(lower, "smsto:"), strings.HasPrefix(lower, "sms:"):
+		num := text[strings.Index(text, ":")+1:]
+		if i := strings.IndexAny(num, ":?"); i >= 0 {
+			num = num[:i]
+		}
+		return "text message", "QR code to send a text message to " + num
+	case strings.HasPrefix(lower, "wifi:"):
+		name := "a Wi-Fi network"
+		for _, f := range strings.Split(after("wifi:"), ";") {
+			if strings.HasPrefix(f, "S:") {
+				name = "Wi-Fi network " + f[2:]
+			}
+		}
+		return "Wi-Fi network settings", "QR code to join " + name
+	case strings.HasPrefix(lower, "begin:vcard"):
+		name := "a contact"
+		for _, line := range strings.Split(text, "\n") {
+			line = strings.TrimRight(line, "\r")
+			if strings.HasPrefix(strings.ToUpper(line), "FN:") {
+				name = line[3:]
+			}
+		}
+		return "contact card", "QR code with contact card for " + name
+	case strings.HasPrefix(lower, "geo:"):
+		return "location", "QR code with location " + after("geo:")
+	}
+	const max = 60
+	short := text
+	if r := []rune(text); len(r) > max {
+		short = string(r[:max]) + "…"
+	}
+	return "text", "QR code containing the text: " + short
+}
@@ -0,0 +1,55 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+var describeTests = []struct {
+	text, kind, alt string
+}{
+	{"http://swtch.com/qr", "web address", "QR code linking to swtch.com"},
+	{"mailto:rsc@swtch.com?subject=hi", "email address", "QR code to send email to rsc@swtch.com"},
+	{"tel:+15555550100", "phone number", "QR code to call +15555550100"},
+	{"SMSTO:5555550100:hello", "text message", "QR code to send a text message to 5555550100"},
+	{"WIFI:T:WPA;S:home;P:secret;;", "Wi-Fi network settings", "QR code to join Wi-Fi network home"},
+	{"BEGIN:VCARD\r\nVERSION:3.0\r\nFN:Ann Smith\r\nEND:VCARD", "contact card", "QR code with contact card for Ann Smith"},
+	{"geo:40.7,-74.0", "location", "QR code with location 40.7,-74.0"},
+	{"hello, world", "text", "QR code containing the text: hello, world"},
+	{strings.Repeat("é", 70), "text", "QR code containing the text: " + strings.Repeat("é", 60) + "…"},
+}
+
+func TestDescribe(t *testing.T) {
+	for _, tt := range describeTests {
+		kind, alt := describe(tt.text)
+		if kind != tt.kind || alt != tt.alt {
+			t.Errorf("describe(%q) = %q, %q, want %q, %q", tt.text, kind, alt, tt.kind, tt.alt)
+		}
+	}
+}
+
+func TestEncodeBundle(t *testing.T) {
+	b, err := EncodeBundle("http://swtch.com/qr", M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b.PNG), string(pngHeader)) {
+		t.Errorf("bundle PNG is not a PNG")
+	}
+	js, err := b.Sidecar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var side map[string]string
+	if err := json.Unmarshal(js, &side); err != nil {
+		t.Fatal(err)
+	}
+	if side["payload"] != "http://swtch.com/qr" || side["contentType"] != "web address" || side["altText"] != b.AltText || len(side) != 3 {
+		t.Errorf("sidecar = %s", js)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package art
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+
+	"code.google.com/p/rsc/qr"
+	"code.google.com/p/rsc/qr/coding"
+)
+
+// A Style gives the color of module (x, y) in frame i of an animation.
+// The black argument reports whether the module is dark in the code.
+// Coordinates outside [0, c.Size) belong to the 4-module quiet zone,
+// which is always light.
+type Style func(i, x, y int, black bool) color.Color
+
+// Animate writes to w an animated GIF of c with n frames,
+// each shown for delay hundredths of a second and colored by style.
+// Each frame may use at most 256 colors.
+//
+// Before writing anything, Animate reads every frame back the way
+// a scanner would, calling a module dark if its reflectance is
+// below one half (see Reflectance), and decodes the result.
+// If any frame fails to decode to the same data as c,
+// Animate returns an error naming the frame and writes nothing.
+func Animate(w io.Writer, c *qr.Code, n, delay int, style Style) error {
+	cc := &coding.Code{Bitmap: c.Bitmap, Size: c.Size, Stride: c.Stride}
+	want, err := coding.Decode(cc)
+	if err != nil {
+		return fmt.Errorf("art: cannot decode code: %v", err)
+	}
+
+	scale := c.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+	anim := &gif.GIF{}
+	for i := 0; i < n; i++ {
+		m, err := frame(c, i, scale, style)
+		if err != nil {
+			return err
+		}
+
+		got, err := readBack(m, c.Size, scale)
+		if err != nil {
+			return fmt.Errorf("art: frame %d: %v", i, err)
+		}
+		data, err := coding.Decode(got)
+		if err != nil {
+			return fmt.Errorf("art: frame %d does not decode: %v", i, err)
+		}
+		if !bytes.Equal(data, want) {
+			return fmt.Errorf("art: frame %d decodes to %q, want %q", i, data, want)
+		}
+
+		anim.Image = append(anim.Image, m)
+		anim.Delay = append(anim.Delay, delay)
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// readBack reads m, an image of a code size modules on a side
+// with scale pixels per module and a 4-module quiet zone, the way
+// a scanner would: a module is dark if the reflectance at its
+// center is below one half.
+func readBack(m image.Image, size, scale int) (*coding.Code, error) {
+	c := &coding.Code{Size: size, Stride: (size + 7) / 8}
+	c.Bitmap = make([]byte, c.Stride*c.Size)
+	b := m.Bounds()
+	d := size + 8
+	for y := 0; y < d; y++ {
+		for x := 0; x < d; x++ {
+			pt := image.Pt(b.Min.X+x*scale+scale/2, b.Min.Y+y*scale+scale/2)
+			if !pt.In(b) {
+				return nil, fmt.Errorf("image %v too small for code", b)
+			}
+			dark := Reflectance(m.At(pt.X, pt.Y)) < 0.5
+			qx, qy := x-4, y-4
+			if qx < 0 || qx >= size || qy < 0 || qy >= size {
+				if dark {
+					return nil, fmt.Errorf("dark module %d,%d in quiet zone", qx, qy)
+				}
+				continue
+			}
+			if dark {
+				c.Bitmap[qy*c.Stride+qx/8] |= 1 << uint(7-qx&7)
+			}
+		}
+	}
+	return c, nil
+}
+
+// frame draws frame i of an animation of c.
+func frame(c *qr.Code, i, scale int, style Style) (*image.Paletted, error) {
+	d := c.Size + 8
+	m := image.NewPaletted(image.Rect(0, 0, d*scale, d*scale), nil)
+	index := make(map[color.RGBA]uint8)
+	for y := 0; y < d; y++ {
+		for x := 0; x < d; x++ {
+			qx, qy := x-4, y-4
+			var col color.Color = color.White
+			if 0 <= qx && qx < c.Size && 0 <= qy && qy < c.Size {
+				col = style(i, qx, qy, c.Black(qx, qy))
+			}
+			r, g, b, a := col.RGBA()
+			rgba := color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)}
+			ci, ok := index[rgba]
+			if !ok {
+				if len(m.Palette) >= 256 {
+					return nil, fmt.Errorf("art: frame %d uses more than 256 colors", i)
+				}
+				ci = uint8(len(m.Palette))
+				index[rgba] = ci
+				m.Palette = append(m.Palette, rgba)
+			}
+			for yy := y * scale; yy < (y+1)*scale; yy++ {
+				row := m.Pix[yy*m.Stride:]
+				for xx := x * scale; xx < (x+1)*scale; xx++ {
+					row[xx] = ci
+				}
+			}
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,620 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package art generates QR codes whose pixels approximate a target image.
+
+The encoded text is a URL followed by a "#" and a run of decimal digits.
+A QR scanner sees an ordinary URL (the fragment is ignored by web servers),
+but the digits can be chosen freely.  Together with the linearity of the
+Reed-Solomon check bytes, that freedom lets the encoder pick the color of
+most data and check pixels.  The technique is described at
+http://research.swtch.com/qart.
+*/
+package art
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"math"
+	"math/rand"
+	"sort"
+
+	"code.google.com/p/rsc/gf256"
+	"code.google.com/p/rsc/qr"
+	"code.google.com/p/rsc/qr/coding"
+)
+
+// An Image describes a QR code to be drawn to look like a target image.
+//
+// Encode is deterministic: an Image with the same fields always
+// produces the same code, so the scalar fields (Dx, Dy, Size,
+// Version, Level, Mask, Rotation, Seed, and the flags) can be
+// saved alongside the URL and source image to reproduce a result
+// or to tweak it one control at a time.
+type Image struct {
+	// Target holds gray levels from 0 (black) to 255 (white),
+	// indexed as Target[y][x].  A value of -1 marks a transparent
+	// pixel whose color does not matter.  See MakeTarget.
+	Target [][]int
+
+	// Source is the image to approximate when Target is nil.
+	// Encode scales it with MakeTarget to fit in a square
+	// 17+4*Version+Size pixels on a side, one pixel per module:
+	// a positive Size makes the image larger than the code,
+	// and a negative Size makes it smaller.
+	Source image.Image
+	Size   int
+
+	// Dx and Dy give the offset of the code's upper left pixel in Target.
+	// Together with Size they position the image behind the code.
+	Dx int
+	Dy int
+
+	URL     string
+	Version coding.Version
+	Level   coding.Level
+	Mask    coding.Mask // Mask0 to Mask7; MaskAuto is not allowed
+	Scale   int         // number of image pixels per QR pixel; 0 means 8
+
+	// Rotation is the number of quarter turns to rotate the code
+	// counterclockwise before fitting it to the target.
+	// It determines which corner lacks a position box:
+	// 0 bottom right, 1 top right, 2 top left, 3 bottom left.
+	Rotation int
+
+	// Seed seeds the random tie-breaking between pixels of
+	// equal priority and, if RandControl is set, the random choice
+	// of pixels.  Changing it gives a different arrangement
+	// of the pixels the encoder cannot control.
+	Seed int64
+
+	// RandControl says to pick the pixels randomly.
+	RandControl bool
+
+	// Dither says to dither instead of using threshold pixel layout.
+	Dither bool
+
+	// OnlyDataBits says to use only data bits, not check bits.
+	OnlyDataBits bool
+
+	// Saliency, if not nil, gives the importance of each target
+	// pixel, from 0 (unimportant) to 255, indexed like Target.
+	// The encoder runs out of freely choosable bits long before
+	// it runs out of pixels, so it spends them on the most salient
+	// pixels first, breaking ties by local contrast.
+	// If Saliency is nil, local contrast alone decides.
+	// See MakeSaliency.
+	Saliency [][]int
+
+	// Params adjusts the mapping from gray levels to pixels.
+	// If Params is nil, Encode uses DefaultParams.
+	Params *Params
+
+	// Debug, if not nil, receives intermediate images from Encode.
+	Debug *Debug
+
+	// LogoSize, if positive, is the fraction of the code's width
+	// covered by a centered square logo to be drawn over the code
+	// after encoding.  The logo is assumed to read as light, or as
+	// dark if LogoDark is set.  Encode steers enough of the covered
+	// pixels to the logo's color that the code still decodes,
+	// leaving fewer free pixels for the image.  See LogoBudget.
+	LogoSize float64
+	LogoDark bool
+}
+
+// Params holds the tuning knobs for the mapping from target gray
+// levels to QR pixels.  The result is sensitive to them, and the
+// best values depend on the image, so expect to experiment.
+type Params struct {
+	// Gamma is applied to target gray levels g before
+	// any other processing: g' = 255*(g/255)^Gamma.
+	// Values above 1 darken mid-tones; values below 1 lighten them.
+	Gamma float64
+
+	// Black and White are gray level thresholds.
+	// Pixels at or below Black want to be black,
+	// and pixels at or above White want to be white.
+	// Pixels strictly between the two are mid-tones:
+	// they are drawn black below the midpoint and white above it,
+	// but they are only given free bits after all other pixels.
+	Black int
+	White int
+
+	// DitherStrength scales the error carried from each pixel
+	// to its neighbor when Image.Dither is set.  At 0 dithering
+	// has no effect; at 1 it carries the usual 7/16.
+	DitherStrength float64
+}
+
+// DefaultParams are the parameters used when Image.Params is nil.
+var DefaultParams = Params{
+	Gamma:          1,
+	Black:          127,
+	White:          128,
+	DitherStrength: 1,
+}
+
+// gray returns the gray level g adjusted by the gamma setting.
+func (p *Params) gray(g byte) byte {
+	if p.Gamma == 1 || p.Gamma <= 0 {
+		return g
+	}
+	return byte(255*math.Pow(float64(g)/255, p.Gamma) + 0.5)
+}
+
+// black reports whether gray level g should be drawn black.
+func (p *Params) black(g int) bool {
+	return 2*g < p.Black+p.White+1
+}
+
+// midtone reports whether gray level g is a mid-tone.
+func (p *Params) midtone(g byte) bool {
+	return p.Black < int(g) && int(g) < p.White
+}
+
+// A pixinfo records what is known about a single data or check pixel.
+type pixinfo struct {
+	x        int
+	y        int
+	pix      coding.Pixel
+	targ     byte
+	dtarg    int
+	contrast int
+	hardZero bool
+	steer    bool // must match the logo
+	block    *bitBlock
+	bit      uint
+	dval     int // gray value chosen by dithering
+}
+
+type pixorder struct {
+	off      int
+	priority int
+	rnd      int // random tie-breaker
+}
+
+type byPriority []pixorder
+
+func (x byPriority) Len() int      { return len(x) }
+func (x byPriority) Swap(i, j int) { x[i], x[j] = x[j], x[i] }
+func (x byPriority) Less(i, j int) bool {
+	if x[i].priority != x[j].priority {
+		return x[i].priority > x[j].priority
+	}
+	return x[i].rnd > x[j].rnd
+}
+
+// target returns the target gray level for pixel (x, y)
+// and the contrast in the surrounding region.
+// The contrast is -1 for pixels outside the target
+// or on transparent target pixels.
+func (m *Image) target(x, y int) (targ byte, contrast int) {
+	tx := x + m.Dx
+	ty := y + m.Dy
+	if ty < 0 || ty >= len(m.Target) || tx < 0 || tx >= len(m.Target[ty]) {
+		return 255, -1
+	}
+
+	v0 := m.Target[ty][tx]
+	if v0 < 0 {
+		return 255, -1
+	}
+	targ = byte(v0)
+
+	n := 0
+	sum := 0
+	sumsq := 0
+	const del = 5
+	for dy := -del; dy <= del; dy++ {
+		for dx := -del; dx <= del; dx++ {
+			if 0 <= ty+dy && ty+dy < len(m.Target) && 0 <= tx+dx && tx+dx < len(m.Target[ty+dy]) {
+				v := m.Target[ty+dy][tx+dx]
+				sum += v
+				sumsq += v * v
+				n++
+			}
+		}
+	}
+
+	avg := sum / n
+	contrast = sumsq/n - avg*avg
+	return
+}
+
+// saliency returns the saliency of pixel (x, y), from 0 to 255.
+func (m *Image) saliency(x, y int) int {
+	tx := x + m.Dx
+	ty := y + m.Dy
+	if ty < 0 || ty >= len(m.Saliency) || tx < 0 || tx >= len(m.Saliency[ty]) {
+		return 0
+	}
+	s := m.Saliency[ty][tx]
+	if s < 0 {
+		s = 0
+	}
+	if s > 255 {
+		s = 255
+	}
+	return s
+}
+
+// digitBits returns the range [bbit, mbit) of data bit offsets
+// in p that hold the freely chosen digits following url.
+func digitBits(url string, p *coding.Plan) (bbit, mbit int, err error) {
+	var b coding.Bits
+	coding.String(url).Encode(&b, p.Version)
+	coding.Num("").Encode(&b, p.Version)
+	bbit = b.Bits()
+	dbit := p.DataBytes*8 - bbit
+	if dbit < 0 {
+		return 0, 0, errors.New("art: cannot encode URL into available bits")
+	}
+	return bbit, bbit + dbit/10*10, nil
+}
+
+// rotate rotates the plan p by rot quarter turns.
+func rotate(p *coding.Plan, rot int) {
+	N := len(p.Pixel)
+	pix := make([][]coding.Pixel, N)
+	apix := make([]coding.Pixel, N*N)
+	for i := range pix {
+		pix[i], apix = apix[:N], apix[N:]
+	}
+
+	switch rot & 3 {
+	case 0:
+		return
+	case 1:
+		for y := 0; y < N; y++ {
+			for x := 0; x < N; x++ {
+				pix[y][x] = p.Pixel[x][N-1-y]
+			}
+		}
+	case 2:
+		for y := 0; y < N; y++ {
+			for x := 0; x < N; x++ {
+				pix[y][x] = p.Pixel[N-1-y][N-1-x]
+			}
+		}
+	case 3:
+		for y := 0; y < N; y++ {
+			for x := 0; x < N; x++ {
+				pix[y][x] = p.Pixel[N-1-x][y]
+			}
+		}
+	}
+
+	p.Pixel = pix
+}
+
+// plan returns the plan for m's version, level, and mask,
+// rotated by m.Rotation.  The pixel choices depend on which pixels
+// the mask inverts, so m.Mask must be one of the eight masks:
+// with MaskAuto, the encoder would pick a different mask afterward
+// and scramble the picture.
+func (m *Image) plan() (*coding.Plan, error) {
+	if m.Mask == coding.MaskAuto {
+		return nil, errors.New("art: Mask must be a fixed mask, not MaskAuto")
+	}
+	p, err := coding.NewPlan(m.Version, m.Level, m.Mask)
+	if err != nil {
+		return nil, err
+	}
+	rotate(p, m.Rotation)
+	return p, nil
+}
+
+// Encode returns a QR code encoding m.URL whose
+// pixels approximate m.Target.
+func (m *Image) Encode() (*qr.Code, error) {
+	p, err := m.plan()
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Target == nil && m.Source != nil {
+		mm := *m
+		mm.Target = MakeTarget(m.Source, len(p.Pixel)+m.Size)
+		m = &mm
+	}
+
+	params := m.Params
+	if params == nil {
+		params = &DefaultParams
+	}
+
+	rand := rand.New(rand.NewSource(m.Seed))
+
+	// QR parameters.
+	nd0 := p.DataBytes / p.Blocks
+	nc := p.CheckBytes / p.Blocks
+	extra := p.DataBytes - nd0*p.Blocks
+	rs := gf256.NewRSEncoder(coding.Field, nc)
+
+	// Build information about pixels, indexed by data/check bit number.
+	pixByOff := make([]pixinfo, (p.DataBytes+p.CheckBytes)*8)
+	for y, row := range p.Pixel {
+		for x, pix := range row {
+			targ, contrast := m.target(x, y)
+			if m.RandControl && contrast >= 0 {
+				contrast = rand.Intn(128) + 64*((x+y)%2) + 64*((x+y)%3%2)
+			}
+			if m.Saliency != nil && contrast >= 0 {
+				if contrast > 0xffff {
+					contrast = 0xffff
+				}
+				contrast |= m.saliency(x, y) << 16
+			}
+			targ = params.gray(targ)
+			switch {
+			case contrast < 0:
+				// Outside target: try last.
+				contrast = -1 << 25
+			case params.midtone(targ):
+				contrast -= 1 << 24
+			}
+			if r := pix.Role(); r == coding.Data || r == coding.Check {
+				pixByOff[pix.Offset()] = pixinfo{x: x, y: y, pix: pix, targ: targ, contrast: contrast}
+			}
+		}
+	}
+
+	// Reserve pixels covered by the logo.
+	if m.LogoSize > 0 {
+		lb, steer, err := m.solveLogo(p)
+		if err != nil {
+			if err, ok := err.(*LogoError); ok {
+				err.Suggestions = m.logoSuggestions()
+			}
+			return nil, err
+		}
+		targ := byte(255)
+		if m.LogoDark {
+			targ = 0
+		}
+		for y := lb.Rect.Min.Y; y < lb.Rect.Max.Y; y++ {
+			for x := lb.Rect.Min.X; x < lb.Rect.Max.X; x++ {
+				pix := p.Pixel[y][x]
+				if r := pix.Role(); r != coding.Data && r != coding.Check {
+					continue
+				}
+				pinfo := &pixByOff[pix.Offset()]
+				pinfo.targ = targ
+				if steer[pix.Offset()] {
+					pinfo.steer = true
+					pinfo.contrast = 1 << 29
+				} else {
+					// Hidden by the logo: try last.
+					pinfo.contrast = -1 << 26
+				}
+			}
+		}
+	}
+
+	// Count fixed initial data bits, prepare template URL.
+	url := m.URL + "#"
+	bbit, mbit, err := digitBits(url, p)
+	if err != nil {
+		return nil, err
+	}
+	dbit := mbit - bbit
+	num := make([]byte, dbit/10*3)
+	var b coding.Bits
+
+Again:
+	for i := range num {
+		num[i] = '0'
+	}
+	for i := range pixByOff {
+		pixByOff[i].block = nil
+	}
+	b.Reset()
+	coding.String(url).Encode(&b, p.Version)
+	coding.Num(num).Encode(&b, p.Version)
+	b.AddCheckBytes(p.Version, p.Level)
+	data := b.Bytes()
+
+	doff := 0 // data offset
+	coff := 0 // checksum offset
+
+	// Choose pixels.
+	bitblocks := make([]*bitBlock, p.Blocks)
+	for blocknum := 0; blocknum < p.Blocks; blocknum++ {
+		nd := nd0
+		if blocknum >= p.Blocks-extra {
+			nd++
+		}
+
+		bdata := data[doff/8 : doff/8+nd]
+		cdata := data[p.DataBytes+coff/8 : p.DataBytes+coff/8+nc]
+		bb := newBlock(nd, nc, rs, bdata, cdata)
+		bitblocks[blocknum] = bb
+
+		// Determine which bits in this block we can try to edit.
+		lo, hi := blockRange(doff, nd, bbit, mbit)
+
+		// Preserve [0, lo) and [hi, nd*8).
+		for i := 0; i < lo; i++ {
+			if !bb.canSet(uint(i), (bdata[i/8]>>uint(7-i&7))&1) {
+				return nil, errors.New("art: cannot preserve required bits")
+			}
+		}
+		for i := hi; i < nd*8; i++ {
+			if !bb.canSet(uint(i), (bdata[i/8]>>uint(7-i&7))&1) {
+				return nil, errors.New("art: cannot preserve required bits")
+			}
+		}
+
+		// Can edit [lo, hi) and checksum bits to hit target.
+		// Determine which ones to try first.
+		order := make([]pixorder, (hi-lo)+nc*8)
+		for i := lo; i < hi; i++ {
+			order[i-lo].off = doff + i
+		}
+		for i := 0; i < nc*8; i++ {
+			order[hi-lo+i].off = p.DataBytes*8 + coff + i
+		}
+		if m.OnlyDataBits {
+			order = order[:hi-lo]
+		}
+		for i := range order {
+			po := &order[i]
+			po.priority = pixByOff[po.off].contrast
+			po.rnd = rand.Intn(256)
+		}
+		sort.Sort(byPriority(order))
+
+		for i := range order {
+			po := &order[i]
+			pinfo := &pixByOff[po.off]
+			bval := byte(0)
+			if params.black(int(pinfo.targ)) {
+				bval = 1
+			}
+			pix := pinfo.pix
+			if pix&coding.Invert != 0 {
+				bval ^= 1
+			}
+			if pinfo.hardZero {
+				bval = 0
+			}
+
+			var bi int
+			if pix.Role() == coding.Data {
+				bi = po.off - doff
+			} else {
+				bi = po.off - p.DataBytes*8 - coff + nd*8
+			}
+			if bb.canSet(uint(bi), bval) {
+				pinfo.block = bb
+				pinfo.bit = uint(bi)
+			} else if pinfo.hardZero {
+				return nil, errors.New("art: cannot clear digit bit")
+			} else if pinfo.steer {
+				return nil, errors.New("art: cannot steer pixel under logo")
+			}
+		}
+		bb.copyOut()
+
+		doff += nd * 8
+		coff += nc * 8
+	}
+
+	// Pass over all pixels again, dithering.
+	if m.Dither {
+		for i := range pixByOff {
+			pinfo := &pixByOff[i]
+			pinfo.dtarg = int(pinfo.targ)
+		}
+		for _, row := range p.Pixel {
+			for x, pix := range row {
+				if pix.Role() != coding.Data && pix.Role() != coding.Check {
+					continue
+				}
+				pinfo := &pixByOff[pix.Offset()]
+				if pinfo.block == nil || pinfo.steer {
+					// did not choose this pixel, or must not change it
+					continue
+				}
+
+				pix := pinfo.pix
+
+				pval := byte(1) // pixel value (black)
+				v := 0          // gray value (black)
+				targ := pinfo.dtarg
+				if !params.black(targ) {
+					// want white
+					pval = 0
+					v = 255
+				}
+
+				bval := pval // bit value
+				if pix&coding.Invert != 0 {
+					bval ^= 1
+				}
+				if pinfo.hardZero && bval != 0 {
+					bval ^= 1
+					pval ^= 1
+					v ^= 255
+				}
+
+				// Set pixel value as we want it.
+				pinfo.block.reset(pinfo.bit, bval)
+				pinfo.dval = v
+
+				err := targ - v
+				if x+1 < len(row) {
+					addDither(pixByOff, row[x+1], int(float64(err*7/16)*params.DitherStrength))
+				}
+			}
+		}
+
+		for _, bb := range bitblocks {
+			bb.copyOut()
+		}
+	}
+
+	noops := 0
+	// Copy numbers back out.
+	for i := 0; i < dbit/10; i++ {
+		// Pull out 10 bits.
+		v := 0
+		for j := 0; j < 10; j++ {
+			bi := uint(bbit + 10*i + j)
+			v <<= 1
+			v |= int((data[bi/8] >> (7 - bi&7)) & 1)
+		}
+		// Turn into 3 digits.
+		if v >= 1000 {
+			// Oops - too many 1 bits.
+			// We know the 512, 256, 128, 64, 32 bits are all set.
+			// Force the 64 bit to zero and try again.
+			// This will break some checksum bits, but so be it.
+			pinfo := &pixByOff[bbit+10*i+3]
+			pinfo.contrast = 1 << 30
+			pinfo.hardZero = true
+			noops++
+		}
+		num[i*3+0] = byte(v/100 + '0')
+		num[i*3+1] = byte(v/10%10 + '0')
+		num[i*3+2] = byte(v%10 + '0')
+	}
+	if noops > 0 {
+		goto Again
+	}
+
+	var b1 coding.Bits
+	coding.String(url).Encode(&b1, p.Version)
+	coding.Num(num).Encode(&b1, p.Version)
+	b1.AddCheckBytes(p.Version, p.Level)
+	if !bytes.Equal(b.Bytes(), b1.Bytes()) {
+		return nil, errors.New("art: internal error: byte mismatch")
+	}
+
+	cc, err := p.Encode(coding.String(url), coding.Num(num))
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Debug != nil {
+		m.Debug.record(m, params, p, pixByOff, cc)
+	}
+
+	scale := m.Scale
+	if scale == 0 {
+		scale = 8
+	}
+	return &qr.Code{Bitmap: cc.Bitmap, Size: cc.Size, Stride: cc.Stride, Scale: scale}, nil
+}
+
+func addDither(pixByOff []pixinfo, pix coding.Pixel, err int) {
+	if pix.Role() != coding.Data && pix.Role() != coding.Check {
+		return
+	}
+	pinfo := &pixByOff[pix.Offset()]
+	pinfo.dtarg += err
+}
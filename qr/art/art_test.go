@@ -0,0 +1,936 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package art
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"strings"
+	"testing"
+
+	"code.google.com/p/rsc/gf256"
+	"code.google.com/p/rsc/qr"
+	"code.google.com/p/rsc/qr/coding"
+)
+
+// disk returns a size×size target showing a black disk on white.
+func disk(size int) [][]int {
+	t := make([][]int, size)
+	r := size / 3
+	for y := range t {
+		t[y] = make([]int, size)
+		for x := range t[y] {
+			dx, dy := x-size/2, y-size/2
+			if dx*dx+dy*dy < r*r {
+				t[y][x] = 0
+			} else {
+				t[y][x] = 255
+			}
+		}
+	}
+	return t
+}
+
+// readBytes returns the data and check bytes stored in c,
+// which must have been laid out according to p.
+func readBytes(p *coding.Plan, c *qr.Code) []byte {
+	b := make([]byte, p.DataBytes+p.CheckBytes)
+	for y, row := range p.Pixel {
+		for x, pix := range row {
+			if r := pix.Role(); r != coding.Data && r != coding.Check {
+				continue
+			}
+			if c.Black(x, y) != (pix&coding.Black != 0) {
+				o := pix.Offset()
+				b[o/8] |= 1 << (7 - o&7)
+			}
+		}
+	}
+	return b
+}
+
+// readDigits parses the byte and numeric segments
+// written by Encode and returns the text they hold.
+func readDigits(t *testing.T, v coding.Version, data []byte) (url, num string) {
+	bit := 0
+	get := func(n int) int {
+		x := 0
+		for i := 0; i < n; i++ {
+			x = x<<1 | int(data[bit/8]>>(7-uint(bit&7))&1)
+			bit++
+		}
+		return x
+	}
+	if mode := get(4); mode != 4 {
+		t.Fatalf("first segment mode = %d, want 4", mode)
+	}
+	var buf []byte
+	for n := get(8); n > 0; n-- {
+		buf = append(buf, byte(get(8)))
+	}
+	url = string(buf)
+	if mode := get(4); mode != 1 {
+		t.Fatalf("second segment mode = %d, want 1", mode)
+	}
+	buf = buf[:0]
+	for n := get(10); n > 0; n -= 3 {
+		w := get(10)
+		if w >= 1000 {
+			t.Fatalf("invalid numeric group %d", w)
+		}
+		buf = append(buf, byte('0'+w/100), byte('0'+w/10%10), byte('0'+w%10))
+	}
+	return url, string(buf)
+}
+
+func TestEncode(t *testing.T) {
+	const v = 6
+	const url = "http://swtch.com/qr"
+	size := 17 + 4*v
+	targ := disk(size)
+	for _, dither := range []bool{false, true} {
+		m := &Image{
+			Target:  targ,
+			URL:     url,
+			Version: v,
+			Level:   coding.L,
+			Mask:    2,
+			Dither:  dither,
+			Debug:   new(Debug),
+		}
+		c, err := m.Encode()
+		if err != nil {
+			t.Fatalf("Encode (dither=%v): %v", dither, err)
+		}
+		if c.Size != size || c.Scale != 8 {
+			t.Fatalf("Encode (dither=%v): size %d, scale %d, want %d, 8", dither, c.Size, c.Scale, size)
+		}
+
+		p, err := coding.NewPlan(v, coding.L, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, num := readDigits(t, v, readBytes(p, c))
+		if u != url+"#" {
+			t.Fatalf("Encode (dither=%v): URL %q, want %q", dither, u, url+"#")
+		}
+		cc, err := p.Encode(coding.String(u), coding.Num(num))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(cc.Bitmap, c.Bitmap) {
+			t.Fatalf("Encode (dither=%v): bitmap is not a valid encoding of %q", dither, u+num)
+		}
+
+		// Most of the controllable pixels should match the target.
+		match, total := 0, 0
+		for y, row := range p.Pixel {
+			for x, pix := range row {
+				if r := pix.Role(); r != coding.Data && r != coding.Check {
+					continue
+				}
+				total++
+				if c.Black(x, y) == (targ[y][x] < 128) {
+					match++
+				}
+			}
+		}
+		if !dither && match < total*3/4 {
+			t.Errorf("Encode: only %d of %d data pixels match target", match, total)
+		}
+		if d := m.Debug; d.Match+d.Mismatch != size*size || d.Controlled == 0 || d.Controlled > total {
+			t.Errorf("Encode (dither=%v): Debug reports %d match, %d mismatch, %d controlled", dither, d.Match, d.Mismatch, d.Controlled)
+		}
+	}
+}
+
+func TestHalftone(t *testing.T) {
+	c, err := qr.Encode("http://swtch.com/qr", qr.M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Scale = 1
+	m, err := Halftone(c, disk(3*c.Size), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := 3 * (c.Size + 8); m.Bounds().Dx() != d || m.Bounds().Dy() != d {
+		t.Fatalf("Halftone: image is %v, want %dx%d", m.Bounds(), d, d)
+	}
+	black := func(x, y int) bool { return m.GrayAt(x+12, y+12).Y == 0 }
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if black(3*x+1, 3*y+1) != c.Black(x, y) {
+				t.Fatalf("Halftone: center of module (%d, %d) has wrong color", x, y)
+			}
+		}
+	}
+	// The upper left position box must be solid.
+	for y := 0; y < 3*7; y++ {
+		for x := 0; x < 3*7; x++ {
+			if black(x, y) != c.Black(x/3, y/3) {
+				t.Fatalf("Halftone: position box subcell (%d, %d) has wrong color", x, y)
+			}
+		}
+	}
+}
+
+func TestSaliency(t *testing.T) {
+	const v = 6
+	size := 17 + 4*v
+	targ := make([][]int, size)
+	for y := range targ {
+		targ[y] = make([]int, size)
+		for x := range targ[y] {
+			targ[y][x] = 255 * ((x/2 + y/2) % 2) // checkerboard is hard to match
+		}
+	}
+
+	// matchTop returns the fraction of data pixels in the top
+	// half of the code that match the target when the
+	// saliency map favors the top half (top=true) or bottom half.
+	matchTop := func(top bool) float64 {
+		sal := make([][]int, size)
+		for y := range sal {
+			sal[y] = make([]int, size)
+			for x := range sal[y] {
+				if (y < size/2) == top {
+					sal[y][x] = 255
+				}
+			}
+		}
+		m := &Image{Target: targ, URL: "http://swtch.com/qr", Version: v, Level: coding.L, Saliency: sal}
+		c, err := m.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, _ := coding.NewPlan(v, coding.L, 0)
+		match, total := 0, 0
+		for y, row := range p.Pixel[:size/2] {
+			for x, pix := range row {
+				if r := pix.Role(); r == coding.Data || r == coding.Check {
+					total++
+					if c.Black(x, y) == (targ[y][x] < 128) {
+						match++
+					}
+				}
+			}
+		}
+		return float64(match) / float64(total)
+	}
+
+	if fav, unfav := matchTop(true), matchTop(false); fav <= unfav {
+		t.Errorf("favored region matches %.2f of target, unfavored %.2f", fav, unfav)
+	}
+}
+
+func TestColorize(t *testing.T) {
+	c, err := qr.Encode("http://swtch.com/qr", qr.M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Scale = 2
+
+	// A mid-gray orange source has no usable contrast by itself.
+	orange := image.NewUniform(color.RGBA{0xc0, 0x80, 0x40, 0xff})
+	src := image.NewRGBA(image.Rect(0, 0, 200, 200))
+	draw.Draw(src, src.Bounds(), orange, image.ZP, draw.Src)
+	if err := CheckContrast(src, c, 0.1); err == nil {
+		t.Errorf("CheckContrast accepted uniform image")
+	}
+	for _, contrast := range []float64{0.4, 0.6, 0.9} {
+		m, err := Colorize(c, src, contrast)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := CheckContrast(m, c, contrast); err != nil {
+			t.Errorf("Colorize(%v): %v", contrast, err)
+		}
+	}
+
+	// A zero Scale means one pixel per module, as in Theme.Image.
+	c.Scale = 0
+	m, err := Colorize(c, src, 0.6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := m.Bounds().Dx(); d != c.Size+8 {
+		t.Errorf("Colorize with Scale 0: width %d, want %d", d, c.Size+8)
+	}
+	if err := CheckContrast(m, c, 0.6); err != nil {
+		t.Errorf("CheckContrast with Scale 0: %v", err)
+	}
+}
+
+func TestReproducible(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			src.SetGray(x, y, color.Gray{uint8(4 * x)})
+		}
+	}
+	enc := func(seed int64, rot int) []byte {
+		m := &Image{Source: src, Size: 4, Dx: 2, Dy: 2, URL: "http://swtch.com/qr", Version: 4, Rotation: rot, Seed: seed, RandControl: true}
+		c, err := m.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c.Bitmap
+	}
+	if !bytes.Equal(enc(1, 1), enc(1, 1)) {
+		t.Errorf("Encode is not deterministic")
+	}
+	if bytes.Equal(enc(1, 1), enc(2, 1)) {
+		t.Errorf("Encode ignores Seed")
+	}
+	if bytes.Equal(enc(1, 1), enc(1, 2)) {
+		t.Errorf("Encode ignores Rotation")
+	}
+}
+
+func TestAnimate(t *testing.T) {
+	c, err := qr.Encode("http://swtch.com/qr", qr.Q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Scale = 2
+	hues := []color.RGBA{{0x80, 0, 0, 0xff}, {0, 0x60, 0, 0xff}, {0, 0, 0x80, 0xff}}
+	good := func(i, x, y int, black bool) color.Color {
+		if black {
+			return hues[(i+x+y)%len(hues)]
+		}
+		return color.RGBA{0xff, 0xff, 0xe0, 0xff}
+	}
+	var buf bytes.Buffer
+	if err := Animate(&buf, c, 3, 10, good); err != nil {
+		t.Fatal(err)
+	}
+	g, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Image) != 3 {
+		t.Fatalf("Animate wrote %d frames, want 3", len(g.Image))
+	}
+
+	// Washing out the dark modules in frame 1 must be caught.
+	bad := func(i, x, y int, black bool) color.Color {
+		if i == 1 && black && x > 8 && y > 8 {
+			return color.RGBA{0xc0, 0xc0, 0xc0, 0xff}
+		}
+		return good(i, x, y, black)
+	}
+	buf.Reset()
+	err = Animate(&buf, c, 3, 10, bad)
+	if err == nil || !strings.Contains(err.Error(), "frame 1") {
+		t.Errorf("Animate with bad frame 1: err = %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Animate with bad frame wrote %d bytes", buf.Len())
+	}
+}
+
+func TestBudget(t *testing.T) {
+	m := &Image{URL: "http://swtch.com/qr", Version: 6, Level: coding.L}
+	b, err := m.Budget()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := coding.NewPlan(6, coding.L, 0)
+	if b.Fixed+b.Free != p.DataBytes*8 {
+		t.Errorf("Fixed+Free = %d+%d, want %d data bits", b.Fixed, b.Free, p.DataBytes*8)
+	}
+	if b.Candidates != b.Free+p.CheckBytes*8 {
+		t.Errorf("Candidates = %d, want %d", b.Candidates, b.Free+p.CheckBytes*8)
+	}
+	n := 0
+	for _, row := range b.Candidate {
+		for _, c := range row {
+			if c {
+				n++
+			}
+		}
+	}
+	if n != b.Candidates {
+		t.Errorf("Candidate map has %d pixels, want %d", n, b.Candidates)
+	}
+
+	m.OnlyDataBits = true
+	if b, err = m.Budget(); err != nil || b.Candidates != b.Free {
+		t.Errorf("OnlyDataBits: Candidates = %d, Free = %d, want equal", b.Candidates, b.Free)
+	}
+}
+
+func TestMaskAuto(t *testing.T) {
+	m := &Image{URL: "http://swtch.com/qr", Version: 6, Level: coding.L, Mask: coding.MaskAuto, LogoSize: 0.2}
+	if _, err := m.Encode(); err == nil {
+		t.Errorf("Encode with MaskAuto succeeded")
+	}
+	if _, err := m.Budget(); err == nil {
+		t.Errorf("Budget with MaskAuto succeeded")
+	}
+	if _, err := m.LogoBudget(); err == nil {
+		t.Errorf("LogoBudget with MaskAuto succeeded")
+	}
+}
+
+func TestLogo(t *testing.T) {
+	const v = 6
+	size := 17 + 4*v
+	m := &Image{
+		Target:   disk(size),
+		URL:      "http://swtch.com/qr",
+		Version:  v,
+		Level:    coding.L,
+		LogoSize: 0.4,
+	}
+	lb, err := m.LogoBudget()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := m.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Draw the logo and count the damaged codewords in each block.
+	p, _ := coding.NewPlan(v, coding.L, 0)
+	before := readBytes(p, c)
+	for y := lb.Rect.Min.Y; y < lb.Rect.Max.Y; y++ {
+		for x := lb.Rect.Min.X; x < lb.Rect.Max.X; x++ {
+			c.Bitmap[y*c.Stride+x/8] &^= 1 << uint(7-x&7)
+		}
+	}
+	after := readBytes(p, c)
+	nd, nc := p.DataBytes/p.Blocks, p.CheckBytes/p.Blocks
+	for i, blk := range lb.Blocks {
+		bad := 0
+		for j := 0; j < nd; j++ {
+			if before[i*nd+j] != after[i*nd+j] {
+				bad++
+			}
+		}
+		for j := 0; j < nc; j++ {
+			if k := p.DataBytes + i*nc + j; before[k] != after[k] {
+				bad++
+			}
+		}
+		if blk.Steered == 0 || bad > blk.Correctable || bad > blk.Damaged-blk.Steered {
+			t.Errorf("block %d: logo damages %d codewords; budget says %d damaged, %d steered, %d correctable",
+				i, bad, blk.Damaged, blk.Steered, blk.Correctable)
+		}
+	}
+
+	// At level Q the URL fills the first block, leaving nothing
+	// to steer with, but level H has enough check bytes.
+	m.Level = coding.Q
+	_, err = m.LogoBudget()
+	lerr, ok := err.(*LogoError)
+	if !ok {
+		t.Fatalf("LogoBudget at level Q: err = %v, want *LogoError", err)
+	}
+	if len(lerr.Suggestions) == 0 || lerr.Suggestions[0] != (LogoSuggestion{v, coding.H}) {
+		t.Errorf("LogoBudget at level Q: suggestions %v", lerr.Suggestions)
+	}
+}
+
+func TestTheme(t *testing.T) {
+	c, err := qr.Encode("http://swtch.com/qr", qr.M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Scale = 4
+	dark := 0
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.Black(x, y) {
+				dark++
+			}
+		}
+	}
+	for _, th := range Themes {
+		if LookupTheme(th.Name) != th {
+			t.Errorf("LookupTheme(%q) failed", th.Name)
+		}
+		m, err := th.Image(c, 0)
+		if err != nil {
+			t.Errorf("theme %s: %v", th.Name, err)
+			continue
+		}
+		if err := CheckContrast(m, c, 0.4); err != nil {
+			t.Errorf("theme %s: %v", th.Name, err)
+		}
+		var buf bytes.Buffer
+		if err := th.SVG(&buf, c, 0); err != nil {
+			t.Errorf("theme %s: SVG: %v", th.Name, err)
+			continue
+		}
+		svg := buf.String()
+		if !strings.HasPrefix(svg, "<svg ") || !strings.HasSuffix(svg, "</svg>\n") {
+			t.Errorf("theme %s: malformed SVG", th.Name)
+		}
+		if n := strings.Count(svg, "/>\n") - 1; n != dark {
+			t.Errorf("theme %s: SVG has %d dark modules, want %d", th.Name, n, dark)
+		}
+	}
+
+	c.Scale = 2
+	if _, err := LookupTheme("dots").Image(c, 0); err == nil {
+		t.Errorf("dots theme at scale 2 succeeded")
+	}
+	c.Scale = 4
+	gray := &Theme{Name: "gray", Dark: color.Gray{0x60}, Light: color.Gray{0x90}}
+	if _, err := gray.Image(c, 0); err == nil {
+		t.Errorf("low-contrast theme succeeded")
+	}
+}
+
+func TestEstimateScannability(t *testing.T) {
+	c, err := qr.Encode("http://swtch.com/qr", qr.M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Scale = 4
+	img, err := png.Decode(bytes.NewReader(c.PNG()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt := &ScanOptions{ModuleSize: 0.5, Distance: 300}
+	r := EstimateScannability(img, c, opt)
+	if r.Risky() {
+		t.Errorf("plain code is risky: %v", r.Problems)
+	}
+	if r.ModulePixels != 4 || r.QuietZone != 4 || r.Contrast < 0.99 {
+		t.Errorf("plain code: ModulePixels=%v QuietZone=%d Contrast=%v, want 4, 4, 1",
+			r.ModulePixels, r.QuietZone, r.Contrast)
+	}
+	if r.CameraPixels < 2 || r.CameraPixels > 3 {
+		t.Errorf("plain code: CameraPixels=%v, want about 2.5", r.CameraPixels)
+	}
+	if r.Score <= 0 || r.Score > 1 {
+		t.Errorf("plain code: Score=%v", r.Score)
+	}
+
+	// Cropping away the quiet zone is a problem.
+	crop := image.NewGray(image.Rect(0, 0, (c.Size+2)*4, (c.Size+2)*4))
+	draw.Draw(crop, crop.Bounds(), img, image.Pt(12, 12), draw.Src)
+	r = EstimateScannability(crop, c, nil)
+	if !r.Risky() || r.QuietZone != 1 {
+		t.Errorf("cropped code: QuietZone=%d, Problems=%v", r.QuietZone, r.Problems)
+	}
+
+	// So is low contrast.
+	src := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	draw.Draw(src, src.Bounds(), &image.Uniform{color.Gray{0x80}}, image.ZP, draw.Src)
+	m, err := Colorize(c, src, 0.2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = EstimateScannability(m, c, nil)
+	if !r.Risky() || r.Contrast >= 0.4 {
+		t.Errorf("low-contrast code: Contrast=%v, Problems=%v", r.Contrast, r.Problems)
+	}
+
+	// And a camera too far away.
+	opt.Distance = 2000
+	r = EstimateScannability(img, c, opt)
+	if !r.Risky() {
+		t.Errorf("distant code is not risky")
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	c, err := qr.Encode("http://swtch.com/qr", qr.M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Scale = 4
+	img, err := png.Decode(bytes.NewReader(c.PNG()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := Measure(img, c, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Overall != GradeA || q.UnusedErrorCorrection != 1 || q.FixedPatternDamage != 0 {
+		t.Errorf("clean code: %+v, want grade A", q)
+	}
+
+	// Gray ink, a damaged position box, and a smudge over the data
+	// each lower the grade.
+	m := image.NewRGBA(img.Bounds())
+	draw.Draw(m, m.Bounds(), img, image.ZP, draw.Src)
+	for y := 0; y < m.Bounds().Dy(); y++ {
+		for x := 0; x < m.Bounds().Dx(); x++ {
+			if Reflectance(m.At(x, y)) < 0.5 {
+				m.Set(x, y, color.Gray{0x50})
+			}
+		}
+	}
+	mod := func(x, y int) image.Rectangle {
+		return image.Rect((x+4)*4, (y+4)*4, (x+5)*4, (y+5)*4)
+	}
+	draw.Draw(m, mod(2, 2), image.White, image.ZP, draw.Src)
+	draw.Draw(m, mod(c.Size-5, c.Size-5).Union(mod(c.Size-3, c.Size-3)), image.Black, image.ZP, draw.Src)
+	q, err = Measure(m, c, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.ModulationGrade == GradeA || q.FixedPatternDamage != 1 || q.UnusedErrorCorrection >= 1 || q.Overall == GradeA {
+		t.Errorf("damaged code: %+v", q)
+	}
+}
+
+func TestStress(t *testing.T) {
+	for _, d := range []Damage{RandomModules, Scratches, Occlusion} {
+		rs, err := Stress("http://swtch.com/qr", d, 5, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rs) != 4 {
+			t.Fatalf("%v: %d results, want 4", d, len(rs))
+		}
+		for _, r := range rs {
+			if r.Min <= 0 || r.Min > r.Mean+1e-9 || r.Mean >= 1 {
+				t.Errorf("%v level %v: Min=%v Mean=%v", d, r.Level, r.Min, r.Mean)
+			}
+		}
+		if rs[qr.H].Mean <= rs[qr.L].Mean {
+			t.Errorf("%v: level H failed at %v, sooner than level L at %v", d, rs[qr.H].Mean, rs[qr.L].Mean)
+		}
+	}
+}
+
+func TestDistort(t *testing.T) {
+	c, err := qr.Encode("http://swtch.com/qr", qr.M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Scale = 4
+	img, err := png.Decode(bytes.NewReader(c.PNG()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := coding.Decode(&coding.Code{Bitmap: c.Bitmap, Size: c.Size, Stride: c.Stride})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := Distort(img, Distortion{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < g.Bounds().Dy(); y++ {
+		for x := 0; x < g.Bounds().Dx(); x++ {
+			if (g.GrayAt(x, y).Y < 128) != (Reflectance(img.At(x, y)) < 0.5) {
+				t.Fatalf("zero distortion changed pixel %d,%d", x, y)
+			}
+		}
+	}
+
+	// Mild degradation keeps the modules readable at their centers.
+	d := Distortion{Rotation: 90, Blur: 0.7, Lighting: 0.2, Noise: 0.05, JPEGQuality: 80, Seed: 1}
+	g, err = Distort(img, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := readBack(g, c.Size, c.Scale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := coding.Decode(got); err != nil || !bytes.Equal(data, want) {
+		t.Errorf("Decode(Distort(%+v)) = %q, %v, want %q", d, data, err, want)
+	}
+
+	imgs, ds, err := Corpus(img, 3, Distortion{Rotation: 30, Perspective: 0.05, Blur: 2, Lighting: 0.5, Noise: 0.1, JPEGQuality: 20, Seed: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imgs) != 3 || len(ds) != 3 {
+		t.Fatalf("Corpus returned %d images, %d distortions", len(imgs), len(ds))
+	}
+	for i, d := range ds {
+		g, err := Distort(img, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(g.Pix, imgs[i].Pix) {
+			t.Errorf("Corpus image %d does not match Distort(%+v)", i, d)
+		}
+	}
+}
+
+func TestCalibration(t *testing.T) {
+	cal := &Calibration{
+		Text:   "http://swtch.com/qr",
+		Themes: []*Theme{LookupTheme("plain"), LookupTheme("dots")},
+	}
+	var buf bytes.Buffer
+	if err := cal.PDF(&buf); err != nil {
+		t.Fatal(err)
+	}
+	pdf := buf.String()
+	if !strings.HasPrefix(pdf, "%PDF-1.4\n") || !strings.HasSuffix(pdf, "%%EOF\n") {
+		t.Fatalf("malformed PDF")
+	}
+	for _, s := range []string{"(0.25mm L plain)", "(1.00mm H dots)"} {
+		if !strings.Contains(pdf, s) {
+			t.Errorf("PDF lacks caption %s", s)
+		}
+	}
+	i := strings.LastIndex(pdf, "startxref\n")
+	var xref int
+	if _, err := fmt.Sscanf(pdf[i+len("startxref\n"):], "%d", &xref); err != nil || !strings.HasPrefix(pdf[xref:], "xref\n") {
+		t.Errorf("startxref does not point at xref table")
+	}
+
+	cal.ModuleSizes = []float64{5}
+	if err := cal.PDF(&buf); err == nil {
+		t.Errorf("oversized calibration sheet succeeded")
+	}
+}
+
+func TestSize(t *testing.T) {
+	if d := MaxScanDistance(50); d != 500 || MinCodeWidth(d) != 50 {
+		t.Errorf("MaxScanDistance(50) = %v, MinCodeWidth(%v) = %v", d, d, MinCodeWidth(d))
+	}
+
+	// A 5 cm sticker does not scan from 2 meters...
+	ss, err := Size("http://swtch.com/qr", 50, 2000, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ss) != 4 {
+		t.Fatalf("Size returned %d results, want 4", len(ss))
+	}
+	for _, s := range ss {
+		if s.OK {
+			t.Errorf("level %v: 5cm code scans from 2m: %+v", s.Level, s)
+		}
+		if s.Modules != 17+4*int(s.Version) {
+			t.Errorf("level %v: Modules=%d, Version=%v", s.Level, s.Modules, s.Version)
+		}
+	}
+
+	// ...but a 30 cm poster does, at every level.
+	ss, err = Size("http://swtch.com/qr", 300, 2000, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range ss {
+		if !s.OK {
+			t.Errorf("level %v: 30cm code does not scan from 2m: %+v", s.Level, s)
+		}
+	}
+
+	// Coarse printers limit tiny codes.
+	ss, err = Size("http://swtch.com/qr", 10, 50, 72)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ss[qr.L].Dots >= 3 || ss[qr.L].OK {
+		t.Errorf("1cm code at 72dpi: %+v", ss[qr.L])
+	}
+}
+
+func TestCriticality(t *testing.T) {
+	cr, err := ModuleCriticality(6, coding.L, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := coding.NewPlan(6, coding.L, 0)
+	corr := float64(coding.Version(6).Correctable(coding.L))
+	for y, row := range p.Pixel {
+		for x, pix := range row {
+			var want float64
+			switch pix.Role() {
+			case coding.Data, coding.Check:
+				want = 1 / corr
+			case coding.Position, coding.Timing, coding.Alignment:
+				want = 0.25
+			}
+			if cr.Score[y][x] != want {
+				t.Fatalf("%v module %d,%d: score %v, want %v", pix.Role(), x, y, cr.Score[y][x], want)
+			}
+		}
+	}
+
+	// A larger patch is worse, and the worst patches are fatal.
+	big, err := ModuleCriticality(6, coding.L, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := big.Ranked()
+	if top := r[0]; big.Score[top.Y][top.X] != 1 {
+		t.Errorf("worst radius-3 patch at %v scores %v, want 1", top, big.Score[top.Y][top.X])
+	}
+	for i := 1; i < len(r); i++ {
+		if big.Score[r[i].Y][r[i].X] > big.Score[r[i-1].Y][r[i-1].X] {
+			t.Fatalf("Ranked out of order at %d", i)
+		}
+	}
+	if big.Score[20][30] <= cr.Score[20][30] {
+		t.Errorf("radius 3 score %v not above radius 0 score %v", big.Score[20][30], cr.Score[20][30])
+	}
+
+	// Rotation 1 moves the missing position box to the top right.
+	rot, err := ModuleCriticality(6, coding.L, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := cr.Size
+	if rot.Score[0][0] != 0.25 || rot.Score[n-1][n-1] != 0.25 || rot.Score[0][n-1] == 0.25 {
+		t.Errorf("rotated map does not match rotation")
+	}
+	if b := cr.Image(2).Bounds(); b.Dx() != (n+8)*2 {
+		t.Errorf("Image bounds %v", b)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	defer func(s Strictness) { Validation = s }(Validation)
+
+	c, err := qr.Encode("http://swtch.com/qr", qr.M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Scale = 4
+	img, err := png.Decode(bytes.NewReader(c.PNG()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []Strictness{Strict, Lenient} {
+		Validation = s
+		if w, err := Validate(img, c); err != nil || len(w) != 0 {
+			t.Errorf("Validate(%d) = %v, %v, want no problems", s, w, err)
+		}
+	}
+
+	// Crop the quiet zone and damage the format information.
+	bad := &qr.Code{Bitmap: append([]byte(nil), c.Bitmap...), Size: c.Size, Stride: c.Stride, Scale: c.Scale}
+	bad.Bitmap[8*bad.Stride] ^= 0x80 // format bit at (0, 8)
+	img, err = png.Decode(bytes.NewReader(bad.PNG()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cropped := image.NewGray(image.Rect(0, 0, c.Size*c.Scale, c.Size*c.Scale))
+	draw.Draw(cropped, cropped.Bounds(), img, image.Pt(4*c.Scale, 4*c.Scale), draw.Src)
+
+	Validation = Strict
+	if _, err := Validate(cropped, bad); err == nil {
+		t.Errorf("strict Validate accepted cropped code with bad format")
+	}
+	Validation = Lenient
+	w, err := Validate(cropped, bad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(w) != 2 || !strings.Contains(w[0], "format") || !strings.Contains(w[1], "quiet zone is 0") {
+		t.Errorf("lenient Validate = %q, want format and quiet zone warnings", w)
+	}
+}
+
+func TestHeadroom(t *testing.T) {
+	c, err := qr.Encode("http://swtch.com/qr", qr.Q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Scale = 4
+	img, err := png.Decode(bytes.NewReader(c.PNG()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := ImageHeadroom(img, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range h {
+		if b.Damaged != 0 || b.Left() != b.Correctable {
+			t.Errorf("undamaged code: %v", b)
+		}
+	}
+
+	// Cover the middle of the code with a white "logo".
+	m := image.NewGray(img.Bounds())
+	draw.Draw(m, m.Bounds(), img, image.ZP, draw.Src)
+	n := (c.Size + 8) * c.Scale
+	draw.Draw(m, image.Rect(n*2/5, n*2/5, n*3/5, n*3/5), image.White, image.ZP, draw.Src)
+	h, err = ImageHeadroom(m, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	damaged := 0
+	for _, b := range h {
+		damaged += b.Damaged
+		s := b.String()
+		if b.Left() >= 0 && s != fmt.Sprintf("block %d has %d of %d correctable codewords left", b.Block, b.Left(), b.Correctable) {
+			t.Errorf("String = %q", s)
+		}
+	}
+	if damaged == 0 {
+		t.Errorf("logo damaged no codewords")
+	}
+	if s := (Headroom{Block: 1, Damaged: 12, Correctable: 10}).String(); s != "block 1 has 12 damaged codewords, 2 more than it can correct" {
+		t.Errorf("String = %q", s)
+	}
+}
+
+func TestBasis(t *testing.T) {
+	const nd, nc = 2, 2
+	rs := gf256.NewRSEncoder(coding.Field, nc)
+	check := make([]byte, nc)
+	valid := func(row []byte) bool {
+		rs.ECC(row[:nd], check)
+		return bytes.Equal(row[nd:], check)
+	}
+
+	// Committing every data bit leaves no free edits,
+	// and row i is the only committed row with bit i set.
+	var bits []int
+	for i := 0; i < nd*8; i++ {
+		bits = append(bits, i)
+	}
+	committed, free := Basis(nd, nc, bits)
+	if len(committed) != nd*8 || len(free) != 0 {
+		t.Fatalf("Basis: %d committed, %d free, want %d, 0", len(committed), len(free), nd*8)
+	}
+	for i, row := range committed {
+		if !valid(row) {
+			t.Errorf("committed row %d = %x is not a codeword", i, row)
+		}
+		for j, r := range committed {
+			if set := r[i/8]>>uint(7-i&7)&1 != 0; set != (i == j) {
+				t.Errorf("committed row %d has bit %d = %v", j, i, set)
+			}
+		}
+	}
+
+	// Committing the even bits of data and check leaves
+	// the edits that touch only odd bits.
+	bits = bits[:0]
+	for i := 0; i < (nd+nc)*8; i += 2 {
+		bits = append(bits, i)
+	}
+	committed, free = Basis(nd, nc, bits)
+	if len(committed)+len(free) != nd*8 {
+		t.Errorf("Basis: %d committed + %d free rows, want %d", len(committed), len(free), nd*8)
+	}
+	for i, row := range free {
+		if !valid(row) {
+			t.Errorf("free row %d = %x is not a codeword", i, row)
+		}
+		for _, bi := range bits {
+			if row[bi/8]>>uint(7-bi&7)&1 != 0 {
+				t.Errorf("free row %d = %x has committed bit %d set", i, row, bi)
+			}
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package art
+
+import (
+	"bytes"
+
+	"code.google.com/p/rsc/gf256"
+	"code.google.com/p/rsc/qr/coding"
+)
+
+// A bitBlock tracks the data and check bytes of a single
+// Reed-Solomon block along with a basis for the space of
+// edits that remain available.
+//
+// Each row of m is a codeword: a data byte pattern with
+// its corresponding check bytes.  XORing a row into b keeps
+// the block a valid codeword.  canSet runs one step of
+// Gaussian elimination to commit a bit to a given value;
+// the rows used for committed bits are moved to m[len(m):cap(m)]
+// so that reset can still flip a committed bit later.
+type bitBlock struct {
+	nd  int
+	nc  int
+	b   []byte
+	m   [][]byte
+	tmp []byte
+	rs  *gf256.RSEncoder
+
+	bdata []byte
+	cdata []byte
+}
+
+func newBlock(nd, nc int, rs *gf256.RSEncoder, dat, cdata []byte) *bitBlock {
+	b := &bitBlock{
+		nd:    nd,
+		nc:    nc,
+		b:     make([]byte, nd+nc),
+		tmp:   make([]byte, nc),
+		rs:    rs,
+		bdata: dat,
+		cdata: cdata,
+	}
+	copy(b.b, dat)
+	rs.ECC(b.b[:nd], b.b[nd:])
+	b.check()
+	if !bytes.Equal(b.tmp, cdata) {
+		panic("art: inconsistent check bytes")
+	}
+
+	b.m = make([][]byte, nd*8)
+	for i := range b.m {
+		row := make([]byte, nd+nc)
+		b.m[i] = row
+		row[i/8] = 1 << (7 - uint(i%8))
+		rs.ECC(row[:nd], row[nd:])
+	}
+	return b
+}
+
+// check panics if b is not a valid codeword.
+func (b *bitBlock) check() {
+	b.rs.ECC(b.b[:b.nd], b.tmp)
+	if !bytes.Equal(b.b[b.nd:], b.tmp) {
+		panic("art: ecc mismatch")
+	}
+}
+
+// reset changes the already committed bit bi to bval.
+// It is used when dithering revisits a pixel.
+func (b *bitBlock) reset(bi uint, bval byte) {
+	if (b.b[bi/8]>>(7-bi&7))&1 == bval {
+		// already has desired bit
+		return
+	}
+	// rows that have already been set
+	m := b.m[len(b.m):cap(b.m)]
+	for _, row := range m {
+		if row[bi/8]&(1<<(7-bi&7)) != 0 {
+			// Found it.
+			for j, v := range row {
+				b.b[j] ^= v
+			}
+			return
+		}
+	}
+	panic("art: reset of unset bit")
+}
+
+// canSet reports whether bit bi can be set to bval
+// without disturbing any previously committed bit.
+// If so, it sets the bit and commits it.
+func (b *bitBlock) canSet(bi uint, bval byte) bool {
+	found := false
+	m := b.m
+	for j, row := range m {
+		if row[bi/8]&(1<<(7-bi&7)) == 0 {
+			continue
+		}
+		if !found {
+			found = true
+			if j != 0 {
+				m[0], m[j] = m[j], m[0]
+			}
+			continue
+		}
+		for k := range row {
+			row[k] ^= m[0][k]
+		}
+	}
+	if !found {
+		return false
+	}
+
+	targ := m[0]
+
+	// Subtract from saved-away rows too.
+	for _, row := range m[len(m):cap(m)] {
+		if row[bi/8]&(1<<(7-bi&7)) == 0 {
+			continue
+		}
+		for k := range row {
+			row[k] ^= targ[k]
+		}
+	}
+
+	// Found a row with bit #bi == 1 and cut that bit from all the others.
+	// Apply to data and remove from m.
+	if (b.b[bi/8]>>(7-bi&7))&1 != bval {
+		for j, v := range targ {
+			b.b[j] ^= v
+		}
+	}
+	b.check()
+	n := len(m) - 1
+	m[0], m[n] = m[n], m[0]
+	b.m = m[:n]
+	return true
+}
+
+// copyOut copies the block's bytes back into the
+// data and check slices it was created from.
+func (b *bitBlock) copyOut() {
+	b.check()
+	copy(b.bdata, b.b[:b.nd])
+	copy(b.cdata, b.b[b.nd:])
+}
+
+// Basis illustrates the elimination that Encode runs on each
+// Reed-Solomon block.  Starting from an all-zero block of nd data
+// and nc check bytes, it commits each of the given bit offsets in
+// turn, skipping any that earlier commitments have fixed.
+// It returns the rows used for the committed bits, in order,
+// followed by the rows for the edits still available.
+// Each row is a codeword of nd+nc bytes.
+func Basis(nd, nc int, bits []int) (committed, free [][]byte) {
+	rs := gf256.NewRSEncoder(coding.Field, nc)
+	dat := make([]byte, nd+nc)
+	b := newBlock(nd, nc, rs, dat[:nd], dat[nd:])
+	for _, bi := range bits {
+		b.canSet(uint(bi), 0)
+	}
+	// canSet moves each committed row to the end of m's
+	// underlying array, so they appear there in reverse order.
+	used := b.m[len(b.m):cap(b.m)]
+	for i := len(used) - 1; i >= 0; i-- {
+		committed = append(committed, used[i])
+	}
+	return committed, b.m
+}
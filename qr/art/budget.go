@@ -0,0 +1,120 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package art
+
+import "code.google.com/p/rsc/qr/coding"
+
+// A Budget describes how much of a code Encode can steer.
+//
+// Encode may choose the value of any data bit holding a free digit
+// and of any check bit, but the check bits are determined by the
+// data bits, so in each block it can choose only as many bits as
+// the block has free data bits.  The check bits are the error
+// correction slack: they let Encode pick which candidate bits
+// to control, favoring the ones whose pixels matter most.
+type Budget struct {
+	Size int // number of pixels on a side
+
+	// Fixed counts data bits whose values Encode cannot change:
+	// the URL, the segment headers, and the final padding.
+	Fixed int
+
+	// Candidates counts the data and check pixels
+	// whose values Encode may choose.
+	Candidates int
+
+	// Free counts the pixels Encode can actually control.
+	// It is at most Candidates.  Occasionally Encode spends
+	// a few extra bits to keep the digits valid.
+	Free int
+
+	// Blocks gives the Candidates and Free counts
+	// for each Reed-Solomon block.
+	Blocks []BlockBudget
+
+	// Candidate[y][x] reports whether pixel (x, y) is a candidate.
+	Candidate [][]bool
+}
+
+// A BlockBudget gives the free-bit budget of a single block.
+type BlockBudget struct {
+	Candidates int
+	Free       int
+}
+
+// Budget returns the free-bit budget for m.
+// It uses m.URL, m.Version, m.Level, m.Rotation, and m.OnlyDataBits,
+// but not the target.
+func (m *Image) Budget() (*Budget, error) {
+	p, err := m.plan()
+	if err != nil {
+		return nil, err
+	}
+	bbit, mbit, err := digitBits(m.URL+"#", p)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(p.Pixel)
+	bg := &Budget{Size: n, Fixed: p.DataBytes*8 - (mbit - bbit)}
+	cand := make([]bool, (p.DataBytes+p.CheckBytes)*8)
+	nd0 := p.DataBytes / p.Blocks
+	nc := p.CheckBytes / p.Blocks
+	extra := p.DataBytes - nd0*p.Blocks
+	doff, coff := 0, 0
+	for blocknum := 0; blocknum < p.Blocks; blocknum++ {
+		nd := nd0
+		if blocknum >= p.Blocks-extra {
+			nd++
+		}
+		lo, hi := blockRange(doff, nd, bbit, mbit)
+		bb := BlockBudget{Candidates: hi - lo, Free: hi - lo}
+		for i := lo; i < hi; i++ {
+			cand[doff+i] = true
+		}
+		if !m.OnlyDataBits {
+			bb.Candidates += nc * 8
+			for i := 0; i < nc*8; i++ {
+				cand[p.DataBytes*8+coff+i] = true
+			}
+		}
+		bg.Blocks = append(bg.Blocks, bb)
+		bg.Candidates += bb.Candidates
+		bg.Free += bb.Free
+		doff += nd * 8
+		coff += nc * 8
+	}
+
+	bg.Candidate = make([][]bool, n)
+	for y, row := range p.Pixel {
+		bg.Candidate[y] = make([]bool, n)
+		for x, pix := range row {
+			if r := pix.Role(); r == coding.Data || r == coding.Check {
+				bg.Candidate[y][x] = cand[pix.Offset()]
+			}
+		}
+	}
+	return bg, nil
+}
+
+// blockRange returns the range [lo, hi) of bits in a block
+// of nd data bytes starting at data bit offset doff that
+// fall within the digit bits [bbit, mbit).
+func blockRange(doff, nd, bbit, mbit int) (lo, hi int) {
+	lo, hi = 0, nd*8
+	if lo < bbit-doff {
+		lo = bbit - doff
+		if lo > hi {
+			lo = hi
+		}
+	}
+	if hi > mbit-doff {
+		hi = mbit - doff
+		if hi < lo {
+			hi = lo
+		}
+	}
+	return lo, hi
+}
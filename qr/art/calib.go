@@ -0,0 +1,199 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package art
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+
+	"code.google.com/p/rsc/qr"
+)
+
+// A Calibration describes a printer calibration sheet: a single
+// page showing the same text encoded at every combination of
+// module size, error correction level, and theme, each captioned,
+// so that an operator can print the page, try each code with the
+// intended scanner, and pick the smallest reliable configuration.
+type Calibration struct {
+	Text string
+
+	// ModuleSizes lists the printed module widths, in millimeters.
+	// If empty, it defaults to 0.25, 0.33, 0.5, 0.75, and 1.
+	ModuleSizes []float64
+
+	// Levels lists the error correction levels.
+	// If empty, it defaults to all four.
+	Levels []qr.Level
+
+	// Themes lists the themes.  If empty, it defaults
+	// to the plain theme.  Shapes are drawn as vectors,
+	// so every theme is exact at every module size.
+	Themes []*Theme
+}
+
+// Page size and margin, in points (A4).
+const (
+	pageWidth  = 595
+	pageHeight = 842
+	pageMargin = 36
+	mmPoints   = 72 / 25.4
+)
+
+// PDF writes the calibration sheet to w as a one-page PDF.
+// It returns an error if the codes do not fit on the page.
+func (cal *Calibration) PDF(w io.Writer) error {
+	sizes := cal.ModuleSizes
+	if len(sizes) == 0 {
+		sizes = []float64{0.25, 0.33, 0.5, 0.75, 1}
+	}
+	levels := cal.Levels
+	if len(levels) == 0 {
+		levels = []qr.Level{qr.L, qr.M, qr.Q, qr.H}
+	}
+	themes := cal.Themes
+	if len(themes) == 0 {
+		themes = []*Theme{LookupTheme("plain")}
+	}
+
+	var page bytes.Buffer
+	const gap, caption = 12, 10 // points
+	x, y := float64(pageMargin), float64(pageHeight-pageMargin)
+	rowHeight := 0.0
+	for _, th := range themes {
+		dark, light, err := th.colors()
+		if err != nil {
+			return err
+		}
+		for _, l := range levels {
+			c, err := qr.Encode(cal.Text, l)
+			if err != nil {
+				return err
+			}
+			shapes, err := th.shapes(c, 0)
+			if err != nil {
+				return err
+			}
+			for _, mm := range sizes {
+				if mm <= 0 {
+					return fmt.Errorf("art: invalid module size %v", mm)
+				}
+				mod := mm * mmPoints
+				side := float64(c.Size+8) * mod
+				label := fmt.Sprintf("%.2fmm %v %s", mm, levelName(l), th.Name)
+				width := side
+				if lw := float64(len(label)) * 0.5 * 7; lw > width {
+					width = lw
+				}
+				if x+width > pageWidth-pageMargin && x > pageMargin {
+					x = pageMargin
+					y -= rowHeight + gap
+					rowHeight = 0
+				}
+				if h := side + caption; h > rowHeight {
+					rowHeight = h
+				}
+				if x+width > pageWidth-pageMargin || y-rowHeight < pageMargin {
+					return fmt.Errorf("art: calibration sheet does not fit on one page")
+				}
+
+				// Code, with its quiet zone, below the caption.
+				top := y - caption
+				pdfFill(&page, light)
+				fmt.Fprintf(&page, "%.2f %.2f %.2f %.2f re f\n", x, top-side, side, side)
+				pdfFill(&page, dark)
+				for my := 0; my < c.Size; my++ {
+					for mx := 0; mx < c.Size; mx++ {
+						if c.Black(mx, my) {
+							pdfShape(&page, shapes[my][mx],
+								x+float64(mx+4)*mod, top-float64(my+5)*mod, mod)
+						}
+					}
+				}
+				fmt.Fprintf(&page, "0 g BT /F1 7 Tf %.2f %.2f Td (%s) Tj ET\n", x, y-7, pdfEscape(label))
+				x += width + gap
+			}
+		}
+	}
+	return writePDF(w, page.Bytes())
+}
+
+func levelName(l qr.Level) string {
+	if 0 <= l && l <= qr.H {
+		return string("LMQH"[l])
+	}
+	return fmt.Sprint(int(l))
+}
+
+// pdfFill sets the fill color to c.
+func pdfFill(b *bytes.Buffer, c color.Color) {
+	r, g, bl, a := c.RGBA()
+	f := func(v uint32) float64 { return float64(v+0xffff-a) / 0xffff }
+	fmt.Fprintf(b, "%.3f %.3f %.3f rg\n", f(r), f(g), f(bl))
+}
+
+// pdfShape fills shape s in the module of side d with lower left corner (x, y).
+func pdfShape(b *bytes.Buffer, s Shape, x, y, d float64) {
+	switch s {
+	case Circle:
+		pdfRoundRect(b, x, y, d, d/2)
+	case Rounded:
+		pdfRoundRect(b, x, y, d, d/4)
+	case Diamond:
+		fmt.Fprintf(b, "%.2f %.2f m %.2f %.2f l %.2f %.2f l %.2f %.2f l f\n",
+			x+d/2, y, x+d, y+d/2, x+d/2, y+d, x, y+d/2)
+	default:
+		fmt.Fprintf(b, "%.2f %.2f %.2f %.2f re f\n", x, y, d, d)
+	}
+}
+
+// pdfRoundRect fills a square of side d with corners of radius r,
+// approximating each corner with a cubic Bézier curve.
+func pdfRoundRect(b *bytes.Buffer, x, y, d, r float64) {
+	const κ = 0.5523 // control point distance for a quarter circle
+	k := r * κ
+	x1, y1 := x+d, y+d
+	fmt.Fprintf(b, "%.2f %.2f m\n", x+r, y)
+	fmt.Fprintf(b, "%.2f %.2f l %.2f %.2f %.2f %.2f %.2f %.2f c\n", x1-r, y, x1-r+k, y, x1, y+r-k, x1, y+r)
+	fmt.Fprintf(b, "%.2f %.2f l %.2f %.2f %.2f %.2f %.2f %.2f c\n", x1, y1-r, x1, y1-r+k, x1-r+k, y1, x1-r, y1)
+	fmt.Fprintf(b, "%.2f %.2f l %.2f %.2f %.2f %.2f %.2f %.2f c\n", x+r, y1, x+r-k, y1, x, y1-r+k, x, y1-r)
+	fmt.Fprintf(b, "%.2f %.2f l %.2f %.2f %.2f %.2f %.2f %.2f c f\n", x, y+r, x, y+r-k, x+r-k, y, x+r, y)
+}
+
+// pdfEscape escapes s for use in a PDF string literal.
+func pdfEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`).Replace(s)
+}
+
+// writePDF writes a one-page PDF with the given content stream,
+// using Helvetica as font F1.
+func writePDF(w io.Writer, content []byte) error {
+	var b bytes.Buffer
+	var offsets []int
+	obj := func(format string, args ...interface{}) {
+		offsets = append(offsets, b.Len())
+		fmt.Fprintf(&b, "%d 0 obj\n", len(offsets))
+		fmt.Fprintf(&b, format, args...)
+		fmt.Fprintf(&b, "\nendobj\n")
+	}
+	b.WriteString("%PDF-1.4\n")
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj("<< /Type /Pages /Kids [3 0 R] /Count 1 >>")
+	obj("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R >>",
+		pageWidth, pageHeight)
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>")
+	obj("<< /Length %d >>\nstream\n%s\nendstream", len(content), content)
+
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	_, err := w.Write(b.Bytes())
+	return err
+}
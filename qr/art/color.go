@@ -0,0 +1,143 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package art
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"code.google.com/p/rsc/qr"
+	"code.google.com/p/rsc/qr/web/resize"
+)
+
+// Reflectance returns the reflectance of c as seen by a typical
+// scanner: its luma, scaled to lie between 0 (black) and 1 (white).
+// Partially transparent colors are assumed to lie on white paper.
+func Reflectance(c color.Color) float64 {
+	r, g, b, a := c.RGBA()
+	// Composite over white.
+	r += 0xffff - a
+	g += 0xffff - a
+	b += 0xffff - a
+	return (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 0xffff
+}
+
+// Colorize returns an image of c in which modules take their colors
+// from src instead of being black and white.  The image src is
+// stretched over the code and its quiet zone, and each module is
+// painted with the average color of the region of src it covers,
+// adjusted to keep the code readable: dark modules are darkened
+// until their reflectance is at most (1-contrast)/2, and light
+// modules are lightened until it is at least (1+contrast)/2.
+// Any dark module and any light module therefore differ in
+// reflectance by at least contrast, which should be between 0 and 1.
+// Scanners generally need 0.4 or more; see CheckContrast.
+//
+// Like c.Image, the result has c.Scale pixels per module
+// and a 4-module quiet zone.
+func Colorize(c *qr.Code, src image.Image, contrast float64) (*image.RGBA, error) {
+	if contrast <= 0 || contrast > 1 {
+		return nil, fmt.Errorf("art: invalid contrast %v", contrast)
+	}
+	n := c.Size + 8
+	b := src.Bounds()
+	if b.Dx() <= 0 || b.Dy() <= 0 {
+		return nil, fmt.Errorf("art: empty source image")
+	}
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), image.White, image.ZP, draw.Src)
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Over)
+	samp := resize.ResizeRGBA(rgba, rgba.Bounds(), n, n)
+
+	darkMax := (1 - contrast) / 2
+	lightMin := (1 + contrast) / 2
+
+	scale := c.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+	m := image.NewRGBA(image.Rect(0, 0, n*scale, n*scale))
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			col := samp.RGBAAt(x, y)
+			if c.Black(x-4, y-4) {
+				col = darken(col, darkMax)
+			} else {
+				col = lighten(col, lightMin)
+			}
+			r := image.Rect(x*scale, y*scale, (x+1)*scale, (y+1)*scale)
+			draw.Draw(m, r, &image.Uniform{col}, image.ZP, draw.Src)
+		}
+	}
+	return m, nil
+}
+
+// darken scales c toward black until its reflectance is at most max.
+func darken(c color.RGBA, max float64) color.RGBA {
+	r := Reflectance(c)
+	if r <= max {
+		return c
+	}
+	f := max / r
+	return color.RGBA{byte(float64(c.R) * f), byte(float64(c.G) * f), byte(float64(c.B) * f), 0xff}
+}
+
+// lighten blends c toward white until its reflectance is at least min.
+func lighten(c color.RGBA, min float64) color.RGBA {
+	r := Reflectance(c)
+	if r >= min {
+		return c
+	}
+	f := (min - r) / (1 - r)
+	up := func(v uint8) uint8 {
+		w := float64(v) + (255-float64(v))*f + 0.999
+		if w > 255 {
+			w = 255
+		}
+		return uint8(w)
+	}
+	return color.RGBA{up(c.R), up(c.G), up(c.B), 0xff}
+}
+
+// CheckContrast checks that m, an image of c with c.Scale pixels
+// per module and a 4-module quiet zone, can be read by a scanner
+// that needs dark and light modules to differ in reflectance by
+// at least min.  It samples the center pixel of every module and
+// compares the lightest dark module against the darkest light one.
+func CheckContrast(m image.Image, c *qr.Code, min float64) error {
+	maxDark := 0.0
+	minLight := 1.0
+	var darkPt, lightPt image.Point
+	scale := c.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+	b := m.Bounds()
+	for y := -4; y < c.Size+4; y++ {
+		for x := -4; x < c.Size+4; x++ {
+			pt := image.Pt(b.Min.X+(x+4)*scale+scale/2, b.Min.Y+(y+4)*scale+scale/2)
+			if !pt.In(b) {
+				return fmt.Errorf("art: image %v too small for code", b)
+			}
+			r := Reflectance(m.At(pt.X, pt.Y))
+			if c.Black(x, y) {
+				if r >= maxDark {
+					maxDark, darkPt = r, image.Pt(x, y)
+				}
+			} else {
+				if r <= minLight {
+					minLight, lightPt = r, image.Pt(x, y)
+				}
+			}
+		}
+	}
+	if minLight-maxDark < min {
+		return fmt.Errorf("art: insufficient contrast: dark module %v has reflectance %.2f, light module %v has %.2f",
+			darkPt, maxDark, lightPt, minLight)
+	}
+	return nil
+}
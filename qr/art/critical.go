@@ -0,0 +1,258 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package art
+
+import (
+	"image"
+	"image/color"
+	"sort"
+
+	"code.google.com/p/rsc/qr/coding"
+)
+
+// A Criticality map rates how damaging it is to lose each part of
+// a code, for deciding where varnish, folds, or staples may overlap it.
+//
+// Score[y][x] is the damage done by obscuring the square patch of
+// modules within Radius of (x, y), as a fraction of what the code
+// can tolerate: 1 means the patch alone makes the code unreadable.
+// The score is the largest of:
+//
+//   - for each Reed-Solomon block, the number of its codewords
+//     the patch touches, over the number it can correct;
+//   - for the format and version information, the number of
+//     bits lost from the less damaged of the two copies, over
+//     the 4 that make a copy unreadable;
+//   - for each position box, the timing patterns, and the
+//     alignment boxes, the number of modules lost, over the 4
+//     that earn grade F for fixed pattern damage (see Measure).
+//
+// The map depends only on the version, level, and rotation,
+// not on the data.  Patches hitting many codewords of one block
+// score high, which is why a clustered scratch is less harmful
+// than the same number of scattered specks.
+type Criticality struct {
+	Size   int
+	Radius int
+	Score  [][]float64
+}
+
+// Module kinds for criticality.
+const (
+	critNone = iota
+	critBlock
+	critFormat
+	critVersion
+	critFixed
+)
+
+type critInfo struct {
+	kind  int
+	group int // block, copy, or fixed-pattern segment
+	item  int // codeword, bit, or module
+}
+
+// ModuleCriticality returns the criticality map for codes with the given
+// version, level, and rotation, for damage of the given radius.
+func ModuleCriticality(v coding.Version, l coding.Level, rotation, radius int) (*Criticality, error) {
+	p, err := coding.NewPlan(v, l, 0)
+	if err != nil {
+		return nil, err
+	}
+	n := len(p.Pixel)
+	nc := p.CheckBytes / p.Blocks
+	nd0 := p.DataBytes / p.Blocks
+	extra := p.DataBytes - nd0*p.Blocks
+	correctable := v.Correctable(l)
+
+	// blockOf returns the block holding data or check byte k.
+	blockOf := func(k int) int {
+		if k >= p.DataBytes {
+			return (k - p.DataBytes) / nc
+		}
+		short := (p.Blocks - extra) * nd0
+		if k < short {
+			return k / nd0
+		}
+		return p.Blocks - extra + (k-short)/(nd0+1)
+	}
+
+	info := make([][]critInfo, n)
+	for y, row := range p.Pixel {
+		info[y] = make([]critInfo, n)
+		for x, pix := range row {
+			ci := &info[y][x]
+			switch pix.Role() {
+			case coding.Data, coding.Check:
+				k := int(pix.Offset() / 8)
+				*ci = critInfo{critBlock, blockOf(k), k}
+			case coding.Format:
+				cp := 1
+				if x < 9 && y < 9 {
+					cp = 0
+				}
+				*ci = critInfo{critFormat, cp, int(pix.Offset())}
+			case coding.PVersion:
+				// Bottom left copy is rows n-11..n-9, columns 0..5;
+				// top right copy is its transpose.
+				if y > n/2 {
+					*ci = critInfo{critVersion, 0, x*3 + y - (n - 11)}
+				} else {
+					*ci = critInfo{critVersion, 1, y*3 + x - (n - 11)}
+				}
+			case coding.Position:
+				seg := 0
+				if x > n/2 {
+					seg = 1
+				} else if y > n/2 {
+					seg = 2
+				}
+				*ci = critInfo{critFixed, seg, y*n + x}
+			case coding.Timing:
+				*ci = critInfo{critFixed, 3, y*n + x}
+			case coding.Alignment:
+				*ci = critInfo{critFixed, 4, y*n + x}
+			}
+		}
+	}
+
+	score := make([][]float64, n)
+	for y := range score {
+		score[y] = make([]float64, n)
+		for x := range score[y] {
+			words := make(map[[2]int]bool)
+			blockHits := make(map[int]int)
+			var fmtHits, verHits [2]int
+			var fixedHits [5]int
+			for yy := y - radius; yy <= y+radius; yy++ {
+				for xx := x - radius; xx <= x+radius; xx++ {
+					if xx < 0 || xx >= n || yy < 0 || yy >= n {
+						continue
+					}
+					ci := info[yy][xx]
+					switch ci.kind {
+					case critBlock:
+						if w := [2]int{ci.group, ci.item}; !words[w] {
+							words[w] = true
+							blockHits[ci.group]++
+						}
+					case critFormat:
+						fmtHits[ci.group]++
+					case critVersion:
+						verHits[ci.group]++
+					case critFixed:
+						fixedHits[ci.group]++
+					}
+				}
+			}
+			s := 0.0
+			for _, h := range blockHits {
+				if correctable == 0 {
+					s = 1
+				} else if f := float64(h) / float64(correctable); f > s {
+					s = f
+				}
+			}
+			for _, hits := range [][2]int{fmtHits, verHits} {
+				h := hits[0]
+				if hits[1] < h {
+					h = hits[1]
+				}
+				if f := float64(h) / 4; f > s {
+					s = f
+				}
+			}
+			for _, h := range fixedHits {
+				if f := float64(h) / 4; f > s {
+					s = f
+				}
+			}
+			if s > 1 {
+				s = 1
+			}
+			score[y][x] = s
+		}
+	}
+	return &Criticality{Size: n, Radius: radius, Score: rotateGrid(score, rotation)}, nil
+}
+
+// rotateGrid returns g turned the same way rotate turns a plan.
+func rotateGrid(g [][]float64, rot int) [][]float64 {
+	n := len(g)
+	r := make([][]float64, n)
+	for y := range r {
+		r[y] = make([]float64, n)
+		for x := range r[y] {
+			switch rot & 3 {
+			case 0:
+				r[y][x] = g[y][x]
+			case 1:
+				r[y][x] = g[x][n-1-y]
+			case 2:
+				r[y][x] = g[n-1-y][n-1-x]
+			case 3:
+				r[y][x] = g[n-1-x][y]
+			}
+		}
+	}
+	return r
+}
+
+type byScore struct {
+	pt    []image.Point
+	score [][]float64
+}
+
+func (x byScore) Len() int      { return len(x.pt) }
+func (x byScore) Swap(i, j int) { x.pt[i], x.pt[j] = x.pt[j], x.pt[i] }
+func (x byScore) Less(i, j int) bool {
+	pi, pj := x.pt[i], x.pt[j]
+	if si, sj := x.score[pi.Y][pi.X], x.score[pj.Y][pj.X]; si != sj {
+		return si > sj
+	}
+	if pi.Y != pj.Y {
+		return pi.Y < pj.Y
+	}
+	return pi.X < pj.X
+}
+
+// Ranked returns the module positions from most to least critical.
+func (c *Criticality) Ranked() []image.Point {
+	var pts []image.Point
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			pts = append(pts, image.Pt(x, y))
+		}
+	}
+	sort.Sort(byScore{pts, c.Score})
+	return pts
+}
+
+// Image returns a heat map of c with scale pixels per module,
+// shading modules from green (harmless) through yellow to red
+// (fatal).  Like the code images, it has a 4-module quiet zone.
+func (c *Criticality) Image(scale int) *image.RGBA {
+	if scale <= 0 {
+		scale = 1
+	}
+	d := (c.Size + 8) * scale
+	m := image.NewRGBA(image.Rect(0, 0, d, d))
+	for y := 0; y < d; y++ {
+		for x := 0; x < d; x++ {
+			mx, my := x/scale-4, y/scale-4
+			col := color.RGBA{0xff, 0xff, 0xff, 0xff}
+			if 0 <= mx && mx < c.Size && 0 <= my && my < c.Size {
+				s := c.Score[my][mx]
+				if s < 0.5 {
+					col = color.RGBA{uint8(510 * s), 0xc0, 0, 0xff}
+				} else {
+					col = color.RGBA{0xff, uint8(0xc0 * 2 * (1 - s)), 0, 0xff}
+				}
+			}
+			m.SetRGBA(x, y, col)
+		}
+	}
+	return m
+}
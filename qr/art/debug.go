@@ -0,0 +1,110 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package art
+
+import (
+	"image"
+	"image/color"
+
+	"code.google.com/p/rsc/qr/coding"
+)
+
+// Debug holds intermediate images from Image.Encode,
+// to help explain why an image reproduces poorly.
+// Each image has one pixel per QR module and no quiet zone.
+type Debug struct {
+	// Target is the target as the encoder saw it: resized,
+	// offset, and gamma-adjusted, or, when dithering, the
+	// black or white chosen for each dithered pixel.
+	// Pixels with no target are drawn mid-gray.
+	Target *image.Gray
+
+	// Control shows which pixels the encoder could choose.
+	// Controlled pixels are black or white; the rest,
+	// fixed by the QR format or by the encoded URL or
+	// by earlier choices, are dark or light gray.
+	Control *image.Gray
+
+	// Diff compares the code with the target.  Pixels
+	// that match are white, pixels that should have been
+	// black are red, and pixels that should have been white
+	// are blue.  Pixels with no target are light gray.
+	Diff *image.RGBA
+
+	// Match and Mismatch count the pixels that do and
+	// do not match the target.  Controlled counts the data
+	// and check pixels the encoder chose.
+	Match      int
+	Mismatch   int
+	Controlled int
+}
+
+var (
+	diffMatch    = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	diffWantDark = color.RGBA{0xff, 0x00, 0x00, 0xff}
+	diffWantLite = color.RGBA{0x00, 0x00, 0xff, 0xff}
+	diffNone     = color.RGBA{0xd0, 0xd0, 0xd0, 0xff}
+)
+
+func (d *Debug) record(m *Image, params *Params, p *coding.Plan, pixByOff []pixinfo, cc *coding.Code) {
+	n := len(p.Pixel)
+	r := image.Rect(0, 0, n, n)
+	d.Target = image.NewGray(r)
+	d.Control = image.NewGray(r)
+	d.Diff = image.NewRGBA(r)
+	d.Match, d.Mismatch, d.Controlled = 0, 0, 0
+
+	for y, row := range p.Pixel {
+		for x, pix := range row {
+			black := cc.Black(x, y)
+
+			var pinfo *pixinfo
+			if r := pix.Role(); r == coding.Data || r == coding.Check {
+				pinfo = &pixByOff[pix.Offset()]
+			}
+
+			// Target.
+			targ, contrast := m.target(x, y)
+			g := int(params.gray(targ))
+			if contrast < 0 {
+				g = -1
+			} else if m.Dither && pinfo != nil && pinfo.block != nil {
+				g = pinfo.dval
+			}
+			if g < 0 {
+				d.Target.SetGray(x, y, color.Gray{0x80})
+			} else {
+				d.Target.SetGray(x, y, color.Gray{uint8(g)})
+			}
+
+			// Control.
+			v := uint8(0x00)
+			if !black {
+				v = 0xff
+			}
+			if pinfo != nil && pinfo.block != nil {
+				d.Controlled++
+			} else {
+				v = v/2 + 0x40
+			}
+			d.Control.SetGray(x, y, color.Gray{v})
+
+			// Diff.
+			switch {
+			case g < 0:
+				d.Diff.SetRGBA(x, y, diffNone)
+			case params.black(g) == black:
+				d.Match++
+				d.Diff.SetRGBA(x, y, diffMatch)
+			case black:
+				d.Mismatch++
+				d.Diff.SetRGBA(x, y, diffWantLite)
+			default:
+				d.Mismatch++
+				d.Diff.SetRGBA(x, y, diffWantDark)
+			}
+		}
+	}
+}
@@ -0,0 +1,274 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package art
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"math"
+	"math/rand"
+)
+
+// A Distortion describes how Distort degrades an image
+// to imitate a photograph of it.  The zero Distortion
+// leaves the image unchanged.
+type Distortion struct {
+	// Rotation turns the image counterclockwise
+	// about its center, in degrees.
+	Rotation float64
+
+	// Perspective moves each corner of the image by up to
+	// this fraction of the image width in a random direction,
+	// as if photographed at an angle.
+	Perspective float64
+
+	// Blur is the standard deviation of a Gaussian blur, in pixels.
+	Blur float64
+
+	// Lighting darkens the image along a random direction,
+	// from no change at one edge to multiplying by 1-Lighting
+	// at the other.
+	Lighting float64
+
+	// Noise is the standard deviation of added Gaussian noise,
+	// as a fraction of the range from black to white.
+	Noise float64
+
+	// JPEGQuality, if positive, is the quality at which
+	// the image is passed through JPEG compression.
+	JPEGQuality int
+
+	// Seed seeds the random choices made by the other fields.
+	Seed int64
+}
+
+// Distort returns a grayscale copy of m degraded by d.
+// Areas uncovered by rotation or perspective are white.
+func Distort(m image.Image, d Distortion) (*image.Gray, error) {
+	rand := rand.New(rand.NewSource(d.Seed))
+	b := m.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= 0 || h <= 0 {
+		return nil, fmt.Errorf("art: empty image")
+	}
+	src := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			src[y*w+x] = Reflectance(m.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+
+	// Geometry: find where the source corners land, then map
+	// each destination pixel back through the inverse homography.
+	corners := [4][2]float64{{0, 0}, {float64(w), 0}, {float64(w), float64(h)}, {0, float64(h)}}
+	var moved [4][2]float64
+	θ := -d.Rotation * math.Pi / 180
+	cx, cy := float64(w)/2, float64(h)/2
+	for i, p := range corners {
+		dx, dy := p[0]-cx, p[1]-cy
+		x := cx + dx*math.Cos(θ) - dy*math.Sin(θ)
+		y := cy + dx*math.Sin(θ) + dy*math.Cos(θ)
+		if d.Perspective > 0 {
+			r := d.Perspective * float64(w) * rand.Float64()
+			φ := 2 * math.Pi * rand.Float64()
+			x += r * math.Cos(φ)
+			y += r * math.Sin(φ)
+		}
+		moved[i] = [2]float64{x, y}
+	}
+	hm, err := homography(moved, corners)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			px, py := float64(x)+0.5, float64(y)+0.5
+			z := hm[6]*px + hm[7]*py + 1
+			sx := (hm[0]*px+hm[1]*py+hm[2])/z - 0.5
+			sy := (hm[3]*px+hm[4]*py+hm[5])/z - 0.5
+			out[y*w+x] = bilinear(src, w, h, sx, sy)
+		}
+	}
+
+	if d.Lighting > 0 {
+		φ := 2 * math.Pi * rand.Float64()
+		ux, uy := math.Cos(φ), math.Sin(φ)
+		span := math.Abs(ux)*float64(w) + math.Abs(uy)*float64(h)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				t := ((float64(x)-cx)*ux+(float64(y)-cy)*uy)/span + 0.5
+				out[y*w+x] *= 1 - d.Lighting*t
+			}
+		}
+	}
+
+	if d.Blur > 0 {
+		out = blur(out, w, h, d.Blur)
+	}
+
+	if d.Noise > 0 {
+		for i := range out {
+			out[i] += d.Noise * rand.NormFloat64()
+		}
+	}
+
+	g := image.NewGray(image.Rect(0, 0, w, h))
+	for i, v := range out {
+		g.Pix[i] = uint8(math.Max(0, math.Min(255, v*255+0.5)))
+	}
+
+	if d.JPEGQuality > 0 {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, g, &jpeg.Options{Quality: d.JPEGQuality}); err != nil {
+			return nil, err
+		}
+		j, err := jpeg.Decode(&buf)
+		if err != nil {
+			return nil, err
+		}
+		draw.Draw(g, g.Bounds(), j, j.Bounds().Min, draw.Src)
+	}
+	return g, nil
+}
+
+// Corpus returns n distortions of m, for testing decoders.
+// The parameters of each distortion are chosen uniformly at random
+// between zero and the corresponding field of max, except that
+// JPEGQuality is chosen between max.JPEGQuality and 100.
+// The distortions themselves are returned along with the images
+// so that failures can be reproduced.
+func Corpus(m image.Image, n int, max Distortion) ([]*image.Gray, []Distortion, error) {
+	rand := rand.New(rand.NewSource(max.Seed))
+	var imgs []*image.Gray
+	var ds []Distortion
+	for i := 0; i < n; i++ {
+		d := Distortion{
+			Rotation:    max.Rotation * rand.Float64(),
+			Perspective: max.Perspective * rand.Float64(),
+			Blur:        max.Blur * rand.Float64(),
+			Lighting:    max.Lighting * rand.Float64(),
+			Noise:       max.Noise * rand.Float64(),
+			Seed:        rand.Int63(),
+		}
+		if max.JPEGQuality > 0 {
+			d.JPEGQuality = max.JPEGQuality + rand.Intn(101-max.JPEGQuality)
+		}
+		g, err := Distort(m, d)
+		if err != nil {
+			return nil, nil, err
+		}
+		imgs = append(imgs, g)
+		ds = append(ds, d)
+	}
+	return imgs, ds, nil
+}
+
+// homography returns the projective map taking each point
+// from[i] to to[i], as the coefficients h0..h7 of
+//
+//	x' = (h0 x + h1 y + h2) / (h6 x + h7 y + 1)
+//	y' = (h3 x + h4 y + h5) / (h6 x + h7 y + 1)
+func homography(from, to [4][2]float64) ([8]float64, error) {
+	var a [8][9]float64
+	for i := 0; i < 4; i++ {
+		x, y := from[i][0], from[i][1]
+		u, v := to[i][0], to[i][1]
+		a[2*i] = [9]float64{x, y, 1, 0, 0, 0, -u * x, -u * y, u}
+		a[2*i+1] = [9]float64{0, 0, 0, x, y, 1, -v * x, -v * y, v}
+	}
+	// Gaussian elimination with partial pivoting.
+	for col := 0; col < 8; col++ {
+		piv := col
+		for r := col + 1; r < 8; r++ {
+			if math.Abs(a[r][col]) > math.Abs(a[piv][col]) {
+				piv = r
+			}
+		}
+		if math.Abs(a[piv][col]) < 1e-12 {
+			return [8]float64{}, fmt.Errorf("art: degenerate distortion")
+		}
+		a[col], a[piv] = a[piv], a[col]
+		for r := 0; r < 8; r++ {
+			if r == col {
+				continue
+			}
+			f := a[r][col] / a[col][col]
+			for k := col; k < 9; k++ {
+				a[r][k] -= f * a[col][k]
+			}
+		}
+	}
+	var h [8]float64
+	for i := range h {
+		h[i] = a[i][8] / a[i][i]
+	}
+	return h, nil
+}
+
+// bilinear samples the w×h image p at (x, y),
+// treating pixels outside the image as white.
+func bilinear(p []float64, w, h int, x, y float64) float64 {
+	at := func(x, y int) float64 {
+		if x < 0 || x >= w || y < 0 || y >= h {
+			return 1
+		}
+		return p[y*w+x]
+	}
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := x-x0, y-y0
+	ix, iy := int(x0), int(y0)
+	return (1-fy)*((1-fx)*at(ix, iy)+fx*at(ix+1, iy)) +
+		fy*((1-fx)*at(ix, iy+1)+fx*at(ix+1, iy+1))
+}
+
+// blur applies a Gaussian blur with standard deviation σ
+// to the w×h image p, extending the edges outward.
+func blur(p []float64, w, h int, σ float64) []float64 {
+	r := int(math.Ceil(3 * σ))
+	k := make([]float64, 2*r+1)
+	sum := 0.0
+	for i := range k {
+		d := float64(i - r)
+		k[i] = math.Exp(-d * d / (2 * σ * σ))
+		sum += k[i]
+	}
+	for i := range k {
+		k[i] /= sum
+	}
+	clamp := func(v, n int) int {
+		if v < 0 {
+			return 0
+		}
+		if v >= n {
+			return n - 1
+		}
+		return v
+	}
+	tmp := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			s := 0.0
+			for i, kv := range k {
+				s += kv * p[y*w+clamp(x+i-r, w)]
+			}
+			tmp[y*w+x] = s
+		}
+	}
+	out := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			s := 0.0
+			for i, kv := range k {
+				s += kv * tmp[clamp(y+i-r, h)*w+x]
+			}
+			out[y*w+x] = s
+		}
+	}
+	return out
+}
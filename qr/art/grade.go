@@ -0,0 +1,208 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package art
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"code.google.com/p/rsc/qr"
+	"code.google.com/p/rsc/qr/coding"
+)
+
+// A Grade is a print quality grade, from F (0) to A (4).
+type Grade int
+
+const (
+	GradeF Grade = iota
+	GradeD
+	GradeC
+	GradeB
+	GradeA
+)
+
+func (g Grade) String() string {
+	if GradeF <= g && g <= GradeA {
+		return string("FDCBA"[g])
+	}
+	return fmt.Sprintf("Grade(%d)", int(g))
+}
+
+// atLeast returns the grade of v given the minimum values
+// for grades A, B, C, and D.
+func atLeast(v float64, a, b, c, d float64) Grade {
+	switch {
+	case v >= a:
+		return GradeA
+	case v >= b:
+		return GradeB
+	case v >= c:
+		return GradeC
+	case v >= d:
+		return GradeD
+	}
+	return GradeF
+}
+
+// A Quality holds the print quality parameters of ISO/IEC 15415,
+// measured from an image of a printed code.  Each parameter has
+// a grade, and the overall grade is the lowest of them.
+type Quality struct {
+	// SymbolContrast is the difference between the highest
+	// and lowest reflectance in the code and its quiet zone.
+	SymbolContrast      float64
+	SymbolContrastGrade Grade
+
+	// Modulation is the smallest difference between a module's
+	// reflectance and the global threshold halfway between the
+	// extremes, relative to half the symbol contrast.
+	Modulation      float64
+	ModulationGrade Grade
+
+	// FixedPatternDamage is the largest number of misread modules
+	// in any one position box (with its separator), in the timing
+	// patterns, or in the alignment boxes.
+	FixedPatternDamage      int
+	FixedPatternDamageGrade Grade
+
+	// AxialNonuniformity is the difference between the horizontal
+	// and vertical module spacing, relative to their mean.
+	AxialNonuniformity      float64
+	AxialNonuniformityGrade Grade
+
+	// UnusedErrorCorrection is the fraction of the error correction
+	// capacity left unused in the worst Reed-Solomon block.
+	// It is 0 if any block has too many errors to decode.
+	UnusedErrorCorrection      float64
+	UnusedErrorCorrectionGrade Grade
+
+	Overall Grade
+}
+
+// Measure measures the print quality of m, a scan or photograph
+// of a printed copy of c, which was created with the given rotation
+// (0 for codes made by qr.Encode).
+//
+// Measure samples m the same way as EstimateScannability: it
+// locates the code by the bounding box of its dark pixels, so the
+// capture must be square to the code, and it reads each module at
+// its center.  It binarizes the samples at the global threshold of
+// ISO/IEC 15415.  The unused error correction grade needs the
+// number of damaged codewords in each block, which coding.Decode
+// does not report, so Measure counts them by comparing the reading
+// against c with coding.BlockErrors.  A block passes if it has no
+// more errors than Version.Correctable allows, the same limit
+// Decode uses.
+func Measure(m image.Image, c *qr.Code, rotation int) (*Quality, error) {
+	p, err := codePlan(c, rotation)
+	if err != nil {
+		return nil, err
+	}
+	box := locate(m)
+	if box.Empty() {
+		return nil, fmt.Errorf("art: cannot find code in image")
+	}
+	refl := sample(m, box, c.Size)
+
+	q := new(Quality)
+	rmin, rmax := 1.0, 0.0
+	for _, row := range refl {
+		for _, r := range row {
+			if r < 0 {
+				continue
+			}
+			rmin = math.Min(rmin, r)
+			rmax = math.Max(rmax, r)
+		}
+	}
+	q.SymbolContrast = rmax - rmin
+	q.SymbolContrastGrade = atLeast(q.SymbolContrast, 0.70, 0.55, 0.40, 0.20)
+	if q.SymbolContrast <= 0 {
+		return nil, fmt.Errorf("art: image has no contrast")
+	}
+
+	// Binarize and measure modulation.
+	gt := (rmax + rmin) / 2
+	got := &coding.Code{Size: c.Size, Stride: (c.Size + 7) / 8}
+	got.Bitmap = make([]byte, got.Stride*got.Size)
+	q.Modulation = 1
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			r := refl[y+4][x+4]
+			if r < gt {
+				got.Bitmap[y*got.Stride+x/8] |= 1 << uint(7-x&7)
+			}
+			q.Modulation = math.Min(q.Modulation, 2*math.Abs(r-gt)/q.SymbolContrast)
+		}
+	}
+	q.ModulationGrade = atLeast(q.Modulation, 0.50, 0.40, 0.30, 0.20)
+
+	// Fixed pattern damage, by segment.
+	n := c.Size
+	var seg [6]int // position boxes by corner, timing, alignment
+	for y, row := range p.Pixel {
+		for x, pix := range row {
+			if got.Black(x, y) == c.Black(x, y) {
+				continue
+			}
+			switch pix.Role() {
+			case coding.Position:
+				i := 0
+				if x >= n/2 {
+					i++
+				}
+				if y >= n/2 {
+					i += 2
+				}
+				seg[i]++
+			case coding.Timing:
+				seg[4]++
+			case coding.Alignment:
+				seg[5]++
+			}
+		}
+	}
+	for _, d := range seg {
+		if d > q.FixedPatternDamage {
+			q.FixedPatternDamage = d
+		}
+	}
+	q.FixedPatternDamageGrade = GradeF
+	if q.FixedPatternDamage < 4 {
+		q.FixedPatternDamageGrade = GradeA - Grade(q.FixedPatternDamage)
+	}
+
+	// Axial nonuniformity.
+	xs := float64(box.Dx()) / float64(c.Size)
+	ys := float64(box.Dy()) / float64(c.Size)
+	q.AxialNonuniformity = math.Abs(xs-ys) / ((xs + ys) / 2)
+	q.AxialNonuniformityGrade = atLeast(-q.AxialNonuniformity, -0.06, -0.08, -0.10, -0.12)
+
+	// Unused error correction.
+	want := &coding.Code{Bitmap: c.Bitmap, Size: c.Size, Stride: c.Stride}
+	errs, max, err := coding.BlockErrors(want, got)
+	if err != nil {
+		return nil, fmt.Errorf("art: %v", err)
+	}
+	q.UnusedErrorCorrection = 1
+	for _, e := range errs {
+		u := 0.0
+		if e <= max && max > 0 {
+			u = 1 - float64(e)/float64(max)
+		}
+		q.UnusedErrorCorrection = math.Min(q.UnusedErrorCorrection, u)
+	}
+	q.UnusedErrorCorrectionGrade = atLeast(q.UnusedErrorCorrection, 0.62, 0.50, 0.37, 0.25)
+
+	q.Overall = GradeA
+	for _, g := range []Grade{q.SymbolContrastGrade, q.ModulationGrade, q.FixedPatternDamageGrade,
+		q.AxialNonuniformityGrade, q.UnusedErrorCorrectionGrade} {
+		if g < q.Overall {
+			q.Overall = g
+		}
+	}
+	return q, nil
+}
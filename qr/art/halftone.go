@@ -0,0 +1,122 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package art
+
+import (
+	"fmt"
+	"image"
+
+	"code.google.com/p/rsc/qr"
+	"code.google.com/p/rsc/qr/coding"
+)
+
+// Halftone returns an image of c in which each module is split
+// into a 3×3 grid of subcells.  The center subcell always has the
+// module's color and is the only one a scanner needs to sample;
+// the other eight are set by error diffusion to reproduce target.
+// Modules belonging to position, alignment, and timing patterns
+// and to format and version information are drawn solid,
+// since scanners locate the code by their shape.
+//
+// The target holds gray levels indexed by subcell, so it should
+// be 3*c.Size subcells on a side (see MakeTarget).  Subcells
+// outside the target or with value -1 take the module's color.
+// The rotation must be the one used to create c, or 0 for codes
+// made by qr.Encode.
+//
+// Each subcell is drawn as a c.Scale×c.Scale square, and the code
+// is surrounded by the usual 4-module quiet zone.
+func Halftone(c *qr.Code, target [][]int, rotation int) (*image.Gray, error) {
+	p, err := codePlan(c, rotation)
+	if err != nil {
+		return nil, err
+	}
+
+	// Compute subcell values with error diffusion.
+	n := 3 * c.Size
+	cell := make([][]bool, n) // true is black
+	errs := make([][]int, n+1)
+	for i := range cell {
+		cell[i] = make([]bool, n)
+	}
+	for i := range errs {
+		errs[i] = make([]int, n+2)
+	}
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			mx, my := x/3, y/3
+			black := c.Black(mx, my)
+			g := -1
+			if y < len(target) && x < len(target[y]) {
+				g = target[y][x]
+			}
+			fixed := g < 0 || x%3 == 1 && y%3 == 1
+			switch p.Pixel[my][mx].Role() {
+			case coding.Position, coding.Alignment, coding.Timing, coding.Format, coding.PVersion, coding.Dark:
+				fixed = true
+			}
+			if g < 0 {
+				cell[y][x] = black
+				continue
+			}
+			want := g + errs[y][x+1]
+			if !fixed {
+				black = want < 128
+			}
+			cell[y][x] = black
+			out := 255
+			if black {
+				out = 0
+			}
+			e := want - out
+			errs[y][x+2] += e * 7 / 16
+			errs[y+1][x] += e * 3 / 16
+			errs[y+1][x+1] += e * 5 / 16
+			errs[y+1][x+2] += e * 1 / 16
+		}
+	}
+
+	// Draw.
+	scale := c.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+	d := (n + 3*8) * scale
+	m := image.NewGray(image.Rect(0, 0, d, d))
+	for i := range m.Pix {
+		m.Pix[i] = 0xFF
+	}
+	for y, row := range cell {
+		for x, black := range row {
+			if !black {
+				continue
+			}
+			x0, y0 := (x+12)*scale, (y+12)*scale
+			for yy := y0; yy < y0+scale; yy++ {
+				pix := m.Pix[yy*m.Stride+x0 : yy*m.Stride+x0+scale]
+				for i := range pix {
+					pix[i] = 0
+				}
+			}
+		}
+	}
+	return m, nil
+}
+
+// codePlan returns a plan giving the pixel roles of c,
+// which was created with the given rotation.
+// The data pixels in the plan are meaningless.
+func codePlan(c *qr.Code, rotation int) (*coding.Plan, error) {
+	v := coding.Version((c.Size - 17) / 4)
+	if c.Size != 17+4*int(v) {
+		return nil, fmt.Errorf("art: invalid code size %d", c.Size)
+	}
+	p, err := coding.NewPlan(v, coding.L, 0)
+	if err != nil {
+		return nil, err
+	}
+	rotate(p, rotation)
+	return p, nil
+}
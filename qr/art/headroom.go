@@ -0,0 +1,69 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package art
+
+import (
+	"fmt"
+	"image"
+
+	"code.google.com/p/rsc/qr"
+	"code.google.com/p/rsc/qr/coding"
+)
+
+// A Headroom reports how much of one Reed-Solomon block's
+// error correction remains after a code has been styled,
+// overlaid with a logo, or printed with defects.
+type Headroom struct {
+	Block       int // index in the order used by coding.BlockErrors
+	Damaged     int // codewords read incorrectly
+	Correctable int // codewords the block can correct
+}
+
+// Left returns the number of further damaged codewords the block
+// can absorb.  It is negative if the block can no longer be decoded.
+func (h Headroom) Left() int {
+	return h.Correctable - h.Damaged
+}
+
+func (h Headroom) String() string {
+	if h.Left() < 0 {
+		return fmt.Sprintf("block %d has %d damaged codewords, %d more than it can correct", h.Block, h.Damaged, -h.Left())
+	}
+	return fmt.Sprintf("block %d has %d of %d correctable codewords left", h.Block, h.Left(), h.Correctable)
+}
+
+// ErrorHeadroom compares got, a reading of the code want after
+// styling or damage, against want, and reports the error correction
+// left in each block.  The codes must have the same orientation.
+func ErrorHeadroom(want, got *qr.Code) ([]Headroom, error) {
+	w := &coding.Code{Bitmap: want.Bitmap, Size: want.Size, Stride: want.Stride}
+	g := &coding.Code{Bitmap: got.Bitmap, Size: got.Size, Stride: got.Stride}
+	return headroom(w, g)
+}
+
+// ImageHeadroom is like ErrorHeadroom but reads the styled code
+// from m, an image of c with c.Scale pixels per module and a
+// 4-module quiet zone, such as one returned by Theme.Image or
+// a logo drawn over c.PNG().
+func ImageHeadroom(m image.Image, c *qr.Code) ([]Headroom, error) {
+	g, err := readBack(m, c.Size, c.Scale)
+	if err != nil {
+		return nil, fmt.Errorf("art: %v", err)
+	}
+	w := &coding.Code{Bitmap: c.Bitmap, Size: c.Size, Stride: c.Stride}
+	return headroom(w, g)
+}
+
+func headroom(want, got *coding.Code) ([]Headroom, error) {
+	errs, max, err := coding.BlockErrors(want, got)
+	if err != nil {
+		return nil, fmt.Errorf("art: %v", err)
+	}
+	h := make([]Headroom, len(errs))
+	for i, e := range errs {
+		h[i] = Headroom{Block: i, Damaged: e, Correctable: max}
+	}
+	return h, nil
+}
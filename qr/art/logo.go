@@ -0,0 +1,272 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package art
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"sort"
+
+	"code.google.com/p/rsc/qr/coding"
+)
+
+// A LogoBudget describes how Encode splits a code's error
+// correction between a logo and the image.
+//
+// A logo drawn over the code after encoding damages every codeword
+// with a covered pixel of the wrong color, and each Reed-Solomon
+// block can correct only half as many damaged codewords as it has
+// check bytes.  Encode avoids damage by steering covered pixels to
+// the logo's color, but every steered pixel is one fewer free pixel
+// for the image.  The solver steers the cheapest damaged codewords
+// in each block until the rest can be corrected.
+type LogoBudget struct {
+	Rect   image.Rectangle // pixels covered by the logo
+	Blocks []LogoBlock
+
+	// Free counts the pixels left for the image.
+	Free int
+}
+
+// A LogoBlock describes the logo's effect on a single block.
+type LogoBlock struct {
+	Damaged     int // codewords the logo would damage
+	Steered     int // damaged codewords Encode steers to match the logo
+	Correctable int // damaged codewords the block can correct
+	Cost        int // free pixels spent steering
+}
+
+// A LogoError reports that a logo cannot be combined with an image.
+type LogoError struct {
+	Reason string
+
+	// Suggestions lists nearby settings that would work,
+	// if any, with the smallest change first.
+	Suggestions []LogoSuggestion
+}
+
+// A LogoSuggestion is a version and level at which a logo fits.
+type LogoSuggestion struct {
+	Version coding.Version
+	Level   coding.Level
+}
+
+func (e *LogoError) Error() string {
+	s := "art: logo does not fit: " + e.Reason
+	for i, sg := range e.Suggestions {
+		if i == 0 {
+			s += "; try"
+		} else {
+			s += " or"
+		}
+		s += fmt.Sprintf(" version %v level %v", sg.Version, sg.Level)
+	}
+	return s
+}
+
+// A word is a damaged codeword that could be steered
+// by setting the given bits.
+type word struct {
+	cost int
+	bits []int
+}
+
+type byCost []word
+
+func (x byCost) Len() int           { return len(x) }
+func (x byCost) Swap(i, j int)      { x[i], x[j] = x[j], x[i] }
+func (x byCost) Less(i, j int) bool { return x[i].cost < x[j].cost }
+
+// logoRect returns the pixels covered by m's logo
+// in a code n pixels on a side.
+func (m *Image) logoRect(n int) image.Rectangle {
+	if m.LogoSize <= 0 {
+		return image.Rectangle{}
+	}
+	d := int(m.LogoSize*float64(n) + 0.5)
+	if d > n {
+		d = n
+	}
+	x0 := (n - d) / 2
+	return image.Rect(x0, x0, x0+d, x0+d)
+}
+
+// LogoBudget returns the logo budget for m, which must have a
+// positive LogoSize.  If the logo cannot be combined with the image
+// at m's version and level, LogoBudget returns a *LogoError
+// suggesting other settings.
+func (m *Image) LogoBudget() (*LogoBudget, error) {
+	p, err := m.plan()
+	if err != nil {
+		return nil, err
+	}
+	lb, _, err := m.solveLogo(p)
+	if err, ok := err.(*LogoError); ok {
+		err.Suggestions = m.logoSuggestions()
+	}
+	return lb, err
+}
+
+// logoSuggestions returns the versions and levels near m's
+// at which its logo would fit.
+func (m *Image) logoSuggestions() []LogoSuggestion {
+	var s []LogoSuggestion
+	try := func(v coding.Version, l coding.Level) bool {
+		mm := *m
+		mm.Version, mm.Level = v, l
+		p, err := coding.NewPlan(v, l, m.Mask)
+		if err != nil {
+			return false
+		}
+		rotate(p, m.Rotation)
+		if _, _, err := mm.solveLogo(p); err != nil {
+			return false
+		}
+		s = append(s, LogoSuggestion{v, l})
+		return true
+	}
+	// Higher level, same version.
+	for l := m.Level + 1; l <= coding.H; l++ {
+		if try(m.Version, l) {
+			break
+		}
+	}
+	// Higher version, same level.
+	for v := m.Version + 1; v <= coding.MaxVersion && v <= m.Version+10; v++ {
+		if try(v, m.Level) {
+			break
+		}
+	}
+	return s
+}
+
+// solveLogo computes the logo budget for the (rotated) plan p.
+// It also returns, indexed by bit offset, the bits that Encode
+// must steer to the logo's color.
+func (m *Image) solveLogo(p *coding.Plan) (*LogoBudget, []bool, error) {
+	n := len(p.Pixel)
+	r := m.logoRect(n)
+	lb := &LogoBudget{Rect: r}
+	nbit := (p.DataBytes + p.CheckBytes) * 8
+	steer := make([]bool, nbit)
+
+	url := m.URL + "#"
+	bbit, mbit, err := digitBits(url, p)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Template data, for the values of fixed bits.
+	var b coding.Bits
+	coding.String(url).Encode(&b, p.Version)
+	coding.Num(bytes.Repeat([]byte("0"), (mbit-bbit)/10*3)).Encode(&b, p.Version)
+	b.Pad(p.DataBytes*8 - b.Bits())
+	data := b.Bytes()
+
+	// Find covered pixels and whether they already match.
+	// match[o] is 1 if bit o is covered and known to match,
+	// -1 if covered and not known to match, and 0 if not covered.
+	match := make([]int, nbit)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			pix := p.Pixel[y][x]
+			switch pix.Role() {
+			case coding.Position, coding.Timing, coding.Format, coding.PVersion:
+				return lb, nil, &LogoError{Reason: fmt.Sprintf("logo covers %v pixel %d,%d", pix.Role(), x, y)}
+			case coding.Data:
+				o := pix.Offset()
+				match[o] = -1
+				if int(o) < bbit || int(o) >= mbit {
+					bit := data[o/8]>>(7-o&7)&1 == 1
+					if (pix&coding.Black != 0) != bit == m.LogoDark {
+						match[o] = 1
+					}
+				}
+			case coding.Check:
+				match[pix.Offset()] = -1
+			}
+		}
+	}
+
+	nd0 := p.DataBytes / p.Blocks
+	nc := p.CheckBytes / p.Blocks
+	extra := p.DataBytes - nd0*p.Blocks
+	doff, coff := 0, 0
+	for blocknum := 0; blocknum < p.Blocks; blocknum++ {
+		nd := nd0
+		if blocknum >= p.Blocks-extra {
+			nd++
+		}
+		lo, hi := blockRange(doff, nd, bbit, mbit)
+		free := hi - lo
+
+		// Examine each codeword.
+		var fixable byCost
+		blk := LogoBlock{Correctable: p.Version.Correctable(p.Level)}
+		for j := 0; j < nd+nc; j++ {
+			var base int
+			var canSteer bool
+			if j < nd {
+				base = doff + 8*j
+			} else {
+				base = p.DataBytes*8 + coff + 8*(j-nd)
+			}
+			var w word
+			damaged := false
+			for k := base; k < base+8; k++ {
+				if match[k] >= 0 {
+					continue
+				}
+				damaged = true
+				w.bits = append(w.bits, k)
+			}
+			if !damaged {
+				continue
+			}
+			blk.Damaged++
+			canSteer = true
+			for _, k := range w.bits {
+				if k < p.DataBytes*8 {
+					if k < doff+lo || k >= doff+hi {
+						canSteer = false
+					}
+				} else if m.OnlyDataBits {
+					canSteer = false
+				}
+			}
+			if canSteer {
+				w.cost = len(w.bits)
+				fixable = append(fixable, w)
+			}
+		}
+
+		// Steer the cheapest codewords until the rest are correctable.
+		sort.Sort(fixable)
+		for _, w := range fixable {
+			if blk.Damaged-blk.Steered <= blk.Correctable {
+				break
+			}
+			if blk.Cost+w.cost > free {
+				break
+			}
+			blk.Steered++
+			blk.Cost += w.cost
+			for _, k := range w.bits {
+				steer[k] = true
+			}
+		}
+		lb.Blocks = append(lb.Blocks, blk)
+		lb.Free += free - blk.Cost
+		if blk.Damaged-blk.Steered > blk.Correctable {
+			return lb, nil, &LogoError{Reason: fmt.Sprintf("block %d has %d damaged codewords but can correct only %d",
+				blocknum, blk.Damaged-blk.Steered, blk.Correctable)}
+		}
+
+		doff += nd * 8
+		coff += nc * 8
+	}
+	return lb, steer, nil
+}
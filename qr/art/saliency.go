@@ -0,0 +1,73 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package art
+
+import "math"
+
+// MakeSaliency returns a rough saliency map for target,
+// suitable for Image.Saliency.  Without a model of what
+// a viewer looks at, it uses two heuristics: detail
+// (the local standard deviation of gray levels) draws
+// the eye, and subjects tend to be centered in the frame.
+// Transparent pixels have saliency 0.
+//
+// Callers with better knowledge, such as the bounding box
+// of a face, should construct the map themselves.
+func MakeSaliency(target [][]int) [][]int {
+	h := len(target)
+	sal := make([][]int, h)
+	if h == 0 {
+		return sal
+	}
+	cy := float64(h-1) / 2
+	maxd := 0.0
+	for y, row := range target {
+		cx := float64(len(row)-1) / 2
+		for x := range row {
+			d := (float64(x)-cx)*(float64(x)-cx) + (float64(y)-cy)*(float64(y)-cy)
+			if d > maxd {
+				maxd = d
+			}
+		}
+	}
+
+	const del = 2
+	for y, row := range target {
+		sal[y] = make([]int, len(row))
+		cx := float64(len(row)-1) / 2
+		for x, v := range row {
+			if v < 0 {
+				continue
+			}
+			n, sum, sumsq := 0, 0, 0
+			for dy := -del; dy <= del; dy++ {
+				for dx := -del; dx <= del; dx++ {
+					if 0 <= y+dy && y+dy < h && 0 <= x+dx && x+dx < len(target[y+dy]) {
+						if v := target[y+dy][x+dx]; v >= 0 {
+							sum += v
+							sumsq += v * v
+							n++
+						}
+					}
+				}
+			}
+			avg := sum / n
+			detail := math.Sqrt(float64(sumsq/n - avg*avg)) // 0 to 127.5
+
+			d := (float64(x)-cx)*(float64(x)-cx) + (float64(y)-cy)*(float64(y)-cy)
+			center := 1.0
+			if maxd > 0 {
+				center -= 0.5 * d / maxd
+			}
+
+			s := int((64 + 1.5*detail) * center)
+			if s > 255 {
+				s = 255
+			}
+			sal[y][x] = s
+		}
+	}
+	return sal
+}
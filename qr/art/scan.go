@@ -0,0 +1,233 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package art
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"code.google.com/p/rsc/qr"
+	"code.google.com/p/rsc/qr/coding"
+)
+
+// ScanOptions describes how a rendered code will be printed and scanned.
+// The zero value means the physical size is unknown, in which case
+// EstimateScannability does not check density against the camera.
+type ScanOptions struct {
+	ModuleSize float64 // printed module width, in millimeters
+	Distance   float64 // distance from camera to code, in millimeters
+
+	// CameraPixels is the camera's horizontal resolution
+	// and FieldOfView its horizontal field of view, in degrees.
+	// If zero, they default to a typical phone camera's 1920 and 65.
+	CameraPixels int
+	FieldOfView  float64
+}
+
+// A ScanReport estimates how easily a rendered code can be scanned.
+type ScanReport struct {
+	// Score summarizes the report, from 0 (unreadable)
+	// to 1 (no concerns).  It is the smallest of the scores
+	// for the individual measurements below.
+	Score float64
+
+	// Contrast is the smallest reflectance difference
+	// between a dark and a light module, sampled at module centers.
+	Contrast float64
+
+	// ModulePixels is the width of a module in image pixels.
+	ModulePixels float64
+
+	// QuietZone is the width in modules of the light margin
+	// around the code, on its narrowest side.
+	QuietZone int
+
+	// CameraPixels is the width of a module in camera pixels,
+	// or 0 if the options do not give the physical size.
+	CameraPixels float64
+
+	// Penalty is the code's total mask penalty score
+	// under the four rules of ISO 18004 §7.8.3.
+	// Lower is better.
+	Penalty int
+
+	// Hotspots lists the modules that start finder-like
+	// patterns outside the position boxes, which can
+	// confuse a scanner looking for the code's corners.
+	Hotspots []image.Point
+
+	// Problems lists the measurements likely to prevent scanning.
+	// A configuration with any problems should not be printed.
+	Problems []string
+}
+
+// Risky reports whether r lists any problems.
+func (r *ScanReport) Risky() bool {
+	return len(r.Problems) > 0
+}
+
+// view returns the width in millimeters seen by the camera.
+func (o *ScanOptions) view() float64 {
+	fov := o.FieldOfView
+	if fov <= 0 {
+		fov = 65
+	}
+	return 2 * o.Distance * math.Tan(fov/2*math.Pi/180)
+}
+
+// cameraPixels returns the width of a module in camera pixels.
+func (o *ScanOptions) cameraPixels() float64 {
+	px := o.CameraPixels
+	if px <= 0 {
+		px = 1920
+	}
+	return o.ModuleSize * float64(px) / o.view()
+}
+
+// ramp maps v to [0, 1], linearly between bad and good.
+func ramp(v, bad, good float64) float64 {
+	f := (v - bad) / (good - bad)
+	return math.Max(0, math.Min(1, f))
+}
+
+// EstimateScannability estimates how easily m, an image of c,
+// can be scanned.  The image may use any colors, shapes, and scale,
+// but its darkest marks must be the code's dark modules: the code
+// is located by the bounding box of the pixels with reflectance
+// below one half.  The options may be nil.
+func EstimateScannability(m image.Image, c *qr.Code, opt *ScanOptions) *ScanReport {
+	r := &ScanReport{Score: 1}
+	score := func(s float64) {
+		if s < r.Score {
+			r.Score = s
+		}
+	}
+
+	b := m.Bounds()
+	box := locate(m)
+	if box.Empty() {
+		r.Score = 0
+		r.Problems = append(r.Problems, "no dark modules in image")
+		return r
+	}
+	mp := float64(box.Dx()) / float64(c.Size)
+	r.ModulePixels = mp
+	score(ramp(mp, 1, 4))
+	if mp < 2 {
+		r.Problems = append(r.Problems, fmt.Sprintf("modules are %.1f pixels wide, want at least 2", mp))
+	}
+
+	// Quiet zone.
+	margin := box.Min.X - b.Min.X
+	for _, d := range []int{box.Min.Y - b.Min.Y, b.Max.X - box.Max.X, b.Max.Y - box.Max.Y} {
+		if d < margin {
+			margin = d
+		}
+	}
+	r.QuietZone = int(float64(margin) / mp)
+	score(ramp(float64(r.QuietZone), 1, 4))
+	if r.QuietZone < 4 {
+		r.Problems = append(r.Problems, fmt.Sprintf("quiet zone is %d modules wide, want 4", r.QuietZone))
+	}
+
+	// Contrast at module centers.
+	maxDark, minLight := 0.0, 1.0
+	refl := sample(m, box, c.Size)
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			v := refl[y+4][x+4]
+			if c.Black(x, y) {
+				maxDark = math.Max(maxDark, v)
+			} else {
+				minLight = math.Min(minLight, v)
+			}
+		}
+	}
+	r.Contrast = minLight - maxDark
+	score(ramp(r.Contrast, 0.2, 0.7))
+	if r.Contrast < 0.4 {
+		r.Problems = append(r.Problems, fmt.Sprintf("contrast is %.2f, want at least 0.4", r.Contrast))
+	}
+
+	// Density as seen by the camera.
+	if opt != nil && opt.ModuleSize > 0 && opt.Distance > 0 {
+		view := opt.view()
+		r.CameraPixels = opt.cameraPixels()
+		score(ramp(r.CameraPixels, 1, 3))
+		if r.CameraPixels < 2 {
+			r.Problems = append(r.Problems, fmt.Sprintf("modules are %.1f camera pixels wide, want at least 2", r.CameraPixels))
+		}
+		if w := opt.ModuleSize * float64(c.Size+8); w > 0.8*view {
+			r.Problems = append(r.Problems, fmt.Sprintf("code is %.0fmm wide but camera sees only %.0fmm", w, view))
+			score(0)
+		}
+	}
+
+	// Mask penalty and hot spots.
+	r.Penalty, r.Hotspots = penalty(c)
+	score(1 / (1 + float64(len(r.Hotspots))/10))
+	if len(r.Hotspots) > 10 {
+		r.Problems = append(r.Problems, fmt.Sprintf("%d finder-like patterns in data", len(r.Hotspots)))
+	}
+	return r
+}
+
+// locate returns the bounding box of the pixels in m with
+// reflectance below one half, which is the bounding box of
+// the code if m is an image of a code.
+func locate(m image.Image) image.Rectangle {
+	b := m.Bounds()
+	box := image.Rectangle{}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if Reflectance(m.At(x, y)) < 0.5 {
+				box = box.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return box
+}
+
+// sample returns the reflectance at the center of each module
+// of a code size modules on a side occupying box in m.
+// The result is indexed [y+4][x+4], so that it includes the
+// quiet zone; modules of the quiet zone lying outside m
+// are given reflectance -1.
+func sample(m image.Image, box image.Rectangle, size int) [][]float64 {
+	b := m.Bounds()
+	mx := float64(box.Dx()) / float64(size)
+	my := float64(box.Dy()) / float64(size)
+	r := make([][]float64, size+8)
+	for y := range r {
+		r[y] = make([]float64, size+8)
+		for x := range r[y] {
+			pt := image.Pt(box.Min.X+int(math.Floor((float64(x-4)+0.5)*mx)),
+				box.Min.Y+int(math.Floor((float64(y-4)+0.5)*my)))
+			if !pt.In(b) {
+				r[y][x] = -1
+				continue
+			}
+			r[y][x] = Reflectance(m.At(pt.X, pt.Y))
+		}
+	}
+	return r
+}
+
+// penalty computes the mask penalty score for c
+// and the locations of finder-like patterns outside
+// the position boxes.
+func penalty(c *qr.Code) (score int, hot []image.Point) {
+	n := c.Size
+	cc := &coding.Code{Bitmap: c.Bitmap, Size: c.Size, Stride: c.Stride}
+	for _, r := range cc.PenaltyRuns() {
+		x, y := r.Start.X, r.Start.Y
+		inBox := x < 8 && y < 8 || x >= n-8 && y < 8 || x < 8 && y >= n-8
+		if r.Rule == 3 && !inBox {
+			hot = append(hot, r.Start)
+		}
+	}
+	return cc.Penalty(), hot
+}
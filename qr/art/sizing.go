@@ -0,0 +1,87 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package art
+
+import (
+	"fmt"
+
+	"code.google.com/p/rsc/qr"
+	"code.google.com/p/rsc/qr/coding"
+)
+
+// ScanRatio is the common rule of thumb that a code can be
+// scanned from at most ScanRatio times its width.
+const ScanRatio = 10
+
+// MaxScanDistance returns the greatest distance from which a code
+// of the given width, not counting its quiet zone, can be scanned
+// according to ScanRatio.  Any unit of length may be used.
+func MaxScanDistance(width float64) float64 {
+	return ScanRatio * width
+}
+
+// MinCodeWidth returns the smallest width, not counting the quiet
+// zone, of a code to be scanned from the given distance
+// according to ScanRatio.
+func MinCodeWidth(distance float64) float64 {
+	return distance / ScanRatio
+}
+
+// A Sizing describes a code printed at a particular size.
+type Sizing struct {
+	Level   qr.Level
+	Version coding.Version
+	Modules int // modules on a side, not counting the quiet zone
+
+	ModuleSize  float64 // width of a module, in millimeters
+	Dots        float64 // printer dots per module, or 0 if the DPI is unknown
+	MaxDistance float64 // greatest scan distance by ScanRatio, in millimeters
+
+	// CameraPixels is the width of a module in the pixels
+	// of a typical phone camera at the requested distance.
+	CameraPixels float64
+
+	// OK reports whether the code should scan: the distance is
+	// within MaxDistance, modules are at least 2 camera pixels wide,
+	// and, if the DPI is known, at least 3 printer dots wide.
+	OK bool
+}
+
+// Size reports how text would print at each error correction level
+// in a square of the given width in millimeters, including the
+// 4-module quiet zone, on a printer with the given resolution in
+// dots per inch (0 if unknown), for scanning from the given
+// distance in millimeters.
+//
+// For example, to ask which levels still scan from 2 meters on
+// a 5 cm sticker, call Size(text, 50, 2000, 300) and check OK
+// in each result.
+func Size(text string, width, distance, dpi float64) ([]Sizing, error) {
+	if width <= 0 || distance <= 0 || dpi < 0 {
+		return nil, fmt.Errorf("art: invalid size %vmm, distance %vmm, or %v dpi", width, distance, dpi)
+	}
+	var out []Sizing
+	for l := qr.L; l <= qr.H; l++ {
+		c, err := qr.Encode(text, l)
+		if err != nil {
+			return nil, err
+		}
+		s := Sizing{
+			Level:      l,
+			Version:    coding.Version((c.Size - 17) / 4),
+			Modules:    c.Size,
+			ModuleSize: width / float64(c.Size+8),
+		}
+		s.MaxDistance = MaxScanDistance(s.ModuleSize * float64(c.Size))
+		if dpi > 0 {
+			s.Dots = s.ModuleSize / 25.4 * dpi
+		}
+		opt := &ScanOptions{ModuleSize: s.ModuleSize, Distance: distance}
+		s.CameraPixels = opt.cameraPixels()
+		s.OK = distance <= s.MaxDistance && s.CameraPixels >= 2 && (dpi == 0 || s.Dots >= 3)
+		out = append(out, s)
+	}
+	return out, nil
+}
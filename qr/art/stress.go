@@ -0,0 +1,170 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package art
+
+import (
+	"fmt"
+	"math/rand"
+
+	"code.google.com/p/rsc/qr"
+	"code.google.com/p/rsc/qr/coding"
+)
+
+// A Damage is a kind of damage applied by Stress.
+type Damage int
+
+const (
+	RandomModules Damage = iota // individual modules flipped at random
+	Scratches                   // straight lines, one module wide, dark or light
+	Occlusion                   // rectangles up to 4×4 modules, dark or light
+)
+
+var damageNames = []string{
+	RandomModules: "random modules",
+	Scratches:     "scratches",
+	Occlusion:     "occlusion",
+}
+
+func (d Damage) String() string {
+	if 0 <= d && int(d) < len(damageNames) {
+		return damageNames[d]
+	}
+	return fmt.Sprintf("Damage(%d)", int(d))
+}
+
+// A StressResult reports how much damage a code survived.
+type StressResult struct {
+	Level  qr.Level
+	Damage Damage
+	Size   int // modules on a side
+
+	// Min and Mean give the fraction of the code's modules
+	// that were damaged when decoding first failed,
+	// over all trials.
+	Min, Mean float64
+}
+
+// Stress encodes text at each error correction level and damages
+// the code progressively, one scratch, rectangle, or module at a
+// time, until it no longer decodes.  It repeats the experiment
+// trials times, using a random source with the given seed,
+// and reports the damage at which decoding failed.
+//
+// A code is considered decodable while every Reed-Solomon block
+// has no more damaged codewords than it can correct (see
+// coding.BlockErrors), which is when coding.Decode can correct it.
+// Stress does not call Decode itself: Decode reads an ideal bitmap
+// and gives up on any damage to the position boxes and on heavy
+// damage to the format information, while real scanners tolerate
+// a good deal of both.  That damage is counted in the damaged
+// fraction but does not by itself cause failure.
+func Stress(text string, d Damage, trials int, seed int64) ([]StressResult, error) {
+	if trials <= 0 {
+		return nil, fmt.Errorf("art: invalid trial count %d", trials)
+	}
+	rand := rand.New(rand.NewSource(seed))
+	var out []StressResult
+	for l := qr.L; l <= qr.H; l++ {
+		c, err := qr.Encode(text, l)
+		if err != nil {
+			return nil, err
+		}
+		want := &coding.Code{Bitmap: c.Bitmap, Size: c.Size, Stride: c.Stride}
+		r := StressResult{Level: l, Damage: d, Size: c.Size, Min: 1}
+		for t := 0; t < trials; t++ {
+			f, err := stress(want, d, rand)
+			if err != nil {
+				return nil, err
+			}
+			if f < r.Min {
+				r.Min = f
+			}
+			r.Mean += f / float64(trials)
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// stress damages a copy of want until it fails to decode
+// and returns the fraction of modules damaged at that point.
+func stress(want *coding.Code, d Damage, rand *rand.Rand) (float64, error) {
+	n := want.Size
+	got := &coding.Code{Size: n, Stride: want.Stride, Bitmap: append([]byte(nil), want.Bitmap...)}
+	set := func(x, y int, black bool) {
+		if x < 0 || x >= n || y < 0 || y >= n {
+			return
+		}
+		bit := byte(1) << uint(7-x&7)
+		if black {
+			got.Bitmap[y*got.Stride+x/8] |= bit
+		} else {
+			got.Bitmap[y*got.Stride+x/8] &^= bit
+		}
+	}
+	damaged := func() int {
+		k := 0
+		for y := 0; y < n; y++ {
+			for x := 0; x < n; x++ {
+				if got.Black(x, y) != want.Black(x, y) {
+					k++
+				}
+			}
+		}
+		return k
+	}
+
+	for {
+		switch d {
+		case RandomModules:
+			x, y := rand.Intn(n), rand.Intn(n)
+			set(x, y, !got.Black(x, y))
+		case Scratches:
+			black := rand.Intn(2) == 0
+			x0, y0 := rand.Intn(n), rand.Intn(n)
+			x1, y1 := rand.Intn(n), rand.Intn(n)
+			steps := abs(x1 - x0)
+			if s := abs(y1 - y0); s > steps {
+				steps = s
+			}
+			for i := 0; i <= steps; i++ {
+				x, y := x0, y0
+				if steps > 0 {
+					x += (x1 - x0) * i / steps
+					y += (y1 - y0) * i / steps
+				}
+				set(x, y, black)
+			}
+		case Occlusion:
+			black := rand.Intn(2) == 0
+			x0, y0 := rand.Intn(n), rand.Intn(n)
+			w, h := 1+rand.Intn(4), 1+rand.Intn(4)
+			for y := y0; y < y0+h; y++ {
+				for x := x0; x < x0+w; x++ {
+					set(x, y, black)
+				}
+			}
+		default:
+			return 0, fmt.Errorf("art: unknown damage %v", d)
+		}
+
+		errs, max, err := coding.BlockErrors(want, got)
+		if err != nil {
+			return 0, err
+		}
+		for _, e := range errs {
+			if e > max {
+				return float64(damaged()) / float64(n*n), nil
+			}
+		}
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
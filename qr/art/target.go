@@ -0,0 +1,55 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package art
+
+import (
+	"image"
+	"image/draw"
+
+	"code.google.com/p/rsc/qr/web/resize"
+)
+
+// MakeTarget returns a target for Image.Target showing m,
+// scaled to fit within a max×max square.
+// Fully transparent pixels in m become -1 (don't care).
+func MakeTarget(m image.Image, max int) [][]int {
+	b := m.Bounds()
+	if b.Dx() <= 0 || b.Dy() <= 0 {
+		return nil
+	}
+	dx, dy := max, max
+	if b.Dx() > b.Dy() {
+		dy = b.Dy() * dx / b.Dx()
+	} else {
+		dx = b.Dx() * dy / b.Dy()
+	}
+	if dx < 1 {
+		dx = 1
+	}
+	if dy < 1 {
+		dy = 1
+	}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), m, b.Min, draw.Src)
+	i := resize.ResizeRGBA(rgba, rgba.Bounds(), dx, dy)
+
+	targ := make([][]int, dy)
+	arr := make([]int, dx*dy)
+	for y := 0; y < dy; y++ {
+		targ[y], arr = arr[:dx], arr[dx:]
+		row := targ[y]
+		for x := 0; x < dx; x++ {
+			p := i.Pix[y*i.Stride+4*x:]
+			r, g, b, a := p[0], p[1], p[2], p[3]
+			if a == 0 {
+				row[x] = -1
+			} else {
+				row[x] = int((299*uint32(r) + 587*uint32(g) + 114*uint32(b) + 500) / 1000)
+			}
+		}
+	}
+	return targ
+}
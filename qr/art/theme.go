@@ -0,0 +1,284 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package art
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+
+	"code.google.com/p/rsc/qr"
+	"code.google.com/p/rsc/qr/coding"
+)
+
+// A Shape is the shape used to draw a dark module.
+//
+// Every shape covers the middle third of its module,
+// where scanners sample, so any shape can be used for any
+// module without changing what the code says.  The finder
+// patterns keep their 1:1:3:1:1 proportions along the lines
+// through module centers, which is what scanners measure.
+type Shape int
+
+const (
+	Square  Shape = iota // the whole module
+	Circle               // a disk touching the module's edges
+	Diamond              // a square rotated 45°, touching the edges
+	Rounded              // a square with rounded corners
+)
+
+var shapeNames = []string{
+	Square:  "square",
+	Circle:  "circle",
+	Diamond: "diamond",
+	Rounded: "rounded",
+}
+
+func (s Shape) String() string {
+	if 0 <= s && int(s) < len(shapeNames) {
+		return shapeNames[s]
+	}
+	return fmt.Sprintf("Shape(%d)", int(s))
+}
+
+// inside reports whether the point (fx, fy), measured in modules
+// from the module's top left corner, lies inside shape s.
+func (s Shape) inside(fx, fy float64) bool {
+	dx, dy := fx-0.5, fy-0.5
+	if dx < 0 {
+		dx = -dx
+	}
+	if dy < 0 {
+		dy = -dy
+	}
+	switch s {
+	case Circle:
+		return dx*dx+dy*dy <= 0.25
+	case Diamond:
+		return dx+dy <= 0.5
+	case Rounded:
+		const r = 0.25
+		if dx <= 0.5-r || dy <= 0.5-r {
+			return true
+		}
+		dx -= 0.5 - r
+		dy -= 0.5 - r
+		return dx*dx+dy*dy <= r*r
+	}
+	return true
+}
+
+// A Theme gives a style for drawing codes: a shape for the dark
+// modules of each kind of pattern, and the dark and light colors.
+// Format and version information are drawn with the Data shape.
+type Theme struct {
+	Name      string
+	Finder    Shape // position boxes
+	Alignment Shape // alignment boxes
+	Timing    Shape // timing patterns
+	Data      Shape // everything else
+
+	// Dark and Light are the module colors.
+	// If nil, they default to black and white.
+	Dark, Light color.Color
+}
+
+// Themes lists the predefined themes.
+var Themes = []*Theme{
+	{Name: "plain"},
+	{Name: "dots", Alignment: Circle, Timing: Circle, Data: Circle},
+	{Name: "rounded", Finder: Rounded, Alignment: Rounded, Timing: Rounded, Data: Rounded},
+	{Name: "diamond", Timing: Diamond, Data: Diamond},
+	{Name: "ink", Alignment: Circle, Data: Circle,
+		Dark: color.RGBA{0x1a, 0x23, 0x7e, 0xff}, Light: color.RGBA{0xff, 0xfb, 0xf0, 0xff}},
+}
+
+// LookupTheme returns the predefined theme with the given name,
+// or nil if there is none.
+func LookupTheme(name string) *Theme {
+	for _, t := range Themes {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// minThemeContrast is the smallest reflectance difference
+// between a theme's dark and light colors.
+const minThemeContrast = 0.4
+
+// colors returns t's dark and light colors, checking their contrast
+// unless Validation is Lenient.
+func (t *Theme) colors() (dark, light color.Color, err error) {
+	dark, light = t.Dark, t.Light
+	if dark == nil {
+		dark = color.Black
+	}
+	if light == nil {
+		light = color.White
+	}
+	if Validation == Strict && Reflectance(light)-Reflectance(dark) < minThemeContrast {
+		return nil, nil, fmt.Errorf("art: theme %q: insufficient contrast between dark and light colors", t.Name)
+	}
+	return dark, light, nil
+}
+
+// shapes returns the shape of each module of c.
+func (t *Theme) shapes(c *qr.Code, rotation int) ([][]Shape, error) {
+	for _, s := range []Shape{t.Finder, t.Alignment, t.Timing, t.Data} {
+		if s < 0 || int(s) >= len(shapeNames) {
+			return nil, fmt.Errorf("art: theme %q: unknown shape %v", t.Name, s)
+		}
+	}
+	p, err := codePlan(c, rotation)
+	if err != nil {
+		return nil, err
+	}
+	s := make([][]Shape, c.Size)
+	for y, row := range p.Pixel {
+		s[y] = make([]Shape, c.Size)
+		for x, pix := range row {
+			switch pix.Role() {
+			case coding.Position:
+				s[y][x] = t.Finder
+			case coding.Alignment:
+				s[y][x] = t.Alignment
+			case coding.Timing:
+				s[y][x] = t.Timing
+			default:
+				s[y][x] = t.Data
+			}
+		}
+	}
+	return s, nil
+}
+
+// Image returns an image of c drawn in theme t, with c.Scale pixels
+// per module and a 4-module quiet zone.  The rotation must be the
+// one used to create c, or 0 for codes made by qr.Encode.
+//
+// Shapes other than Square need at least 3 pixels per module to
+// be drawn faithfully.  Image reads the result back the way a
+// scanner would and returns an error if it does not decode.
+func (t *Theme) Image(c *qr.Code, rotation int) (*image.RGBA, error) {
+	dark, light, err := t.colors()
+	if err != nil {
+		return nil, err
+	}
+	shapes, err := t.shapes(c, rotation)
+	if err != nil {
+		return nil, err
+	}
+	scale := c.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+	for _, row := range shapes {
+		for _, s := range row {
+			if s != Square && scale < 3 {
+				return nil, fmt.Errorf("art: theme %q: scale %d too small for %v modules", t.Name, scale, s)
+			}
+		}
+	}
+
+	d := (c.Size + 8) * scale
+	m := image.NewRGBA(image.Rect(0, 0, d, d))
+	dc := color.RGBAModel.Convert(dark).(color.RGBA)
+	lc := color.RGBAModel.Convert(light).(color.RGBA)
+	for y := 0; y < d; y++ {
+		for x := 0; x < d; x++ {
+			col := lc
+			mx, my := x/scale-4, y/scale-4
+			if c.Black(mx, my) {
+				fx := (float64(x%scale) + 0.5) / float64(scale)
+				fy := (float64(y%scale) + 0.5) / float64(scale)
+				if shapes[my][mx].inside(fx, fy) {
+					col = dc
+				}
+			}
+			m.SetRGBA(x, y, col)
+		}
+	}
+
+	if err := verify(m, c, scale); err != nil {
+		return nil, fmt.Errorf("art: theme %q: %v", t.Name, err)
+	}
+	return m, nil
+}
+
+// verify checks that m, an image of c, reads back as c.
+func verify(m image.Image, c *qr.Code, scale int) error {
+	got, err := readBack(m, c.Size, scale)
+	if err != nil {
+		return err
+	}
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if got.Black(x, y) != c.Black(x, y) {
+				return fmt.Errorf("module %d,%d reads incorrectly", x, y)
+			}
+		}
+	}
+	return nil
+}
+
+// SVG writes to w an SVG image of c drawn in theme t.
+// The image measures c.Scale user units per module, including
+// a 4-module quiet zone, and scales to any size without loss.
+// The rotation must be the one used to create c.
+func (t *Theme) SVG(w io.Writer, c *qr.Code, rotation int) error {
+	dark, light, err := t.colors()
+	if err != nil {
+		return err
+	}
+	shapes, err := t.shapes(c, rotation)
+	if err != nil {
+		return err
+	}
+	scale := c.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+
+	b := bufio.NewWriter(w)
+	d := c.Size + 8
+	fmt.Fprintf(b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n",
+		d*scale, d*scale, d, d)
+	fmt.Fprintf(b, "<rect width=\"%d\" height=\"%d\" fill=\"%s\"/>\n", d, d, hexColor(light))
+	fmt.Fprintf(b, "<g fill=\"%s\">\n", hexColor(dark))
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.Black(x, y) {
+				continue
+			}
+			x0, y0 := x+4, y+4
+			switch shapes[y][x] {
+			case Square:
+				fmt.Fprintf(b, "<rect x=\"%d\" y=\"%d\" width=\"1\" height=\"1\"/>\n", x0, y0)
+			case Circle:
+				fmt.Fprintf(b, "<circle cx=\"%d.5\" cy=\"%d.5\" r=\"0.5\"/>\n", x0, y0)
+			case Diamond:
+				fmt.Fprintf(b, "<polygon points=\"%d.5,%d %d,%d.5 %d.5,%d %d,%d.5\"/>\n",
+					x0, y0, x0+1, y0, x0, y0+1, x0, y0)
+			case Rounded:
+				fmt.Fprintf(b, "<rect x=\"%d\" y=\"%d\" width=\"1\" height=\"1\" rx=\"0.25\"/>\n", x0, y0)
+			}
+		}
+	}
+	fmt.Fprintf(b, "</g>\n</svg>\n")
+	return b.Flush()
+}
+
+// hexColor returns the #rrggbb form of c, composited over white.
+func hexColor(c color.Color) string {
+	r, g, b, a := c.RGBA()
+	r += 0xffff - a
+	g += 0xffff - a
+	b += 0xffff - a
+	return fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
+}
@@ -0,0 +1,70 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package art
+
+import (
+	"fmt"
+	"image"
+
+	"code.google.com/p/rsc/qr"
+	"code.google.com/p/rsc/qr/coding"
+)
+
+// A Strictness says how the package treats codes and images
+// that break the QR specification but may still scan.
+type Strictness int
+
+const (
+	// Strict rejects anything outside the specification.
+	// It suits compliance tooling.
+	Strict Strictness = iota
+
+	// Lenient permits departures from the specification,
+	// reporting them as warnings instead.  It suits creative
+	// tooling, where a narrow quiet zone or a pale palette
+	// may be a deliberate choice.
+	Lenient
+)
+
+// Validation is the package's strictness.  It governs Validate
+// and the contrast check made when drawing a theme.
+var Validation = Strict
+
+// Validate checks that m, an image of c, follows the specification:
+// the format information must record a standard mask and level
+// exactly, the quiet zone must be at least 4 modules wide, and dark
+// and light modules must differ in reflectance by at least 0.4.
+// The image is located and sampled as in EstimateScannability.
+//
+// In Strict mode, Validate returns an error describing the first
+// problem.  In Lenient mode, it returns every problem as a warning
+// and a nil error.
+func Validate(m image.Image, c *qr.Code) (warnings []string, err error) {
+	var problems []string
+	cc := &coding.Code{Bitmap: c.Bitmap, Size: c.Size, Stride: c.Stride}
+	if _, _, n, err := coding.FormatErrors(cc); err != nil {
+		problems = append(problems, "format information does not record a standard mask and level")
+	} else if n > 0 {
+		problems = append(problems, fmt.Sprintf("format information has %d incorrect bits", n))
+	}
+
+	r := EstimateScannability(m, c, nil)
+	switch {
+	case r.ModulePixels == 0:
+		problems = append(problems, "no dark modules in image")
+	default:
+		if r.QuietZone < 4 {
+			problems = append(problems, fmt.Sprintf("quiet zone is %d modules wide, want 4", r.QuietZone))
+		}
+		if r.Contrast < minThemeContrast {
+			problems = append(problems, fmt.Sprintf("contrast is %.2f, want at least %.1f", r.Contrast, minThemeContrast))
+		}
+	}
+
+	if len(problems) > 0 && Validation == Strict {
+		return nil, fmt.Errorf("art: %s", problems[0])
+	}
+	return problems, nil
+}
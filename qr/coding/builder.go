@@ -0,0 +1,117 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coding
+
+import (
+	"fmt"
+	"image"
+)
+
+// A PlanBuilder makes plans with a non-standard layout, for
+// experiments with variants of the symbology.  It uses the same
+// finder patterns, timing strips, version and format information,
+// data placement, and masks as NewPlan, but the caller chooses where
+// the alignment patterns go and can reserve regions of the symbol
+// that hold no data.
+//
+// The number of codewords in a built plan is however many fit in
+// the pixels that remain, so it is usually not the standard number.
+// The block count and check bytes per block are the standard ones
+// for the version and level.  Standard readers cannot decode such
+// codes, and the plans do not pass Validate unless the alignment
+// patterns are the standard ones.  Built plans with explicit Align or
+// Reserved settings are marked Custom.
+type PlanBuilder struct {
+	Version Version
+	Level   Level
+	Mask    Mask
+
+	// Align lists the alignment pattern centers.
+	// If Align is nil, Build uses AlignmentCenters(Version);
+	// to build a plan with no alignment patterns, use an empty,
+	// non-nil slice.
+	Align []image.Point
+
+	// Reserved lists rectangles of pixels to exclude from the
+	// data area.  Build marks those pixels Unused and leaves them
+	// white.  Reserved pixels cannot overlap the fixed patterns.
+	Reserved []image.Rectangle
+}
+
+// Build returns the plan described by b.
+func (b *PlanBuilder) Build() (*Plan, error) {
+	v, l, m := b.Version, b.Level, b.Mask
+	if v < MinVersion || v > MaxVersion {
+		return nil, fmt.Errorf("%w %d", ErrInvalidVersion, int(v))
+	}
+	if l < L || l > H {
+		return nil, fmt.Errorf("%w %d", ErrInvalidLevel, int(l))
+	}
+	if m < Mask0 || m > Mask7 {
+		return nil, fmt.Errorf("%w %d", ErrInvalidMask, int(m))
+	}
+
+	align := b.Align
+	if align == nil {
+		align = AlignmentCenters(v)
+	}
+	siz := 17 + 4*int(v)
+	bounds := image.Rect(0, 0, siz, siz)
+	fixed, err := vplan(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := fplan(l, m, fixed); err != nil {
+		return nil, err
+	}
+	for _, c := range align {
+		box := image.Rect(c.X-2, c.Y-2, c.X+3, c.Y+3)
+		if !box.In(bounds) {
+			return nil, fmt.Errorf("alignment pattern at %d,%d is outside the symbol", c.X, c.Y)
+		}
+		for y := box.Min.Y; y < box.Max.Y; y++ {
+			for x := box.Min.X; x < box.Max.X; x++ {
+				switch fixed.Pixel[y][x].Role() {
+				case Position, Format, PVersion, Dark:
+					return nil, fmt.Errorf("alignment pattern at %d,%d overlaps %v pixel %d,%d", c.X, c.Y, fixed.Pixel[y][x].Role(), x, y)
+				}
+			}
+		}
+	}
+
+	p := vplanAlign(v, align)
+	if err := fplan(l, m, p); err != nil {
+		return nil, err
+	}
+	for _, r := range b.Reserved {
+		r = r.Canon().Intersect(bounds)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				if role := p.Pixel[y][x].Role(); role != 0 && role != Unused {
+					return nil, fmt.Errorf("reserved region %v overlaps %v pixel %d,%d", r, role, x, y)
+				}
+				p.Pixel[y][x] = Unused.Pixel()
+			}
+		}
+	}
+
+	free := 0
+	for _, row := range p.Pixel {
+		for _, pix := range row {
+			if pix.Role() == 0 {
+				free++
+			}
+		}
+	}
+	lev := &vtab[v].level[l]
+	if err := layout(p, l, free/8, lev.nblock, lev.check); err != nil {
+		return nil, err
+	}
+	if err := mplan(m, p); err != nil {
+		return nil, err
+	}
+	p.Custom = b.Align != nil || len(b.Reserved) > 0
+	return p, nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package conformance holds known-good QR code encodings
+// and a runner that checks package coding against them.
+//
+// Each vector's Source says where its codewords and matrix come
+// from.  None come from package coding: the codewords are from
+// published worked examples where there is one, and every matrix,
+// and the remaining codewords, are from Kazuhiko Arase's QRCode for
+// JavaScript, an independent encoder.  testdata/vectors.js prints
+// that encoder's output for comparison.
+package conformance
+
+import (
+	"bytes"
+	"fmt"
+
+	"code.google.com/p/rsc/qr/coding"
+)
+
+// A Vector is a single conformance vector.
+type Vector struct {
+	Name    string
+	Payload string
+	Mode    string // "numeric", "alphanumeric", or "byte"
+	Version coding.Version
+	Level   coding.Level
+	Mask    coding.Mask
+	Source  string // where the codewords and matrix come from
+
+	// Codewords holds the data codewords, block by block,
+	// followed by the check codewords, block by block,
+	// as produced by coding.Bits.AddCheckBytes.
+	// Codewords are interleaved only when placed in the matrix.
+	Codewords []byte
+
+	// Matrix holds the symbol, without quiet zone,
+	// one string per row, with # for dark and . for light.
+	Matrix []string
+}
+
+// encoding returns the coding.Encoding for v's payload.
+func (v *Vector) encoding() (coding.Encoding, error) {
+	switch v.Mode {
+	case "numeric":
+		return coding.Num(v.Payload), nil
+	case "alphanumeric":
+		return coding.Alpha(v.Payload), nil
+	case "byte":
+		return coding.String(v.Payload), nil
+	}
+	return nil, fmt.Errorf("unknown mode %q", v.Mode)
+}
+
+// Run checks that package coding reproduces v exactly
+// and decodes its matrix back to the payload.
+func Run(v *Vector) error {
+	enc, err := v.encoding()
+	if err != nil {
+		return fmt.Errorf("%s: %v", v.Name, err)
+	}
+
+	var b coding.Bits
+	enc.Encode(&b, v.Version)
+	b.Pad(v.Version.DataBytes(v.Level)*8 - b.Bits())
+	b.AddCheckBytes(v.Version, v.Level)
+	if cw := b.Bytes(); !bytes.Equal(cw, v.Codewords) {
+		return fmt.Errorf("%s: codewords are\n\t% x\nwant\n\t% x", v.Name, cw, v.Codewords)
+	}
+
+	p, err := coding.NewPlan(v.Version, v.Level, v.Mask)
+	if err != nil {
+		return fmt.Errorf("%s: %v", v.Name, err)
+	}
+	c, err := p.Encode(enc)
+	if err != nil {
+		return fmt.Errorf("%s: %v", v.Name, err)
+	}
+	if len(v.Matrix) != c.Size {
+		return fmt.Errorf("%s: matrix has %d rows, code has %d", v.Name, len(v.Matrix), c.Size)
+	}
+	for y, row := range v.Matrix {
+		if len(row) != c.Size {
+			return fmt.Errorf("%s: matrix row %d has %d modules, want %d", v.Name, y, len(row), c.Size)
+		}
+		for x := 0; x < c.Size; x++ {
+			if c.Black(x, y) != (row[x] == '#') {
+				return fmt.Errorf("%s: module %d,%d differs", v.Name, x, y)
+			}
+		}
+	}
+
+	out, err := coding.Decode(c)
+	if err != nil {
+		return fmt.Errorf("%s: decode: %v", v.Name, err)
+	}
+	if string(out) != v.Payload {
+		return fmt.Errorf("%s: decodes to %q, want %q", v.Name, out, v.Payload)
+	}
+	return nil
+}
+
+// RunAll runs every vector in Vectors and returns the errors.
+func RunAll() []error {
+	var errs []error
+	for i := range Vectors {
+		if err := Run(&Vectors[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
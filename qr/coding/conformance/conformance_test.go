@@ -0,0 +1,36 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conformance
+
+import "testing"
+
+func TestVectors(t *testing.T) {
+	for _, err := range RunAll() {
+		t.Error(err)
+	}
+	for _, v := range Vectors {
+		if v.Source == "" {
+			t.Errorf("%s: no source", v.Name)
+		}
+	}
+}
+
+func TestRunDetects(t *testing.T) {
+	v := Vectors[0]
+	v.Matrix = append([]string(nil), v.Matrix...)
+	row := []byte(v.Matrix[10])
+	row[10] ^= '#' ^ '.'
+	v.Matrix[10] = string(row)
+	if err := Run(&v); err == nil {
+		t.Errorf("Run accepted damaged matrix")
+	}
+
+	v = Vectors[0]
+	v.Codewords = append([]byte(nil), v.Codewords...)
+	v.Codewords[20]++
+	if err := Run(&v); err == nil {
+		t.Errorf("Run accepted wrong codewords")
+	}
+}
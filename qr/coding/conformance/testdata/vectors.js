@@ -0,0 +1,117 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This program prints the codewords and matrix of each conformance
+// vector as made by Kazuhiko Arase's QRCode for JavaScript, an encoder
+// independent of package coding, for comparison with vectors.go.
+// The library ships with npm as part of qrcode-terminal:
+//
+//	node vectors.js $(npm root -g)/npm/node_modules/qrcode-terminal/vendor/QRCode
+//
+// The library has only byte mode, so numeric and alphanumeric
+// segments are written here, following ISO/IEC 18004 §7.4.3 and
+// §7.4.4; everything after the data bit stream — padding, check
+// bytes, interleaving, placement, masking, format and version
+// information — is the library's own.
+
+var dir = process.argv[2];
+var QRCode = require(dir);
+var QR8bitByte = require(dir + '/QR8bitByte');
+var QRMode = require(dir + '/QRMode');
+var QRRSBlock = require(dir + '/QRRSBlock');
+var QRUtil = require(dir + '/QRUtil');
+var QRPolynomial = require(dir + '/QRPolynomial');
+var Level = require(dir + '/QRErrorCorrectLevel');
+
+function Numeric(s) {
+	this.mode = QRMode.MODE_NUMBER;
+	this.data = s;
+}
+Numeric.prototype = {
+	getLength: function() { return this.data.length; },
+	write: function(buf) {
+		var s = this.data;
+		for (var i = 0; i < s.length; i += 3) {
+			var g = s.substring(i, i + 3);
+			buf.put(parseInt(g, 10), [0, 4, 7, 10][g.length]);
+		}
+	}
+};
+
+var alphabet = '0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:';
+
+function Alpha(s) {
+	this.mode = QRMode.MODE_ALPHA_NUM;
+	this.data = s;
+}
+Alpha.prototype = {
+	getLength: function() { return this.data.length; },
+	write: function(buf) {
+		var s = this.data;
+		for (var i = 0; i + 1 < s.length; i += 2) {
+			buf.put(alphabet.indexOf(s[i]) * 45 + alphabet.indexOf(s[i + 1]), 11);
+		}
+		if (s.length % 2 == 1) {
+			buf.put(alphabet.indexOf(s[s.length - 1]), 6);
+		}
+	}
+};
+
+var vectors = [
+	['ISO 18004 Annex I', '01234567', Numeric, 1, 'M', 2],
+	['HELLO WORLD 1-Q', 'HELLO WORLD', Alpha, 1, 'Q', 6],
+	['HELLO WORLD 5-Q', 'HELLO WORLD', Alpha, 5, 'Q', 3],
+	['URL 7-H', 'http://swtch.com/qr', QR8bitByte, 7, 'H', 5],
+	['pi 10-L', '31415926535897932384626433832795028841971693993751', Numeric, 10, 'L', 0],
+];
+
+function hex(b) {
+	return '0x' + (b < 16 ? '0' : '') + b.toString(16);
+}
+
+vectors.forEach(function(v) {
+	var qr = new QRCode(v[3], Level[v[4]]);
+	qr.dataList.push(new v[2](v[1]));
+	qr.makeImpl(false, v[5]);
+
+	// Undo the interleaving of the codewords, to list them
+	// block by block as package coding does.
+	var blocks = QRRSBlock.getRSBlocks(v[3], Level[v[4]]);
+	// The library leaves some zero coefficients undefined;
+	// its placement code reads them as 0, and so do we.
+	var cw = qr.dataCache.map(function(b) { return b | 0; });
+	var data = [], check = [], k = 0;
+	var maxd = 0, maxc = 0;
+	blocks.forEach(function(b, i) {
+		data[i] = [];
+		check[i] = [];
+		maxd = Math.max(maxd, b.dataCount);
+		maxc = Math.max(maxc, b.totalCount - b.dataCount);
+	});
+	for (var j = 0; j < maxd; j++) {
+		blocks.forEach(function(b, i) {
+			if (j < b.dataCount) data[i].push(cw[k++]);
+		});
+	}
+	for (var j = 0; j < maxc; j++) {
+		blocks.forEach(function(b, i) {
+			if (j < b.totalCount - b.dataCount) check[i].push(cw[k++]);
+		});
+	}
+	var all = [].concat.apply([], data.concat(check));
+
+	console.log('// ' + v[0]);
+	console.log('Codewords:');
+	for (var i = 0; i < all.length; i += 12) {
+		console.log('\t' + all.slice(i, i + 12).map(hex).join(', ') + ',');
+	}
+	console.log('Matrix:');
+	for (var y = 0; y < qr.getModuleCount(); y++) {
+		var row = '';
+		for (var x = 0; x < qr.getModuleCount(); x++) {
+			row += qr.isDark(y, x) ? '#' : '.';
+		}
+		console.log('\t"' + row + '",');
+	}
+});
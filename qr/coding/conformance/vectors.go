@@ -0,0 +1,322 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conformance
+
+import "code.google.com/p/rsc/qr/coding"
+
+// Vectors holds the conformance vectors.
+var Vectors = []Vector{
+	{
+		Name:    "ISO 18004 Annex I",
+		Payload: "01234567",
+		Mode:    "numeric",
+		Version: 1,
+		Level:   coding.M,
+		Mask:    2,
+		Source: "Codewords from the worked example in ISO/IEC 18004 Annex I; " +
+			"matrix from Kazuhiko Arase's QRCode for JavaScript, not yet checked against the standard's figure.",
+		Codewords: []byte{
+			0x10, 0x20, 0x0c, 0x56, 0x61, 0x80, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11,
+			0xec, 0x11, 0xec, 0x11, 0xa5, 0x24, 0xd4, 0xc1, 0xed, 0x36, 0xc7, 0x87,
+			0x2c, 0x55,
+		},
+		Matrix: []string{
+			"#######..#.##.#######",
+			"#.....#..####.#.....#",
+			"#.###.#.#.....#.###.#",
+			"#.###.#.##....#.###.#",
+			"#.###.#.#.###.#.###.#",
+			"#.....#.#...#.#.....#",
+			"#######.#.#.#.#######",
+			"........#..##........",
+			"#.#####..#..#.#####..",
+			"...#.#.##.#.#..#.##..",
+			"..#...##.#.#.#..#####",
+			"....#....#.....####..",
+			"...######..#.#..#....",
+			"........#.#####..##..",
+			"#######..##.#.##.....",
+			"#.....#.#.#####...#.#",
+			"#.###.#.#...#..#.##..",
+			"#.###.#.##..#..#.....",
+			"#.###.#.#.##.#..#.#..",
+			"#.....#........##.##.",
+			"#######.####.#..#.#..",
+		},
+	},
+	{
+		Name:    "HELLO WORLD 1-Q",
+		Payload: "HELLO WORLD",
+		Mode:    "alphanumeric",
+		Version: 1,
+		Level:   coding.Q,
+		Mask:    6,
+		Source:  "Codewords from the thonky.com QR code tutorial; matrix from Kazuhiko Arase's QRCode for JavaScript.",
+		Codewords: []byte{
+			0x20, 0x5b, 0x0b, 0x78, 0xd1, 0x72, 0xdc, 0x4d, 0x43, 0x40, 0xec, 0x11,
+			0xec, 0xa8, 0x48, 0x16, 0x52, 0xd9, 0x36, 0x9c, 0x00, 0x2e, 0x0f, 0xb4,
+			0x7a, 0x10,
+		},
+		Matrix: []string{
+			"#######....#..#######",
+			"#.....#.##..#.#.....#",
+			"#.###.#..#.##.#.###.#",
+			"#.###.#.#####.#.###.#",
+			"#.###.#.##.#..#.###.#",
+			"#.....#..#..#.#.....#",
+			"#######.#.#.#.#######",
+			"........##.##........",
+			".#.####.##..###.##.#.",
+			"#.####.#....####.###.",
+			"..#.#.##...#..##.....",
+			"#.##.#...#.##...##...",
+			"##.########.###.#####",
+			"........#...#..#.#...",
+			"#######..##..##..####",
+			"#.....#.#.#..#..#.###",
+			"#.###.#.##.#..#...###",
+			"#.###.#.#.###...#.#..",
+			"#.###.#..#....#....##",
+			"#.....#.###..###..##.",
+			"#######..#.#.......#.",
+		},
+	},
+	{
+		Name:    "HELLO WORLD 5-Q",
+		Payload: "HELLO WORLD",
+		Mode:    "alphanumeric",
+		Version: 5,
+		Level:   coding.Q,
+		Mask:    3,
+		Source:  "Codewords and matrix from Kazuhiko Arase's QRCode for JavaScript.",
+		Codewords: []byte{
+			0x20, 0x5b, 0x0b, 0x78, 0xd1, 0x72, 0xdc, 0x4d, 0x43, 0x40, 0xec, 0x11,
+			0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11,
+			0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11,
+			0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11,
+			0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11,
+			0xec, 0x11, 0x8d, 0xc8, 0x71, 0x9b, 0x65, 0xfd, 0xd3, 0x89, 0xe6, 0x0c,
+			0x25, 0x5d, 0x34, 0x06, 0xb4, 0x90, 0xe9, 0xd5, 0x08, 0x7f, 0x3a, 0xd5,
+			0x45, 0x56, 0xd1, 0x22, 0x04, 0xce, 0x4b, 0xe5, 0x52, 0x75, 0xf5, 0xfd,
+			0xdf, 0x00, 0xfd, 0xd0, 0xd0, 0xde, 0x94, 0x25, 0x8d, 0x82, 0xe3, 0x30,
+			0xb6, 0xf1, 0x67, 0xfd, 0x25, 0x0d, 0xab, 0x10, 0xfd, 0xd0, 0xd0, 0xde,
+			0x94, 0x25, 0x8d, 0x82, 0xe3, 0x30, 0xb6, 0xf1, 0x67, 0xfd, 0x25, 0x0d,
+			0xab, 0x10,
+		},
+		Matrix: []string{
+			"#######..#.#.###..#..#...###..#######",
+			"#.....#.#.###.#.#.#......#..#.#.....#",
+			"#.###.#.##.#......###..##...#.#.###.#",
+			"#.###.#..####.#.#.##.#.##.###.#.###.#",
+			"#.###.#..##.##.#.##.########..#.###.#",
+			"#.....#..#..##...#.###.#..#.#.#.....#",
+			"#######.#.#.#.#.#.#.#.#.#.#.#.#######",
+			".............##.#.#..#..###.#........",
+			".###.##....#####.#..#..####.......##.",
+			".###.#..#.############..#..#..####.##",
+			".#..###..##...#..###...##...#....#.#.",
+			"..#.#...#...#..##.#.#....#..###.#.##.",
+			"###..###.#.....##.#..#..#..##.#.....#",
+			".#.......####...#...#.#####..###...#.",
+			".##...####......#.#...##..#..####.###",
+			"#.##...#.####.......####.#.#...####..",
+			"...#..##...#.##..#.#....#.....#####.#",
+			".###.#.##......#.#.###...#.....#..###",
+			"#.#...###......##.###.#.......##.....",
+			".###...#..#...#.#.##.#.#...##...#....",
+			".####.#.#........#..#...##.#.....###.",
+			"#..##...##..##.###..##..#.#####...#.#",
+			"....#.##..###.######...##.##.#.##.#.#",
+			"..##.#..#..#.#.#..#.#....##..#..##..#",
+			"#.#..####...##.....#.#..#..#..##.#.#.",
+			"..##........###.#.#.######..#...####.",
+			".##.#.####.#..#...#..#.#..#####....##",
+			"#..##..###.....#.#.....#.#..###...#..",
+			"..##..#.###..#.#..#...#.#..######...#",
+			"........#...##...#.####..#..#...####.",
+			"#######...###..##..#..#....##.#.#.#.#",
+			"#.....#.#..#..#...#.##.#...##...#.#..",
+			"#.###.#..#..####.##.#....#.######.#..",
+			"#.###.#.######.##.#..#...#####.#..#.#",
+			"#.###.#.#..#.#....#......#.###..#.##.",
+			"#.....#.#.####.....#...##..##....#..#",
+			"#######...##.#.##...##.##.##.##..#..#",
+		},
+	},
+	{
+		Name:    "URL 7-H",
+		Payload: "http://swtch.com/qr",
+		Mode:    "byte",
+		Version: 7,
+		Level:   coding.H,
+		Mask:    5,
+		Source:  "Codewords and matrix from Kazuhiko Arase's QRCode for JavaScript.",
+		Codewords: []byte{
+			0x41, 0x36, 0x87, 0x47, 0x47, 0x03, 0xa2, 0xf2, 0xf7, 0x37, 0x77, 0x46,
+			0x36, 0x82, 0xe6, 0x36, 0xf6, 0xd2, 0xf7, 0x17, 0x20, 0xec, 0x11, 0xec,
+			0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec,
+			0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec,
+			0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec,
+			0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x8d, 0xc8, 0xd1, 0x6f, 0x7f, 0x3a,
+			0xa6, 0xa2, 0xb1, 0x6a, 0xc8, 0x37, 0x9d, 0x37, 0x38, 0x30, 0x97, 0x45,
+			0x20, 0xee, 0x72, 0xe6, 0x37, 0x70, 0xdb, 0x4c, 0xd0, 0xea, 0x87, 0x34,
+			0xf7, 0x1b, 0x54, 0x5d, 0xe1, 0xa2, 0x3c, 0x79, 0x93, 0xc6, 0x03, 0xe8,
+			0x19, 0x9b, 0x62, 0x6e, 0x4e, 0xd5, 0x70, 0xfd, 0xcb, 0xdd, 0xb5, 0x6c,
+			0xca, 0xf7, 0x7d, 0x7b, 0x71, 0x94, 0x9c, 0x80, 0xe6, 0x5f, 0x65, 0x40,
+			0xce, 0xb4, 0x94, 0xde, 0x21, 0x5a, 0x4b, 0x91, 0xe8, 0xb5, 0xd8, 0x82,
+			0xa2, 0x6c, 0x86, 0x12, 0x41, 0x1a, 0x7f, 0xd9, 0x7a, 0xf7, 0x2a, 0x63,
+			0x37, 0x16, 0x1f, 0xcf, 0x4f, 0x13, 0xe7, 0x01, 0x55, 0xb9, 0xec, 0x1e,
+			0x58, 0x82, 0xe9, 0x37, 0xe8, 0x04, 0x12, 0xef, 0x5e, 0x0c, 0x91, 0x02,
+			0x3e, 0x6e, 0x7b, 0xd3, 0x4c, 0x61, 0xc2, 0x8e, 0x53, 0xf1, 0x99, 0x8a,
+			0x65, 0xc9, 0x74, 0x03,
+		},
+		Matrix: []string{
+			"#######.##.......##..#.#..#...#.....#.#######",
+			"#.....#..#...#.#.#.##.##...........#..#.....#",
+			"#.###.#.####...#.....#.####.##.....#..#.###.#",
+			"#.###.#..#.#.#...##.#..###.#...#.#.##.#.###.#",
+			"#.###.#.###.#...#...######.###..#####.#.###.#",
+			"#.....#..#.##.....###...####..###.....#.....#",
+			"#######.#.#.#.#.#.#.#.#.#.#.#.#.#.#.#.#######",
+			"........##.######...#...#.....##.###.........",
+			".....##..###....#...######.####...###.#.#.#.#",
+			"#.####.....##.#.#..#.###..#...#####.#.####.##",
+			"..##..####...###..#.#..#..#.###...####.#.....",
+			".##.##.....#..#..#...###....##...##.#....#.#.",
+			".####.#####.##.##..##.#.###.##...#.####..####",
+			"#..###.###..#...#.....####...#..####....#....",
+			"..##..##..#...#.#.###...#..##.####...##.#.###",
+			"#..#...#.#..##..##.#####.##...###.##.#.####..",
+			".##..###.#...#..######.########.....####.#.##",
+			"#..##..###..#....#.####.###...##....##.#..#.#",
+			"..##.####.##....#.###.#.#.....###.##...##...#",
+			"..###...#####..###....########..###.###.###.#",
+			".##.######..#####...#######....#.##.#####..##",
+			"..###...#...##.####.#...###.##..##.##...#.#..",
+			"#####.#.#....#...##.#.#.#####.##.#..#.#.####.",
+			"#.###...#...#.#..####...##.#...#....#...#.###",
+			"##.######..#.####..######..######.#.#####.###",
+			"##.###..#.##.#...#.#.#.#####.#.#...#..#..#.#.",
+			"##.#..#.....#.##.##..#...#..###....#.##......",
+			"#..##.....#..####..##.######.#..#.#.#.##.#.##",
+			"#...####.###.#.##.##..#......####.#.....#.#..",
+			"######..###.####.#.#.......##.#.##..##.#..#..",
+			"##.#..#...#.#####.#.##....#####....######.#..",
+			"#...#...##..##.##..#####.......#.....#.#..#.#",
+			"#.##.###......#.##..##...#..##.....#####.####",
+			".#...#..####.####...####..#..####.##...#.####",
+			"....#.##.####.#.#...#.#...#..##....#.####.#..",
+			".####...##.#.#...##...#..........##.#..#####.",
+			"#..##.#.##....#.....#####....#...#.######..##",
+			"........##......###.#...#.#.#.#.#####...##..#",
+			"#######...####..#..##.#.#.#....###..#.#.##...",
+			"#.....#.#.....#####.#...#.#..#.#..#.#...###.#",
+			"#.###.#....####.#.########.##..#....#####...#",
+			"#.###.#...######.#.##..##.#.#..##..#..##.#.#.",
+			"#.###.#....##.....#.#.###.....#.#.#.##.###.##",
+			"#.....#..#.##.........##.#...#...####..#.#...",
+			"#######..........####....####.#####.#.##..##.",
+		},
+	},
+	{
+		Name:    "pi 10-L",
+		Payload: "31415926535897932384626433832795028841971693993751",
+		Mode:    "numeric",
+		Version: 10,
+		Level:   coding.L,
+		Mask:    0,
+		Source:  "Codewords and matrix from Kazuhiko Arase's QRCode for JavaScript.",
+		Codewords: []byte{
+			0x10, 0x32, 0x4e, 0x89, 0xf4, 0x25, 0x66, 0xf4, 0xd4, 0x3d, 0x39, 0x08,
+			0x54, 0x94, 0x7e, 0xd9, 0x20, 0x68, 0xec, 0xce, 0xaf, 0xa9, 0x66, 0x00,
+			0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11,
+			0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11,
+			0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11,
+			0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11,
+			0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11,
+			0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11,
+			0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11,
+			0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11,
+			0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11,
+			0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11,
+			0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11,
+			0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11,
+			0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11,
+			0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11,
+			0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11,
+			0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11,
+			0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11,
+			0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11,
+			0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11,
+			0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11,
+			0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0x8a, 0xd8,
+			0x3f, 0x94, 0x68, 0x8c, 0x62, 0x36, 0x2a, 0x71, 0xa8, 0x23, 0x56, 0xcc,
+			0x4c, 0x79, 0x75, 0x50, 0x56, 0xbf, 0x53, 0x51, 0x3b, 0x3b, 0xec, 0x88,
+			0x53, 0xad, 0x0a, 0xb7, 0x1a, 0x7f, 0xf8, 0x34, 0x8f, 0xea, 0x27, 0x76,
+			0xbe, 0x91, 0x63, 0xd5, 0x77, 0xc3, 0xe2, 0xf0, 0x15, 0xbd, 0x2f, 0x33,
+			0xea, 0xfd, 0x78, 0x59, 0x11, 0x6f, 0xb5, 0x7b, 0x41, 0xf1, 0x1d, 0xc5,
+			0x6d, 0xef, 0xc4, 0xdb, 0xea, 0x88, 0x6f, 0x97, 0x11, 0xdf,
+		},
+		Matrix: []string{
+			"#######..######.#.##.##.##....####....####....##..#######",
+			"#.....#...#..#....####...#..#..#.#..#..#.#..#..#..#.....#",
+			"#.###.#.##.#.##.#..#.##.#.#.#.####..#.####.#..##..#.###.#",
+			"#.###.#..#..##....##.#......#..#.##.#..#.##.##.#..#.###.#",
+			"#.###.#..##..##.#.##.##.#.########....####.....#..#.###.#",
+			"#.....#.....##....####...##...##.#..#..#.#.##.#...#.....#",
+			"#######.#.#.#.#.#.#.#.#.#.#.#.#.#.#.#.#.#.#.#.#.#.#######",
+			"........####...#.##.#..#.##...#..#.#..#..#.#..##.........",
+			"###.#####.#.#.#.##.##.#.#######.####....####...####...#..",
+			"###.##..###.#...##.#....##.###...#.##.#..#.##.##..#####..",
+			"##.#.##..#..##....####...###.##.####..#.####..###.##..#.#",
+			"###.....#######.#..#.##.##.#..##.#....##.##....#..##.##..",
+			"..#..##.##.###..#.####..####...#.##.#..#....##..#..####.#",
+			"#.###..###.#.#..#..#.#..##.##.####....#####...#...#..#.#.",
+			"...#..#.###.##....####...###..##.##.#.##.##.#..##.##.###.",
+			"#..##..#..#..##.#..#.##.##.#..##.#....##.#....####.#.#.##",
+			"......######.#..#.####..####...#.##.#..#.##.#..#####.###.",
+			"##.....#..##.#..#..#.#..##.##.####....####....##.#.###.#.",
+			"##.#.###..#...#...####...###..##.##.#.##.##.#.######.###.",
+			".#####..........#..#.##.##....##.#....##.#.##.##.#...#.##",
+			".#.#..####.#..#.#.####..###.#..#.##.#..#.###....##..####.",
+			"#.#.##.....##...#..#.#..#.#...####....####.#..#.#.#...###",
+			"..#####.#.##......####......#.##.##.#.##.###..#..##.#..#.",
+			"#.##.#.##.##.#..#..#.##.#.#...##..#..#.#..#..#..##....##.",
+			"####..#..##.#..##.#.##.##.#.#..#....####....###.###.#.###",
+			".#.....#.#######....##.#..#...###.#..#.##.#..#..##....##.",
+			"#.#######....#####....##########..#.####..#.###.######.#.",
+			"###.#...##.....#.##.#..#.##...#...##.#.....#..#.#...#..##",
+			"#.#.#.#.#..#.#####..#.#####.#.#.#..#.##.#..#.####.#.#.##.",
+			"##..#...##.#####.#..#..#..#...#...####.....####.#...#.#..",
+			"...#######.#..####....###.#####.#.##.##.#..#.##.#####...#",
+			"###....#.#...###.##.#..#...#.#....##.#....##.#.##..#..#..",
+			"#.#.#.#.#.########..#.####.##.#.#..#.##.#..#.##...#.#.#.#",
+			".##....##.##..##.#..#..#...#......####....####.##.....#..",
+			".##..##..#.##.####....###..##.#.#.##.##.#.##.##.#.##....#",
+			"..###..###.##..#.##.#..#...#......##.#....##.#.##...#.#..",
+			"###..###.####.####..#.####.##.#.#..#.##.#..#.#####.##.#.#",
+			"........#..#...#.#..#..#...#......####....####..####.#.##",
+			"..#.###...####.###....###..##.#.#.##.##.#.##.###.#.####.#",
+			"##......###....#.##.#..#.#.#.....#.#..#..#.#..##.#.#.#..#",
+			"##.#.##.#...#...##.##.#.#.#.#.#.####....####......#.###.#",
+			"##.#.#.#.##..#..##.#....###.#....#.##.#..#.##.##..#.##...",
+			".#.#.###.####.....####....#...#.####..#.####..#.........#",
+			"##.#.#..#..##.#.#..#.##.###.#..#.#....##.#...#.#....#....",
+			".#.#..###.####..#.####..#.#.#.##.##.#..#..#.#....#..###.#",
+			"#.#.#...#.#...#.#..#.#..###.#..###....####...#.#.##.##.#.",
+			"#.#..##..#.#.##...####....#...##.##.#.##..#.#.#.##...###.",
+			"#####..######.#.#..#.##.###.#..#.#....##.#....##.#..##.##",
+			"......###.#..##.#.####..#.######.##.#..#.##.#..#########.",
+			"........###.###.#..#.#..###...####....####....###...##.#.",
+			"#######.####.##...####...##.#.##.##.#.##.##.#.#.#.#.####.",
+			"#.....#.#######.#..#.##.###...##.#....##.#.#..###...##.##",
+			"#.###.#.##.#.#..#.####..#.######.##.#..#.##.....########.",
+			"#.###.#....#..#.#..#.#..#..#..####....####.##.#...###.##.",
+			"#.###.#.#####.#...####....##...#.##.#.##.####.#.#..##...#",
+			"#.....#.#.#...#.#..#.##.#..##.##..#..#.#..#..#....###.#..",
+			"#######.#.##.#.##.#.##.##.##..##....####....###.#.###.#.#",
+		},
+	},
+}
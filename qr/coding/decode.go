@@ -0,0 +1,525 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coding
+
+import (
+	"errors"
+	"fmt"
+
+	"code.google.com/p/rsc/gf256"
+)
+
+// Decode decodes the QR code c and returns the data it holds.
+//
+// Decode reads an ideal bitmap, such as one produced by Plan.Encode,
+// not a photograph.  It accepts codes in any of the four orientations,
+// with or without a light margin, such as the quiet zone in codes
+// encoded from a plan made by WithQuietZone.
+// It reads the format information to find the level and mask,
+// uses the Reed-Solomon check bytes to correct up to
+// v.Correctable(l) damaged codewords in each block, and then
+// parses the data segments.
+func Decode(c *Code) ([]byte, error) {
+	c = trimMargin(c)
+	v := Version((c.Size - 17) / 4)
+	if c.Size != 17+4*int(v) || v < MinVersion || v > MaxVersion {
+		return nil, fmt.Errorf("invalid QR size %d", c.Size)
+	}
+	c, err := orient(c)
+	if err != nil {
+		return nil, err
+	}
+	l, m, err := readFormat(c)
+	if err != nil {
+		return nil, err
+	}
+	p, err := NewPlan(v, l, m)
+	if err != nil {
+		return nil, err
+	}
+	b := p.readBytes(c)
+	if err := correctBytes(v, l, b); err != nil {
+		return nil, err
+	}
+	return parseData(v, b[:p.DataBytes])
+}
+
+// trimMargin returns c without the light margin, if any, around
+// its black pixels.  The finder patterns reach three corners of the
+// symbol, so the black pixels span the whole symbol.  If they do not
+// make a square, trimMargin returns c unchanged.
+func trimMargin(c *Code) *Code {
+	x0, y0, x1, y1 := c.Size, c.Size, -1, -1
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.Black(x, y) {
+				if x < x0 {
+					x0 = x
+				}
+				if x > x1 {
+					x1 = x
+				}
+				if y < y0 {
+					y0 = y
+				}
+				y1 = y
+			}
+		}
+	}
+	n := x1 - x0 + 1
+	if x1 < 0 || n != y1-y0+1 || n == c.Size {
+		return c
+	}
+	r := &Code{Size: n, Stride: (n + 7) / 8}
+	r.Bitmap = make([]byte, r.Stride*n)
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			if c.Black(x0+x, y0+y) {
+				r.Bitmap[y*r.Stride+x/8] |= 1 << uint(7-x&7)
+			}
+		}
+	}
+	return r
+}
+
+// orient returns c rotated so that the corner without
+// a position box is at the bottom right.
+func orient(c *Code) (*Code, error) {
+	rot, err := orientation(c)
+	if err != nil {
+		return nil, err
+	}
+	return turn(c, rot), nil
+}
+
+// orientation returns the number of clockwise quarter turns
+// that move the corner of c without a position box to the bottom right.
+func orientation(c *Code) (int, error) {
+	n := c.Size
+	// box reports whether there is a position box
+	// with upper left corner at x, y.
+	box := func(x, y int) bool {
+		for dy := 0; dy < 7; dy++ {
+			for dx := 0; dx < 7; dx++ {
+				black := dx == 0 || dx == 6 || dy == 0 || dy == 6 || 2 <= dx && dx <= 4 && 2 <= dy && dy <= 4
+				if c.Black(x+dx, y+dy) != black {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	tl, tr, bl, br := box(0, 0), box(n-7, 0), box(0, n-7), box(n-7, n-7)
+	switch {
+	case tl && tr && bl && !br:
+		return 0, nil
+	case tl && !tr && bl && br:
+		return 1, nil // clockwise
+	case !tl && tr && bl && br:
+		return 2, nil // half turn
+	case tl && tr && !bl && br:
+		return 3, nil // counterclockwise
+	}
+	return 0, errors.New("cannot find position boxes")
+}
+
+// turn returns c turned clockwise by rot quarter turns.
+func turn(c *Code, rot int) *Code {
+	if rot == 0 {
+		return c
+	}
+	n := c.Size
+	r := &Code{Size: n, Stride: c.Stride}
+	r.Bitmap = make([]byte, len(c.Bitmap))
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			var sx, sy int
+			switch rot {
+			case 1:
+				sx, sy = y, n-1-x
+			case 2:
+				sx, sy = n-1-x, n-1-y
+			case 3:
+				sx, sy = n-1-y, x
+			}
+			if c.Black(sx, sy) {
+				r.Bitmap[y*r.Stride+x/8] |= 1 << uint(7-x&7)
+			}
+		}
+	}
+	return r
+}
+
+// BlockErrors compares got, a possibly damaged reading of the
+// QR code want, against want.  It returns the number of damaged
+// codewords in each Reed-Solomon block, in the order used by
+// Bits.AddCheckBytes, and the number of damaged codewords each
+// block can correct (see Version.Correctable).
+//
+// Both codes must have the same orientation; want must be
+// undamaged, so that BlockErrors can read its format.
+func BlockErrors(want, got *Code) (errs []int, max int, err error) {
+	if want.Size != got.Size {
+		return nil, 0, fmt.Errorf("size mismatch: %d vs %d", want.Size, got.Size)
+	}
+	v := Version((want.Size - 17) / 4)
+	if want.Size != 17+4*int(v) || v < MinVersion || v > MaxVersion {
+		return nil, 0, fmt.Errorf("invalid QR size %d", want.Size)
+	}
+	rot, err := orientation(want)
+	if err != nil {
+		return nil, 0, err
+	}
+	want, got = turn(want, rot), turn(got, rot)
+	l, m, err := readFormat(want)
+	if err != nil {
+		return nil, 0, err
+	}
+	p, err := NewPlan(v, l, m)
+	if err != nil {
+		return nil, 0, err
+	}
+	wb, gb := p.readBytes(want), p.readBytes(got)
+
+	lev := &vtab[v].level[l]
+	nd := p.DataBytes
+	db := nd / lev.nblock
+	extra := nd % lev.nblock
+	errs = make([]int, lev.nblock)
+	doff, coff := 0, nd
+	for i := 0; i < lev.nblock; i++ {
+		if i == lev.nblock-extra {
+			db++
+		}
+		for j := doff; j < doff+db; j++ {
+			if wb[j] != gb[j] {
+				errs[i]++
+			}
+		}
+		for j := coff; j < coff+lev.check; j++ {
+			if wb[j] != gb[j] {
+				errs[i]++
+			}
+		}
+		doff += db
+		coff += lev.check
+	}
+	return errs, v.Correctable(l), nil
+}
+
+// formatBits returns the 15 format bits, before masking,
+// for the given level and mask.
+func formatBits(l Level, m Mask) uint32 {
+	fb := uint32(l^1) << 13 // level: L=01, M=00, Q=11, H=10
+	fb |= uint32(m) << 10   // mask
+	const formatPoly = 0x537
+	rem := fb
+	for i := 14; i >= 10; i-- {
+		if rem&(1<<uint(i)) != 0 {
+			rem ^= formatPoly << uint(i-10)
+		}
+	}
+	return fb | rem
+}
+
+// readFormat reads the two copies of the format information in c
+// and returns the level and mask of the nearest valid format.
+func readFormat(c *Code) (Level, Mask, error) {
+	l, m, _, err := FormatErrors(c)
+	return l, m, err
+}
+
+// FormatErrors is like readFormat but also returns the total number
+// of bits by which the two copies of the format information differ
+// from the valid format.  A code made by Plan.Encode with a mask
+// from 0 to 7 has no format errors.
+func FormatErrors(c *Code) (Level, Mask, int, error) {
+	p := &Plan{Pixel: grid(c.Size)}
+	fplan(L, 0, p)
+	var fb [2]uint32
+	k := 0
+	for y, row := range p.Pixel {
+		for x, pix := range row {
+			if pix.Role() != Format {
+				continue
+			}
+			i := pix.Offset()
+			bit := c.Black(x, y)
+			if (0x5412>>i)&1 == 1 {
+				bit = !bit
+			}
+			if bit {
+				if x < 9 && y < 9 {
+					fb[0] |= 1 << i
+				} else {
+					fb[1] |= 1 << i
+				}
+			}
+			k++
+		}
+	}
+	if k != 30 {
+		panic("qr: format math")
+	}
+
+	best, bestDist := -1, 16
+	for f := 0; f < 32; f++ {
+		want := formatBits(Level(f>>3), Mask(f&7))
+		for _, got := range fb {
+			if d := popcount(want ^ got); d < bestDist {
+				best, bestDist = f, d
+			}
+		}
+	}
+	if bestDist > 3 {
+		return 0, 0, 0, errors.New("cannot read format information")
+	}
+	want := formatBits(Level(best>>3), Mask(best&7))
+	errs := popcount(want^fb[0]) + popcount(want^fb[1])
+	return Level(best >> 3), Mask(best & 7), errs, nil
+}
+
+func popcount(x uint32) int {
+	n := 0
+	for ; x != 0; x &= x - 1 {
+		n++
+	}
+	return n
+}
+
+// readBytes returns the data and check bytes stored in c,
+// in the order used by Bits.AddCheckBytes: all the data bytes,
+// block by block, and then all the check bytes.
+func (p *Plan) readBytes(c *Code) []byte {
+	b := make([]byte, p.DataBytes+p.CheckBytes)
+	for y, row := range p.Pixel {
+		for x, pix := range row {
+			if r := pix.Role(); r != Data && r != Check {
+				continue
+			}
+			if c.Black(x, y) != (pix&Black != 0) {
+				o := pix.Offset()
+				b[o/8] |= 1 << (7 - o&7)
+			}
+		}
+	}
+	return b
+}
+
+// correctBytes corrects errors in b, which holds the data and check
+// bytes of a code with version v and level l, one Reed-Solomon block
+// at a time.  It corrects at most v.Correctable(l) bytes in each block:
+// the smallest codes reserve some check bytes for detecting errors.
+func correctBytes(v Version, l Level, b []byte) error {
+	vt := &vtab[v]
+	lev := &vt.level[l]
+	nd := v.DataBytes(l)
+	db := nd / lev.nblock
+	extra := nd % lev.nblock
+	rs := gf256.NewRSDecoder(Field, lev.check)
+	max := v.Correctable(l)
+	dat, chk := b[:nd], b[nd:]
+	for i := 0; i < lev.nblock; i++ {
+		if i == lev.nblock-extra {
+			db++
+		}
+		msg := append(append([]byte(nil), dat[:db]...), chk[:lev.check]...)
+		n, err := rs.Correct(msg)
+		if err == nil && n > max {
+			err = gf256.ErrTooManyErrors
+		}
+		if err != nil {
+			return fmt.Errorf("block %d: %v", i, err)
+		}
+		copy(dat, msg[:db])
+		copy(chk, msg[db:])
+		dat = dat[db:]
+		chk = chk[lev.check:]
+	}
+	return nil
+}
+
+// Syndromes returns the numEC Reed-Solomon syndromes of a block of a
+// QR code with the given data and check bytes, which must hold numEC
+// bytes.  The syndromes are all zero when the block has no errors;
+// verification tools can check that more cheaply than running the
+// decoder.
+func Syndromes(data, check []byte, numEC int) []byte {
+	return gf256.NewRSDecoder(Field, numEC).Syndromes(data, check)
+}
+
+// A bitReader reads bit fields from a byte slice.
+type bitReader struct {
+	b   []byte
+	off int
+}
+
+func (r *bitReader) left() int {
+	return len(r.b)*8 - r.off
+}
+
+func (r *bitReader) read(n int) uint {
+	v := uint(0)
+	for i := 0; i < n; i++ {
+		v = v<<1 | uint(r.b[r.off/8]>>uint(7-r.off&7)&1)
+		r.off++
+	}
+	return v
+}
+
+// parseData parses the data segments in b,
+// which holds the data bytes of a version v code.
+func parseData(v Version, b []byte) ([]byte, error) {
+	r := &bitReader{b: b}
+	var out []byte
+	for r.left() >= 4 {
+		mode := r.read(4)
+		switch mode {
+		case 0:
+			return out, nil
+		case 1:
+			nb := numLen[v.sizeClass()]
+			if r.left() < nb {
+				return nil, errors.New("truncated numeric segment")
+			}
+			n := int(r.read(nb))
+			for ; n >= 3; n -= 3 {
+				if r.left() < 10 {
+					return nil, errors.New("truncated numeric segment")
+				}
+				w := r.read(10)
+				if w >= 1000 {
+					return nil, fmt.Errorf("invalid numeric group %d", w)
+				}
+				out = append(out, byte('0'+w/100), byte('0'+w/10%10), byte('0'+w%10))
+			}
+			switch n {
+			case 1:
+				if r.left() < 4 {
+					return nil, errors.New("truncated numeric segment")
+				}
+				w := r.read(4)
+				if w >= 10 {
+					return nil, fmt.Errorf("invalid numeric group %d", w)
+				}
+				out = append(out, byte('0'+w))
+			case 2:
+				if r.left() < 7 {
+					return nil, errors.New("truncated numeric segment")
+				}
+				w := r.read(7)
+				if w >= 100 {
+					return nil, fmt.Errorf("invalid numeric group %d", w)
+				}
+				out = append(out, byte('0'+w/10), byte('0'+w%10))
+			}
+		case 2:
+			nb := alphaLen[v.sizeClass()]
+			if r.left() < nb {
+				return nil, errors.New("truncated alphanumeric segment")
+			}
+			n := int(r.read(nb))
+			for ; n >= 2; n -= 2 {
+				if r.left() < 11 {
+					return nil, errors.New("truncated alphanumeric segment")
+				}
+				w := r.read(11)
+				if w >= 45*45 {
+					return nil, fmt.Errorf("invalid alphanumeric group %d", w)
+				}
+				out = append(out, alphabet[w/45], alphabet[w%45])
+			}
+			if n == 1 {
+				if r.left() < 6 {
+					return nil, errors.New("truncated alphanumeric segment")
+				}
+				w := r.read(6)
+				if w >= 45 {
+					return nil, fmt.Errorf("invalid alphanumeric group %d", w)
+				}
+				out = append(out, alphabet[w])
+			}
+		case 4:
+			nb := stringLen[v.sizeClass()]
+			if r.left() < nb {
+				return nil, errors.New("truncated byte segment")
+			}
+			n := int(r.read(nb))
+			if r.left() < 8*n {
+				return nil, errors.New("truncated byte segment")
+			}
+			for ; n > 0; n-- {
+				out = append(out, byte(r.read(8)))
+			}
+		case 7:
+			// ECI designator: the reader, not the decoder,
+			// decides what to do with the assignment number.
+			if r.left() < 8 {
+				return nil, errors.New("truncated ECI designator")
+			}
+			n := 0
+			switch w := r.read(8); {
+			case w&0x80 == 0:
+			case w&0xc0 == 0x80:
+				n = 8
+			case w&0xe0 == 0xc0:
+				n = 16
+			default:
+				return nil, fmt.Errorf("invalid ECI designator %#x", w)
+			}
+			if r.left() < n {
+				return nil, errors.New("truncated ECI designator")
+			}
+			r.read(n)
+		case 8:
+			nb := kanjiLen[v.sizeClass()]
+			if r.left() < nb {
+				return nil, errors.New("truncated kanji segment")
+			}
+			n := int(r.read(nb))
+			if r.left() < 13*n {
+				return nil, errors.New("truncated kanji segment")
+			}
+			for ; n > 0; n-- {
+				w := r.read(13)
+				c := w/0xc0<<8 | w%0xc0
+				if c < 0x1f00 {
+					c += 0x8140
+				} else {
+					c += 0xc140
+				}
+				out = append(out, byte(c>>8), byte(c))
+			}
+		case 13:
+			if r.left() < 4 {
+				return nil, errors.New("truncated hanzi segment")
+			}
+			if sub := r.read(4); sub != 1 {
+				return nil, fmt.Errorf("unsupported hanzi subset %d", sub)
+			}
+			nb := hanziLen[v.sizeClass()]
+			if r.left() < nb {
+				return nil, errors.New("truncated hanzi segment")
+			}
+			n := int(r.read(nb))
+			if r.left() < 13*n {
+				return nil, errors.New("truncated hanzi segment")
+			}
+			for ; n > 0; n-- {
+				w := r.read(13)
+				c := w/0x60<<8 | w%0x60
+				if c < 0x0a00 {
+					c += 0xa1a1
+				} else {
+					c += 0xa6a1
+				}
+				out = append(out, byte(c>>8), byte(c))
+			}
+		default:
+			return nil, fmt.Errorf("unsupported segment mode %d", mode)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,273 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coding
+
+import (
+	"bytes"
+	"testing"
+)
+
+var decodeTests = []struct {
+	text []Encoding
+	want string
+}{
+	{[]Encoding{String("hello, world")}, "hello, world"},
+	{[]Encoding{Num("0123456789")}, "0123456789"},
+	{[]Encoding{Num("12"), Num("3")}, "123"},
+	{[]Encoding{Alpha("HELLO WORLD")}, "HELLO WORLD"},
+	{[]Encoding{String("http://swtch.com/qr#"), Num("123456")}, "http://swtch.com/qr#123456"},
+	{[]Encoding{ECI(26), String("h\xc3\xa9llo")}, "h\xc3\xa9llo"},
+	{[]Encoding{ECI(1000), Num("1"), ECI(100000), Num("2")}, "12"},
+	{[]Encoding{Hanzi("\xb0\xa1\xa1\xa1\xfa\xfe\xaa\xfe")}, "\xb0\xa1\xa1\xa1\xfa\xfe\xaa\xfe"},
+	{[]Encoding{Kanji("\x93\x5f\xe4\xaa")}, "\x93\x5f\xe4\xaa"},
+}
+
+// rotate returns c turned a quarter turn clockwise.
+func rotate(c *Code) *Code {
+	n := c.Size
+	r := &Code{Size: n, Stride: c.Stride, Bitmap: make([]byte, len(c.Bitmap))}
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			if c.Black(y, n-1-x) {
+				r.Bitmap[y*r.Stride+x/8] |= 1 << uint(7-x&7)
+			}
+		}
+	}
+	return r
+}
+
+func TestDecode(t *testing.T) {
+	for _, v := range []Version{1, 2, 7, 10, 27, 40} {
+		for l := L; l <= H; l++ {
+			for m := Mask(0); m < 8; m++ {
+				for _, tt := range decodeTests {
+					p, err := NewPlan(v, l, m)
+					if err != nil {
+						t.Fatal(err)
+					}
+					c, err := p.Encode(tt.text...)
+					if err != nil {
+						// too long for this version
+						continue
+					}
+					for rot := 0; rot < 4; rot++ {
+						out, err := Decode(c)
+						if err != nil || string(out) != tt.want {
+							t.Errorf("v%v/%v/%d rot %d: Decode(Encode(%v)) = %q, %v, want %q", v, l, m, rot, tt.text, out, err, tt.want)
+						}
+						c = rotate(c)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeCorrupt(t *testing.T) {
+	p, err := NewPlan(3, M, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := p.Encode(String("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Flip one pixel of each of the first n codewords.
+	// Version 3-M has one block, which can correct 13 codewords.
+	max := p.Version.Correctable(p.Level)
+	for _, n := range []int{1, max, max + 1} {
+		got := &Code{Size: c.Size, Stride: c.Stride, Bitmap: append([]byte(nil), c.Bitmap...)}
+		flipped := make(map[uint]bool)
+		for y, row := range p.Pixel {
+			for x, pix := range row {
+				o := pix.Offset() / 8
+				if r := pix.Role(); (r == Data || r == Check) && int(o) < n && !flipped[o] {
+					flipped[o] = true
+					got.Bitmap[y*got.Stride+x/8] ^= 1 << uint(7-x&7)
+				}
+			}
+		}
+		out, err := Decode(got)
+		if n <= max && (err != nil || string(out) != "hello") {
+			t.Errorf("Decode with %d codewords damaged = %q, %v, want %q", n, out, err, "hello")
+		}
+		if n > max && err == nil {
+			t.Errorf("Decode with %d codewords damaged = %q, want error", n, out)
+		}
+	}
+}
+
+func TestDecodeQuietZone(t *testing.T) {
+	p, err := NewPlan(4, Q, Mask5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{1, QuietZone} {
+		c, err := p.WithQuietZone(n).Encode(String("quiet"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rot := 0; rot < 4; rot++ {
+			if out, err := Decode(c); err != nil || string(out) != "quiet" {
+				t.Errorf("margin %d, rot %d: Decode = %q, %v, want %q", n, rot, out, err, "quiet")
+			}
+			c = rotate(c)
+		}
+	}
+}
+
+func TestSyndromes(t *testing.T) {
+	// Version 1-M "01234567", from ISO/IEC 18004 Annex I.
+	data := []byte{0x10, 0x20, 0x0c, 0x56, 0x61, 0x80, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11}
+	check := []byte{0xa5, 0x24, 0xd4, 0xc1, 0xed, 0x36, 0xc7, 0x87, 0x2c, 0x55}
+	s := Syndromes(data, check, len(check))
+	if len(s) != len(check) || !bytes.Equal(s, make([]byte, len(check))) {
+		t.Errorf("Syndromes = %x, want all zero", s)
+	}
+	data[3] ^= 0x40
+	if s := Syndromes(data, check, len(check)); bytes.Equal(s, make([]byte, len(check))) {
+		t.Errorf("Syndromes of damaged block = %x, want non-zero", s)
+	}
+}
+
+func TestBlockErrors(t *testing.T) {
+	p, err := NewPlan(5, Q, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := p.Encode(String("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &Code{Size: want.Size, Stride: want.Stride, Bitmap: append([]byte(nil), want.Bitmap...)}
+	// Damage data byte 0 (block 0) twice and the last check byte (block 3) once.
+	last := uint((p.DataBytes+p.CheckBytes)*8 - 1)
+	for y, row := range p.Pixel {
+		for x, pix := range row {
+			if r := pix.Role(); (r == Data || r == Check) && (pix.Offset() < 2 || pix.Offset() == last) {
+				got.Bitmap[y*got.Stride+x/8] ^= 1 << uint(7-x&7)
+			}
+		}
+	}
+	for rot := 0; rot < 4; rot++ {
+		errs, max, err := BlockErrors(want, got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(errs) != 4 || errs[0] != 1 || errs[1] != 0 || errs[2] != 0 || errs[3] != 1 || max != 9 {
+			t.Errorf("rot %d: BlockErrors = %v, %d, want [1 0 0 1], 9", rot, errs, max)
+		}
+		want, got = rotate(want), rotate(got)
+	}
+}
+
+func TestPad(t *testing.T) {
+	p, err := NewPlan(2, L, 3) // version 2 has 7 remainder bits
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Pad = []byte{0x5a}
+	p.Remainder = 0x55
+	c, err := p.Encode(String("pad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, err := Decode(c); err != nil || string(out) != "pad" {
+		t.Fatalf("Decode = %q, %v, want %q", out, err, "pad")
+	}
+	b := p.readBytes(c)
+	for i := 5; i < p.DataBytes; i++ { // 4+8+3*8 bits + 4-bit terminator = 5 bytes
+		if b[i] != 0x5a {
+			t.Errorf("data byte %d = %#x, want 0x5a", i, b[i])
+		}
+	}
+	n := 0
+	for y, row := range p.Pixel {
+		for x, pix := range row {
+			if pix.Role() != Extra {
+				continue
+			}
+			n++
+			o := pix.Offset() - uint(8*(p.DataBytes+p.CheckBytes))
+			if c.Black(x, y) != (pix&Black != 0) != (p.Remainder>>o&1 == 1) {
+				t.Errorf("remainder bit %d has wrong value", o)
+			}
+		}
+	}
+	if n != 7 {
+		t.Errorf("found %d remainder bits, want 7", n)
+	}
+}
+
+func TestKanji(t *testing.T) {
+	// Example from ISO/IEC 18004 §7.4.6.
+	var b Bits
+	Kanji("\x93\x5f\xe4\xaa").Encode(&b, 1)
+	b.Write(0, 2) // pad to 40 bits
+	want := []byte{0x80, 0x26, 0xcf, 0xea, 0xa8}
+	if got := b.Bytes(); string(got) != string(want) {
+		t.Errorf("Kanji bits = %x, want %x", got, want)
+	}
+	for _, s := range []string{"\x93", "ab", "\xa0\x40", "\x81\x3f"} {
+		if Kanji(s).Check() == nil {
+			t.Errorf("Kanji(%q).Check() = nil, want error", s)
+		}
+	}
+}
+
+func TestEncodeCodewords(t *testing.T) {
+	p, err := NewPlan(2, Q, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b Bits
+	String("raw").Encode(&b, p.Version)
+	b.Pad(p.DataBytes*8 - b.Bits())
+	c, err := p.EncodeCodewords(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1, err := p.Encode(String("raw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(c.Bitmap) != string(c1.Bitmap) {
+		t.Errorf("EncodeCodewords and Encode differ")
+	}
+	if _, err := p.EncodeCodewords(b.Bytes()[1:]); err == nil {
+		t.Errorf("EncodeCodewords accepted short data")
+	}
+}
+
+func TestGroups(t *testing.T) {
+	// ISO/IEC 18004 Table 9: 5-Q has 2 blocks of 15 data bytes
+	// and 2 of 16, each with 18 check bytes.
+	p, err := NewPlan(5, Q, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []BlockGroup{{2, 15, 18}, {2, 16, 18}}
+	if len(p.Groups) != 2 || p.Groups[0] != want[0] || p.Groups[1] != want[1] {
+		t.Errorf("5-Q groups = %v, want %v", p.Groups, want)
+	}
+
+	for v := Version(1); v <= 40; v++ {
+		for l := L; l <= H; l++ {
+			p, err := NewPlan(v, l, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			nb, nd, nc := 0, 0, 0
+			for _, g := range p.Groups {
+				nb += g.Blocks
+				nd += g.Blocks * g.DataBytes
+				nc += g.Blocks * g.CheckBytes
+			}
+			if nb != p.Blocks || nd != p.DataBytes || nc != p.CheckBytes {
+				t.Errorf("%v-%v groups %v do not add up to %d blocks, %d data, %d check", v, l, p.Groups, p.Blocks, p.DataBytes, p.CheckBytes)
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coding
+
+import (
+	"fmt"
+	"image"
+	"strings"
+)
+
+// A PlanDiff lists the pixels that differ between two plans,
+// or between a plan and a code, grouped by the pixel's role in
+// the first plan.  Each list is in row-major order.
+type PlanDiff struct {
+	Changed map[PixelRole][]image.Point
+}
+
+// Len returns the total number of differing pixels.
+func (d *PlanDiff) Len() int {
+	n := 0
+	for _, pts := range d.Changed {
+		n += len(pts)
+	}
+	return n
+}
+
+// String summarizes the difference, listing the number
+// of differing pixels for each role, such as "format:2 data:10".
+// It returns "no differences" if there are none.
+func (d *PlanDiff) String() string {
+	var parts []string
+	for r := PixelRole(0); r <= Quiet; r++ {
+		if n := len(d.Changed[r]); n > 0 {
+			name := r.String()
+			if r == 0 {
+				name = "none"
+			}
+			parts = append(parts, fmt.Sprintf("%s:%d", name, n))
+		}
+	}
+	if parts == nil {
+		return "no differences"
+	}
+	return strings.Join(parts, " ")
+}
+
+func (d *PlanDiff) add(r PixelRole, x, y int) {
+	if d.Changed == nil {
+		d.Changed = make(map[PixelRole][]image.Point)
+	}
+	d.Changed[r] = append(d.Changed[r], image.Pt(x, y))
+}
+
+// DiffPlans compares the pixel maps of a and b, which must be the
+// same size.  A pixel differs if its role, color, inversion, or
+// offset differs.
+func DiffPlans(a, b *Plan) (*PlanDiff, error) {
+	if len(a.Pixel) != len(b.Pixel) {
+		return nil, fmt.Errorf("cannot diff plans of size %d and %d", len(a.Pixel), len(b.Pixel))
+	}
+	d := new(PlanDiff)
+	for y, row := range a.Pixel {
+		for x, pix := range row {
+			if pix != b.Pixel[y][x] {
+				d.add(pix.Role(), x, y)
+			}
+		}
+	}
+	return d, nil
+}
+
+// DiffCode compares the fixed pixels of p, those that do not depend on
+// the encoded data, with the modules of c, which might be a decoded
+// symbol.  Data, check, and remainder pixels are not compared.
+func (p *Plan) DiffCode(c *Code) (*PlanDiff, error) {
+	if len(p.Pixel) != c.Size {
+		return nil, fmt.Errorf("cannot diff plan of size %d and code of size %d", len(p.Pixel), c.Size)
+	}
+	d := new(PlanDiff)
+	for y, row := range p.Pixel {
+		for x, pix := range row {
+			switch r := pix.Role(); r {
+			case Data, Check, Extra:
+				// depends on data
+			default:
+				if (pix&Black != 0) != c.Black(x, y) {
+					d.add(r, x, y)
+				}
+			}
+		}
+	}
+	return d, nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coding
+
+import (
+	"bytes"
+	"testing"
+)
+
+// FuzzRoundTrip encodes arbitrary bytes at the given level and mask
+// in the smallest version that holds them, checks that Decode
+// recovers them, and then damages up to the correctable number
+// of codewords in the first block and checks that BlockErrors
+// counts the damage and that Decode corrects it.
+func FuzzRoundTrip(f *testing.F) {
+	f.Add([]byte("hello, world"), uint8(0), uint8(0), uint8(0))
+	f.Add([]byte("http://swtch.com/qr#0123456789"), uint8(1), uint8(5), uint8(3))
+	f.Add([]byte{0, 0xff, 0x80, '\n'}, uint8(3), uint8(7), uint8(255))
+	f.Fuzz(func(t *testing.T, data []byte, level, mask, damage uint8) {
+		l, m := Level(level%4), Mask(mask%8)
+		var c *Code
+		var p *Plan
+		for v := MinVersion; v <= MaxVersion; v++ {
+			var err error
+			p, err = NewPlan(Version(v), l, m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c, err = p.Encode(String(data)); err == nil {
+				break
+			}
+		}
+		if c == nil {
+			t.Skip("too long")
+		}
+
+		out, err := Decode(c)
+		if err != nil {
+			t.Fatalf("v%v/%v/%d: Decode: %v", p.Version, l, m, err)
+		}
+		if !bytes.Equal(out, data) {
+			t.Fatalf("v%v/%v/%d: Decode = %q, want %q", p.Version, l, m, out, data)
+		}
+
+		// Flip one pixel of each of the first n data codewords.
+		n := int(damage) % (p.Version.Correctable(l) + 1)
+		got := &Code{Size: c.Size, Stride: c.Stride, Bitmap: append([]byte(nil), c.Bitmap...)}
+		flipped := make(map[uint]bool)
+		for y, row := range p.Pixel {
+			for x, pix := range row {
+				o := pix.Offset()
+				if pix.Role() == Data && int(o/8) < n && !flipped[o/8] {
+					flipped[o/8] = true
+					got.Bitmap[y*got.Stride+x/8] ^= 1 << uint(7-x&7)
+				}
+			}
+		}
+		errs, max, err := BlockErrors(c, got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if errs[0] != n || n > max {
+			t.Fatalf("v%v/%v/%d: damaged %d codewords, BlockErrors = %v, %d", p.Version, l, m, n, errs, max)
+		}
+		out, err = Decode(got)
+		if err != nil || !bytes.Equal(out, data) {
+			t.Fatalf("v%v/%v/%d: Decode with %d codewords damaged = %q, %v, want %q", p.Version, l, m, n, out, err, data)
+		}
+	})
+}
+
+// FuzzUnmarshalPlan checks that UnmarshalBinary rejects or accepts
+// arbitrary input without panicking, and that a plan it accepts
+// can be used without panicking.
+func FuzzUnmarshalPlan(f *testing.F) {
+	for _, v := range []Version{1, 7} {
+		p, _ := NewPlan(v, M, Mask2)
+		data, _ := p.MarshalBinary()
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var p Plan
+		if p.UnmarshalBinary(data) != nil {
+			return
+		}
+		p.Interleave()
+		p.DataModules()
+		p.Encode(String("fuzz"))
+	})
+}
@@ -0,0 +1,214 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coding
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// planMagic begins every marshaled plan; the last byte is the format version.
+const planMagic = "QRP\x03"
+
+// MarshalBinary encodes the plan in a compact binary form,
+// so that plans can be computed once and cached or embedded.
+//
+// After a header holding the version, level, mask, block layout,
+// pad cycle, remainder bits, quiet zone width, and custom flag, each
+// pixel is one byte holding its role and Black and Invert bits.
+// If the pixel has an offset, bit 0x40 of that byte is set and the
+// offset follows as a varint.
+func (p *Plan) MarshalBinary() ([]byte, error) {
+	var b bytes.Buffer
+	var tmp [binary.MaxVarintLen64]byte
+	put := func(v int) {
+		b.Write(tmp[:binary.PutUvarint(tmp[:], uint64(v))])
+	}
+
+	b.WriteString(planMagic)
+	b.WriteByte(byte(p.Version))
+	b.WriteByte(byte(p.Level))
+	b.WriteByte(byte(p.Mask))
+	put(p.DataBytes)
+	put(p.CheckBytes)
+	put(p.Blocks)
+	put(len(p.Groups))
+	for _, g := range p.Groups {
+		put(g.Blocks)
+		put(g.DataBytes)
+		put(g.CheckBytes)
+	}
+	put(len(p.Pad))
+	b.Write(p.Pad)
+	put(int(p.Remainder))
+	put(p.QuietZone)
+	if p.Custom {
+		put(1)
+	} else {
+		put(0)
+	}
+
+	put(len(p.Pixel))
+	for _, row := range p.Pixel {
+		if len(row) != len(p.Pixel) {
+			return nil, errors.New("plan pixel map is not square")
+		}
+		for _, pix := range row {
+			c := byte(pix & 63)
+			if o := pix.Offset(); o != 0 {
+				b.WriteByte(c | 0x40)
+				put(int(o))
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a plan encoded by MarshalBinary.
+func (p *Plan) UnmarshalBinary(data []byte) (err error) {
+	if !bytes.HasPrefix(data, []byte(planMagic)) {
+		return errors.New("not a marshaled QR plan")
+	}
+	r := bytes.NewReader(data[len(planMagic):])
+	fail := func() error {
+		return errors.New("truncated or corrupt QR plan")
+	}
+	get := func() int {
+		v, e := binary.ReadUvarint(r)
+		if e != nil || v > 1<<30 {
+			err = fail()
+			return 0
+		}
+		return int(v)
+	}
+	getByte := func() byte {
+		c, e := r.ReadByte()
+		if e != nil {
+			err = fail()
+		}
+		return c
+	}
+
+	var q Plan
+	q.Version = Version(getByte())
+	q.Level = Level(getByte())
+	q.Mask = Mask(int8(getByte()))
+	q.DataBytes = get()
+	q.CheckBytes = get()
+	q.Blocks = get()
+	n := get()
+	if err != nil || n > 2 {
+		return fail()
+	}
+	for i := 0; i < n; i++ {
+		q.Groups = append(q.Groups, BlockGroup{get(), get(), get()})
+	}
+	n = get()
+	if err != nil || n > r.Len() {
+		return fail()
+	}
+	if n > 0 {
+		q.Pad = make([]byte, n)
+		r.Read(q.Pad)
+	}
+	q.Remainder = uint(get())
+	q.QuietZone = get()
+	switch get() {
+	case 0:
+	case 1:
+		q.Custom = true
+	default:
+		return fail()
+	}
+	if err != nil {
+		return err
+	}
+	if q.Version < MinVersion || q.Version > MaxVersion || q.Level < L || q.Level > H {
+		return fmt.Errorf("marshaled plan has invalid version %d or level %d", int(q.Version), int(q.Level))
+	}
+
+	if q.QuietZone > maxQuietZone || q.Remainder > 0x7f {
+		return fail()
+	}
+	if err := q.checkBlocks(); err != nil {
+		return err
+	}
+
+	// Every pixel takes at least one byte, so a short input
+	// cannot make UnmarshalBinary allocate a large grid.
+	siz := get()
+	if err != nil || siz != 17+4*int(q.Version)+2*q.QuietZone || r.Len() < siz*siz {
+		return fail()
+	}
+	q.Pixel = grid(siz)
+	nbit := uint(8 * (q.DataBytes + q.CheckBytes))
+	for _, row := range q.Pixel {
+		for x := range row {
+			c := getByte()
+			pix := Pixel(c & 63)
+			if c&0x40 != 0 {
+				pix |= OffsetPixel(uint(get()))
+			}
+			if err != nil {
+				return err
+			}
+			o := pix.Offset()
+			bad := false
+			switch pix.Role() {
+			case 0, Position, Alignment, Timing, Unused, Dark, Quiet:
+				bad = o != 0
+			case Format:
+				bad = o >= 15
+			case PVersion:
+				bad = o >= 18
+			case Data:
+				bad = o >= uint(8*q.DataBytes)
+			case Check:
+				bad = o < uint(8*q.DataBytes) || o >= nbit
+			case Extra:
+				bad = o < nbit || o >= nbit+7
+			default:
+				bad = true
+			}
+			if bad {
+				return fmt.Errorf("marshaled plan has invalid pixel %v", pix)
+			}
+			row[x] = pix
+		}
+	}
+	if r.Len() != 0 {
+		return fail()
+	}
+	*p = q
+	return nil
+}
+
+// maxQuietZone is the widest quiet zone UnmarshalBinary accepts.
+const maxQuietZone = 1 << 10
+
+// checkBlocks checks that p's block counts and sizes agree
+// with its Groups, so that Encode and Interleave can rely on them.
+func (p *Plan) checkBlocks() error {
+	nb, nd, nc := 0, 0, 0
+	for _, g := range p.Groups {
+		if g.Blocks <= 0 || g.DataBytes <= 0 || g.CheckBytes != p.Groups[0].CheckBytes {
+			return fmt.Errorf("marshaled plan has invalid block group %+v", g)
+		}
+		nb += g.Blocks
+		nd += g.Blocks * g.DataBytes
+		nc += g.Blocks * g.CheckBytes
+	}
+	if nb != p.Blocks || nd != p.DataBytes || nc != p.CheckBytes {
+		return fmt.Errorf("marshaled plan has %d blocks, %d data bytes, %d check bytes, but groups %+v", p.Blocks, p.DataBytes, p.CheckBytes, p.Groups)
+	}
+	if n := rawDataModules(p.Version) / 8; nd+nc > n {
+		return fmt.Errorf("marshaled plan has %d codewords, want at most %d", nd+nc, n)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coding
+
+import (
+	"image"
+	"image/color"
+)
+
+var (
+	maskInverted = color.RGBA{0x00, 0x00, 0x00, 0xff}
+	maskKept     = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	fixedDark    = color.RGBA{0x80, 0x80, 0x80, 0xff}
+	fixedLight   = color.RGBA{0xd0, 0xd0, 0xd0, 0xff}
+
+	hotRun    = color.RGBA{0xe0, 0x20, 0x20, 0xff} // N1: run of five or more
+	hotBlock  = color.RGBA{0xf0, 0xa0, 0x20, 0xff} // N2: 2×2 block
+	hotFinder = color.RGBA{0x20, 0x60, 0xe0, 0xff} // N3: finder-like pattern
+)
+
+// MaskImage returns a picture of the mask applied to p, for checking
+// by eye that the mask is the intended one.  Data, check, and
+// remainder pixels that the mask inverts are black and the others
+// white.  All other pixels are gray: dark gray if black in the plan,
+// light gray if white.  Each module is scale×scale image pixels;
+// the image has no quiet zone.
+func (p *Plan) MaskImage(scale int) *image.RGBA {
+	if scale < 1 {
+		scale = 1
+	}
+	n := len(p.Pixel)
+	m := image.NewRGBA(image.Rect(0, 0, n*scale, n*scale))
+	for y, row := range p.Pixel {
+		for x, pix := range row {
+			var c color.RGBA
+			switch r := pix.Role(); {
+			case r == Data || r == Check || r == Extra:
+				c = maskKept
+				if pix&Invert != 0 {
+					c = maskInverted
+				}
+			case pix&Black != 0:
+				c = fixedDark
+			default:
+				c = fixedLight
+			}
+			fill(m, x, y, scale, c)
+		}
+	}
+	return m
+}
+
+// PenaltyImage returns a picture of c marking the modules that earn
+// mask penalty points, for diagnosing codes that scanners find hard
+// to read.  Modules in finder-like patterns (rule N3) are blue, in
+// 2×2 blocks of one color (N2) orange, and in runs of five or more
+// (N1) red; a module in several is drawn in the first of those that
+// applies.  Other modules are dark or light gray.  Each module is
+// scale×scale image pixels; the image has no quiet zone.
+func PenaltyImage(c *Code, scale int) *image.RGBA {
+	if scale < 1 {
+		scale = 1
+	}
+	n := c.Size
+	dark := c.dark()
+	run, block, finder := grid2(n), grid2(n), grid2(n)
+	for _, r := range c.PenaltyRuns() {
+		g := run
+		if r.Rule == 3 {
+			g = finder
+		}
+		for k := 0; k < r.Len; k++ {
+			if r.Down {
+				g[r.Start.Y+k][r.Start.X] = true
+			} else {
+				g[r.Start.Y][r.Start.X+k] = true
+			}
+		}
+	}
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			if sameBlock(dark, x, y) {
+				block[y][x], block[y][x+1], block[y+1][x], block[y+1][x+1] = true, true, true, true
+			}
+		}
+	}
+
+	m := image.NewRGBA(image.Rect(0, 0, n*scale, n*scale))
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			col := fixedLight
+			switch {
+			case finder[y][x]:
+				col = hotFinder
+			case block[y][x]:
+				col = hotBlock
+			case run[y][x]:
+				col = hotRun
+			case dark[y][x]:
+				col = fixedDark
+			}
+			fill(m, x, y, scale, col)
+		}
+	}
+	return m
+}
+
+func grid2(n int) [][]bool {
+	g := make([][]bool, n)
+	for i := range g {
+		g[i] = make([]bool, n)
+	}
+	return g
+}
+
+// fill paints module x, y of m, which has scale pixels per module.
+func fill(m *image.RGBA, x, y, scale int, c color.RGBA) {
+	for dy := 0; dy < scale; dy++ {
+		for dx := 0; dx < scale; dx++ {
+			m.SetRGBA(x*scale+dx, y*scale+dy, c)
+		}
+	}
+}
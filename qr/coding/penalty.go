@@ -0,0 +1,347 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coding
+
+import (
+	"fmt"
+	"image"
+)
+
+// Penalty weights from ISO/IEC 18004 §7.8.3.1, Table 11.
+const (
+	penaltyN1 = 3
+	penaltyN2 = 3
+	penaltyN3 = 40
+	penaltyN4 = 10
+)
+
+// Penalty returns the mask penalty score of the pixel colors in p,
+// using the four rules of ISO/IEC 18004 §7.8.3.1.  Lower is better.
+// For a plan from NewPlan, the colors are the function patterns and
+// the mask applied to data that is all zero bits; to score a code
+// holding real data, use Code.Penalty.
+func Penalty(p *Plan) int {
+	m := p.symbol()
+	dark := make([][]bool, len(m))
+	for y, row := range m {
+		dark[y] = make([]bool, len(row))
+		for x, pix := range row {
+			dark[y][x] = pix&Black != 0
+		}
+	}
+	return score(dark).Total()
+}
+
+// Penalty returns the mask penalty score of c,
+// using the four rules of ISO/IEC 18004 §7.8.3.1.
+// Lower is better.  Encoders choose the mask that
+// gives the code the lowest score.
+func (c *Code) Penalty() int {
+	return c.PenaltyScore().Total()
+}
+
+// A PenaltyScore breaks down a mask penalty score by rule.
+type PenaltyScore struct {
+	N1 int // runs of five or more modules of one color
+	N2 int // 2×2 blocks of one color
+	N3 int // finder-like 1:1:3:1:1 patterns
+	N4 int // dark proportion away from one half
+}
+
+// Total returns the total penalty score.
+func (s PenaltyScore) Total() int {
+	return s.N1 + s.N2 + s.N3 + s.N4
+}
+
+func (s PenaltyScore) String() string {
+	return fmt.Sprintf("N1=%d N2=%d N3=%d N4=%d total=%d", s.N1, s.N2, s.N3, s.N4, s.Total())
+}
+
+// PenaltyScore is like Penalty but returns the score for each rule.
+func (c *Code) PenaltyScore() PenaltyScore {
+	return score(c.dark())
+}
+
+// dark returns the colors of c's modules, indexed [y][x].
+func (c *Code) dark() [][]bool {
+	dark := make([][]bool, c.Size)
+	for y := range dark {
+		dark[y] = make([]bool, c.Size)
+		for x := range dark[y] {
+			dark[y][x] = c.Black(x, y)
+		}
+	}
+	return dark
+}
+
+// EvaluateMasks returns the penalty scores of the codes holding text
+// with each of the eight masks, indexed by mask, so that the choice
+// of mask can be explained or logged.  EvaluateAll chooses the mask
+// with the lowest total.  The plan's own mask does not matter.
+func (p *Plan) EvaluateMasks(text ...Encoding) ([8]PenaltyScore, error) {
+	var scores [8]PenaltyScore
+	bytes, err := p.codewords(text)
+	if err != nil {
+		return scores, err
+	}
+	code := p.candidates(bytes)
+	for m := range scores {
+		scores[m] = code(Mask(m)).PenaltyScore()
+	}
+	return scores, nil
+}
+
+// score returns the penalty for the square grid of colors.
+func score(dark [][]bool) PenaltyScore {
+	var s PenaltyScore
+	s.N1, s.N2, s.N3, s.N4 = penalties(dark)
+	return s
+}
+
+// penalties returns the penalty for each of the four rules:
+// N1, runs of five or more modules of one color in a row or column;
+// N2, 2×2 blocks of one color;
+// N3, 1:1:3:1:1 finder-like patterns with four light modules on either side;
+// N4, a proportion of dark modules far from one half.
+func penalties(dark [][]bool) (n1, n2, n3, n4 int) {
+	n := len(dark)
+	for dir := 0; dir < 2; dir++ {
+		for j := 0; j < n; j++ {
+			l1, l3 := linePenalty(dark, dir, j)
+			n1 += l1
+			n3 += l3
+		}
+	}
+
+	// N2: 2×2 blocks of one color, overlapping blocks counted separately.
+	ndark := 0
+	for y, row := range dark {
+		for x, b := range row {
+			if b {
+				ndark++
+			}
+			if sameBlock(dark, x, y) {
+				n2 += penaltyN2
+			}
+		}
+	}
+	n4 = darkPenalty(ndark, n*n)
+	return
+}
+
+// A PenaltyRun is a stretch of one row or column of a code that
+// earns mask penalty points: a run of five or more modules of one
+// color (rule N1) or a finder-like pattern (rule N3).
+type PenaltyRun struct {
+	Rule  int         // 1 or 3
+	Start image.Point // first module
+	Down  bool        // whether the run goes down a column instead of across a row
+	Len   int         // number of modules
+}
+
+// PenaltyRuns returns the runs in c that earn points under rules
+// N1 and N3, row by row and then column by column, so that tools
+// can show where a code's penalty comes from.
+func (c *Code) PenaltyRuns() []PenaltyRun {
+	dark := c.dark()
+	var runs []PenaltyRun
+	for dir := 0; dir < 2; dir++ {
+		for j := range dark {
+			lineScan(dark, dir, j, func(rule, i, n int) {
+				r := PenaltyRun{Rule: rule, Start: image.Pt(i, j), Len: n}
+				if dir == 1 {
+					r.Start, r.Down = image.Pt(j, i), true
+				}
+				runs = append(runs, r)
+			})
+		}
+	}
+	return runs
+}
+
+// linePenalty returns the N1 and N3 penalties for line j of dark,
+// which is row j for dir 0 and column j for dir 1.
+func linePenalty(dark [][]bool, dir, j int) (n1, n3 int) {
+	return lineScan(dark, dir, j, nil)
+}
+
+// lineScan is like linePenalty but also calls f, if not nil, with
+// the rule, first module, and length of each penalized run.
+func lineScan(dark [][]bool, dir, j int, f func(rule, i, n int)) (n1, n3 int) {
+	// Copy the line into buf, with four light modules,
+	// as in the quiet zone, on each side.
+	const pad = 4
+	n := len(dark)
+	var tmp [pad + 17 + 4*MaxVersion + pad]bool
+	buf := tmp[:]
+	if n+2*pad > len(buf) {
+		buf = make([]bool, n+2*pad)
+	}
+	buf = buf[:n+2*pad]
+	line := buf[pad : pad+n]
+	for i := range line {
+		if dir == 0 {
+			line[i] = dark[j][i]
+		} else {
+			line[i] = dark[i][j]
+		}
+	}
+
+	// N1: runs of 5 or more.
+	run := 1
+	for i := 1; i <= n; i++ {
+		if i < n && line[i] == line[i-1] {
+			run++
+			continue
+		}
+		if run >= 5 {
+			n1 += penaltyN1 + run - 5
+			if f != nil {
+				f(1, i-run, run)
+			}
+		}
+		run = 1
+	}
+
+	// N3: dark-light-dark-dark-dark-light-dark
+	// with 4 light modules before or after.
+	for i := pad; i+7 <= pad+n; i++ {
+		if !buf[i] || buf[i+1] || !buf[i+2] || !buf[i+3] || !buf[i+4] || buf[i+5] || !buf[i+6] {
+			continue
+		}
+		before := !buf[i-1] && !buf[i-2] && !buf[i-3] && !buf[i-4]
+		after := !buf[i+7] && !buf[i+8] && !buf[i+9] && !buf[i+10]
+		if before || after {
+			n3 += penaltyN3
+			if f != nil {
+				f(3, i-pad, 7)
+			}
+		}
+	}
+	return
+}
+
+// sameBlock reports whether the 2×2 block with top left
+// corner x, y lies in dark and is all one color.
+func sameBlock(dark [][]bool, x, y int) bool {
+	n := len(dark)
+	if x < 0 || y < 0 || x+1 >= n || y+1 >= n {
+		return false
+	}
+	b := dark[y][x]
+	return dark[y][x+1] == b && dark[y+1][x] == b && dark[y+1][x+1] == b
+}
+
+// darkPenalty returns the N4 penalty for ndark dark modules out of
+// total: 10 points for each full 5% the proportion is away from 50%.
+func darkPenalty(ndark, total int) int {
+	if total == 0 {
+		return 0
+	}
+	// The deviation in units of 5% is |ndark/total - 1/2| * 20.
+	dev := 20*ndark - 10*total
+	if dev < 0 {
+		dev = -dev
+	}
+	return penaltyN4 * (dev / total)
+}
+
+// A PenaltyEvaluator tracks the penalty score of a code as its
+// modules are flipped one at a time, as when steering the pixels of
+// a QArt code.  Each flip costs time proportional to the width of
+// the code, rather than its area.
+type PenaltyEvaluator struct {
+	dark     [][]bool
+	n1, n3   [2][]int // per-line penalties, by direction and line
+	n2       int
+	ndark    int
+	n1s, n3s int // sums of n1 and n3
+}
+
+// NewPenaltyEvaluator returns an evaluator for the colors of c.
+// Later changes to c do not affect it.
+func NewPenaltyEvaluator(c *Code) *PenaltyEvaluator {
+	n := c.Size
+	e := &PenaltyEvaluator{dark: make([][]bool, n)}
+	for y := range e.dark {
+		e.dark[y] = make([]bool, n)
+		for x := range e.dark[y] {
+			if e.dark[y][x] = c.Black(x, y); e.dark[y][x] {
+				e.ndark++
+			}
+		}
+	}
+	for dir := 0; dir < 2; dir++ {
+		e.n1[dir] = make([]int, n)
+		e.n3[dir] = make([]int, n)
+		for j := 0; j < n; j++ {
+			e.n1[dir][j], e.n3[dir][j] = linePenalty(e.dark, dir, j)
+			e.n1s += e.n1[dir][j]
+			e.n3s += e.n3[dir][j]
+		}
+	}
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			if sameBlock(e.dark, x, y) {
+				e.n2 += penaltyN2
+			}
+		}
+	}
+	return e
+}
+
+// Score returns the current penalty score.
+func (e *PenaltyEvaluator) Score() PenaltyScore {
+	n := len(e.dark)
+	return PenaltyScore{e.n1s, e.n2, e.n3s, darkPenalty(e.ndark, n*n)}
+}
+
+// Black reports whether the module at x, y is currently dark.
+func (e *PenaltyEvaluator) Black(x, y int) bool {
+	return e.dark[y][x]
+}
+
+// Flip inverts the module at x, y and returns the new score.
+func (e *PenaltyEvaluator) Flip(x, y int) PenaltyScore {
+	// Blocks containing x, y before and after.
+	for dy := -1; dy <= 0; dy++ {
+		for dx := -1; dx <= 0; dx++ {
+			if sameBlock(e.dark, x+dx, y+dy) {
+				e.n2 -= penaltyN2
+			}
+		}
+	}
+	e.dark[y][x] = !e.dark[y][x]
+	if e.dark[y][x] {
+		e.ndark++
+	} else {
+		e.ndark--
+	}
+	for dy := -1; dy <= 0; dy++ {
+		for dx := -1; dx <= 0; dx++ {
+			if sameBlock(e.dark, x+dx, y+dy) {
+				e.n2 += penaltyN2
+			}
+		}
+	}
+
+	// Row y and column x.
+	for dir, j := range [2]int{y, x} {
+		n1, n3 := linePenalty(e.dark, dir, j)
+		e.n1s += n1 - e.n1[dir][j]
+		e.n3s += n3 - e.n3[dir][j]
+		e.n1[dir][j], e.n3[dir][j] = n1, n3
+	}
+	return e.Score()
+}
+
+// Delta returns the change in the total score
+// that flipping the module at x, y would cause.
+func (e *PenaltyEvaluator) Delta(x, y int) int {
+	before := e.Score().Total()
+	after := e.Flip(x, y).Total()
+	e.Flip(x, y)
+	return after - before
+}
@@ -0,0 +1,493 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coding
+
+import (
+	"errors"
+	"image"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// parseGrid parses rows of '#' (dark) and '.' (light) modules.
+func parseGrid(s string) [][]bool {
+	var g [][]bool
+	for _, line := range strings.Fields(s) {
+		row := make([]bool, len(line))
+		for i, c := range line {
+			row[i] = c == '#'
+		}
+		g = append(g, row)
+	}
+	return g
+}
+
+var penaltyTests = []struct {
+	grid           string
+	n1, n2, n3, n4 int
+}{
+	// All light: a run of 5 in each row and column,
+	// sixteen 2×2 blocks, and no dark modules.
+	{
+		`.....
+		 .....
+		 .....
+		 .....
+		 .....`,
+		30, 48, 0, 100,
+	},
+	// Checkerboard: no runs, no blocks, half dark.
+	{
+		`#.#.
+		 .#.#
+		 #.#.
+		 .#.#`,
+		0, 0, 0, 0,
+	},
+	// A finder-like pattern in the top row, with light
+	// modules (and the quiet zone) on both sides.
+	{
+		`#.###.#....
+		 ...........
+		 ...........
+		 ...........
+		 ...........
+		 ...........
+		 ...........
+		 ...........
+		 ...........
+		 ...........
+		 ...........`,
+		// Runs: 10 light rows and 6 light columns of 11,
+		// and 5 columns of 10 below a dark module.
+		// Blocks: 90 below the top row and 3 in it.
+		// Dark proportion 4%: nine 5% steps from one half.
+		10*9 + 6*9 + 5*8, 3 * 93, 40, 90,
+	},
+	// One 2×2 block, and dark proportion 44%:
+	// one full 5% step from one half.
+	{
+		`##.#.
+		 .#.#.
+		 #.#.#
+		 .#...
+		 #...#`,
+		0, 3, 0, 10,
+	},
+}
+
+func TestPenalties(t *testing.T) {
+	for i, tt := range penaltyTests {
+		n1, n2, n3, n4 := penalties(parseGrid(tt.grid))
+		if n1 != tt.n1 || n2 != tt.n2 || n3 != tt.n3 || n4 != tt.n4 {
+			t.Errorf("#%d: penalties = %d, %d, %d, %d, want %d, %d, %d, %d",
+				i, n1, n2, n3, n4, tt.n1, tt.n2, tt.n3, tt.n4)
+		}
+	}
+}
+
+func TestPenalty(t *testing.T) {
+	p, err := NewPlan(3, M, Mask2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := p.EncodeCodewords(make([]byte, p.DataBytes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The check bytes of all-zero data are zero too,
+	// so the code has the same colors as the plan.
+	if Penalty(p) != c.Penalty() {
+		t.Errorf("Penalty(p) = %d, c.Penalty() = %d", Penalty(p), c.Penalty())
+	}
+	if Penalty(p.WithQuietZone(QuietZone)) != Penalty(p) {
+		t.Errorf("quiet zone changes penalty")
+	}
+	// Every code has three finder patterns, each of which
+	// looks like a finder pattern both across and down at
+	// least once.
+	if n := c.Penalty(); n < 6*penaltyN3 {
+		t.Errorf("c.Penalty() = %d, want at least %d", n, 6*penaltyN3)
+	}
+}
+
+func TestMaskAuto(t *testing.T) {
+	for _, tt := range []struct {
+		v    Version
+		l    Level
+		text string
+	}{
+		{1, M, "HELLO WORLD"},
+		{2, L, "https://example.com/"},
+		{7, Q, strings.Repeat("mask selection ", 5)},
+	} {
+		auto, err := NewPlan(tt.v, tt.l, MaskAuto)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if auto.Mask.String() != "auto" {
+			t.Errorf("auto mask String = %q", auto.Mask.String())
+		}
+		m, err := auto.ChooseMask(String(tt.text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := auto.Encode(String(tt.text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for m1 := Mask0; m1 <= Mask7; m1++ {
+			p, _ := NewPlan(tt.v, tt.l, m1)
+			c1, err := p.Encode(String(tt.text))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m1 == m && !reflect.DeepEqual(c, c1) {
+				t.Errorf("%q: auto code differs from code with chosen %v", tt.text, m)
+			}
+			if c1.Penalty() < c.Penalty() || c1.Penalty() == c.Penalty() && m1 < m {
+				t.Errorf("%q: chose %v with penalty %d, but %v has %d", tt.text, m, c.Penalty(), m1, c1.Penalty())
+			}
+		}
+		data, err := Decode(c)
+		if err != nil || string(data) != tt.text {
+			t.Errorf("%q: Decode = %q, %v", tt.text, data, err)
+		}
+
+		// Quiet zones do not affect the choice.
+		cq, err := auto.WithQuietZone(QuietZone).Encode(String(tt.text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for y := 0; y < cq.Size; y++ {
+			for x := 0; x < cq.Size; x++ {
+				if cq.Black(x, y) != c.Black(x-QuietZone, y-QuietZone) {
+					t.Fatalf("%q: code with quiet zone differs at %d,%d", tt.text, x, y)
+				}
+			}
+		}
+	}
+}
+
+func TestMaskSelector(t *testing.T) {
+	text := String("selectors")
+	p, err := NewPlan(2, M, MaskAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	best, err := p.ChooseMask(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// dark picks the mask that makes the most dark modules.
+	dark := MaskSelectorFunc(func(code func(Mask) *Code) Mask {
+		best, most := Mask0, -1
+		for m := Mask0; m <= Mask7; m++ {
+			n := 0
+			for _, b := range code(m).Bitmap {
+				for ; b != 0; b &= b - 1 {
+					n++
+				}
+			}
+			if n > most {
+				best, most = m, n
+			}
+		}
+		return best
+	})
+
+	for _, tt := range []struct {
+		sel  MaskSelector
+		want Mask
+	}{
+		{EvaluateAll, best},
+		{PickFirst(-1), best},
+		{PickFirst(1 << 30), Mask0},
+		{Fixed(Mask6), Mask6},
+	} {
+		p.Selector = tt.sel
+		m, err := p.ChooseMask(text)
+		if err != nil || m != tt.want {
+			t.Errorf("%T: ChooseMask = %v, %v, want %v", tt.sel, m, err, tt.want)
+		}
+	}
+
+	p.Selector = dark
+	m, err := p.ChooseMask(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := p.Encode(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, _ := NewPlan(2, M, m)
+	if want, _ := q.Encode(text); !reflect.DeepEqual(c, want) {
+		t.Errorf("custom selector: code differs from code with %v", m)
+	}
+
+	p.Selector = Fixed(9)
+	if _, err := p.Encode(text); !errors.Is(err, ErrInvalidMask) {
+		t.Errorf("Fixed(9): Encode error %v, want ErrInvalidMask", err)
+	}
+}
+
+func TestSetCustomMask(t *testing.T) {
+	// A standard mask, written as a custom one, is recognized.
+	p, _ := NewPlan(4, Q, MaskAuto)
+	m, damaged, err := p.SetCustomMask(func(x, y int) bool { return x%3 == 0 })
+	if err != nil || m != Mask2 || damaged != 0 {
+		t.Fatalf("SetCustomMask(column mod 3) = %v, %d, %v, want mask 2, 0", m, damaged, err)
+	}
+	want, _ := NewPlan(4, Q, Mask2)
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("custom mask 2 plan differs from NewPlan")
+	}
+	if err := p.CheckFormat(); err != nil {
+		t.Error(err)
+	}
+
+	// Mask 1 with a blot in the data area: the format says mask 1,
+	// and the codewords under the blot are damaged.
+	p, _ = NewPlan(4, Q, Mask5)
+	blot := func(x, y int) bool { return 20 <= x && x < 24 && 20 <= y && y < 24 }
+	m, damaged, err = p.SetCustomMask(func(x, y int) bool { return y%2 == 0 != blot(x, y) })
+	if err != nil || m != Mask1 {
+		t.Fatalf("SetCustomMask(blotted mask 1) = %v, %v, want mask 1", m, err)
+	}
+	ref, _ := NewPlan(4, Q, Mask1)
+	bad := make(map[uint]bool)
+	for y, row := range p.Pixel {
+		for x, pix := range row {
+			if pix != ref.Pixel[y][x] {
+				if !blot(x, y) {
+					t.Fatalf("pixel %d,%d outside blot differs", x, y)
+				}
+				bad[pix.Offset()/8] = true
+			}
+		}
+	}
+	if damaged != len(bad) || damaged == 0 {
+		t.Errorf("damaged = %d, want %d", damaged, len(bad))
+	}
+}
+
+func TestEvaluateMasks(t *testing.T) {
+	text := Alpha("EVALUATE")
+	p, _ := NewPlan(1, H, MaskAuto)
+	scores, err := p.EvaluateMasks(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	best, err := p.ChooseMask(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for m, s := range scores {
+		q, _ := NewPlan(1, H, Mask(m))
+		c, err := q.Encode(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.PenaltyScore() != s || c.Penalty() != s.Total() {
+			t.Errorf("mask %d: score %v, code has %v", m, s, c.PenaltyScore())
+		}
+		if s.Total() < scores[best].Total() {
+			t.Errorf("mask %d scores %v, better than chosen mask %d", m, s, int(best))
+		}
+	}
+	if s := (PenaltyScore{1, 2, 40, 10}).String(); s != "N1=1 N2=2 N3=40 N4=10 total=53" {
+		t.Errorf("PenaltyScore.String() = %q", s)
+	}
+}
+
+func TestEvaluateParallel(t *testing.T) {
+	for _, v := range []Version{1, 10, 40} {
+		p, err := NewPlan(v, L, MaskAuto)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text := String(strings.Repeat("parallel ", p.DataBytes/10))
+		want, err := p.ChooseMask(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, n := range []int{0, 1, 3, 8, 100} {
+			p.Selector = EvaluateParallel(n)
+			if m, err := p.ChooseMask(text); err != nil || m != want {
+				t.Errorf("version %d, %d workers: ChooseMask = %v, %v, want %v", v, n, m, err, want)
+			}
+		}
+		p.Selector = nil
+	}
+}
+
+func benchmarkSelector(b *testing.B, sel MaskSelector) {
+	p, err := NewPlan(40, L, MaskAuto)
+	if err != nil {
+		b.Fatal(err)
+	}
+	p.Selector = sel
+	text := String(strings.Repeat("x", p.DataBytes-3))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.Encode(text); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEvaluateAll(b *testing.B)      { benchmarkSelector(b, EvaluateAll) }
+func BenchmarkEvaluateParallel(b *testing.B) { benchmarkSelector(b, EvaluateParallel(0)) }
+func BenchmarkPickFirst(b *testing.B)        { benchmarkSelector(b, PickFirst(1<<30)) }
+
+func TestPenaltyEvaluator(t *testing.T) {
+	p, _ := NewPlan(3, L, Mask4)
+	c, err := p.Encode(String("incremental"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewPenaltyEvaluator(c)
+	if e.Score() != c.PenaltyScore() {
+		t.Fatalf("initial score %v, want %v", e.Score(), c.PenaltyScore())
+	}
+
+	// Flip pseudo-random modules, comparing with a full rescore.
+	x, y := 0, 0
+	for i := 0; i < 500; i++ {
+		x = (x*7 + 3 + i) % c.Size
+		y = (y*5 + 11 + i/3) % c.Size
+		d := e.Delta(x, y)
+		before := e.Score()
+		got := e.Flip(x, y)
+		c.Bitmap[y*c.Stride+x/8] ^= 1 << uint(7-x&7)
+		if want := c.PenaltyScore(); got != want {
+			t.Fatalf("flip %d at %d,%d: score %v, want %v", i, x, y, got, want)
+		}
+		if d != got.Total()-before.Total() {
+			t.Fatalf("flip %d at %d,%d: Delta = %d, want %d", i, x, y, d, got.Total()-before.Total())
+		}
+		if e.Black(x, y) != c.Black(x, y) {
+			t.Fatalf("flip %d at %d,%d: Black disagrees", i, x, y)
+		}
+	}
+}
+
+func BenchmarkPenaltyFlip(b *testing.B) {
+	p, _ := NewPlan(40, L, Mask0)
+	c, err := p.EncodeCodewords(make([]byte, p.DataBytes))
+	if err != nil {
+		b.Fatal(err)
+	}
+	e := NewPenaltyEvaluator(c)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e.Flip(i%c.Size, i/c.Size%c.Size)
+	}
+}
+
+func TestPenaltyRuns(t *testing.T) {
+	for m := Mask0; m <= Mask7; m++ {
+		p, _ := NewPlan(3, M, m)
+		c, err := p.Encode(String("penalty runs"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var n1, n3 int
+		for _, r := range c.PenaltyRuns() {
+			switch r.Rule {
+			case 1:
+				n1 += penaltyN1 + r.Len - 5
+			case 3:
+				n3 += penaltyN3
+			}
+			end := r.Start.Add(image.Pt(r.Len-1, 0))
+			if r.Down {
+				end = r.Start.Add(image.Pt(0, r.Len-1))
+			}
+			if !end.In(image.Rect(0, 0, c.Size, c.Size)) {
+				t.Errorf("mask %d: run %+v leaves the code", m, r)
+			}
+		}
+		if s := c.PenaltyScore(); n1 != s.N1 || n3 != s.N3 {
+			t.Errorf("mask %d: runs add up to N1=%d N3=%d, want %v", m, n1, n3, s)
+		}
+	}
+}
+
+func TestMaskImage(t *testing.T) {
+	p, _ := NewPlan(2, M, Mask4)
+	m := p.MaskImage(3)
+	if b := m.Bounds(); b.Dx() != 25*3 || b.Dy() != 25*3 {
+		t.Fatalf("MaskImage bounds %v", b)
+	}
+	f := MaskFunc(Mask4)
+	for y, row := range p.Pixel {
+		for x, pix := range row {
+			got := m.RGBAAt(3*x+1, 3*y+2)
+			switch r := pix.Role(); r {
+			case Data, Check, Extra:
+				if want := f(y, x); (got == maskInverted) != want || got != maskInverted && got != maskKept {
+					t.Fatalf("data pixel %d,%d is %v, inverted %v", x, y, got, want)
+				}
+			default:
+				if got != fixedDark && got != fixedLight {
+					t.Fatalf("%v pixel %d,%d is %v", r, x, y, got)
+				}
+			}
+		}
+	}
+}
+
+func TestPenaltyImage(t *testing.T) {
+	p, _ := NewPlan(1, L, Mask0)
+	c, err := p.Encode(String("hot"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := PenaltyImage(c, 1)
+	// Row 2 of the top left finder pattern is a finder-like
+	// pattern with the quiet zone before it.
+	for x := 0; x < 7; x++ {
+		if got := m.RGBAAt(x, 2); got != hotFinder {
+			t.Errorf("module %d,2 is %v, want finder color", x, got)
+		}
+	}
+	// The 3×3 center of the finder pattern holds 2×2 blocks.
+	if got := m.RGBAAt(3, 3); got != hotFinder {
+		t.Errorf("center module is %v, want finder color (patterns win)", got)
+	}
+	// Some modules are marked as runs exactly when N1 is positive.
+	n1, _, _, _ := penalties(parseGrid(codeGrid(c)))
+	runs := 0
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if m.RGBAAt(x, y) == hotRun {
+				runs++
+			}
+		}
+	}
+	if (n1 > 0) != (runs > 0) {
+		t.Errorf("N1 = %d but %d modules marked as runs", n1, runs)
+	}
+}
+
+// codeGrid returns c in the form parseGrid reads.
+func codeGrid(c *Code) string {
+	var b []byte
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.Black(x, y) {
+				b = append(b, '#')
+			} else {
+				b = append(b, '.')
+			}
+		}
+		b = append(b, '\n')
+	}
+	return string(b)
+}
@@ -0,0 +1,824 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coding
+
+import (
+	"errors"
+	"image"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// alignTable is ISO/IEC 18004 Annex E, Table E.1: the row and
+// column coordinates of the alignment pattern centers by version.
+var alignTable = [41][]int{
+	2:  {6, 18},
+	3:  {6, 22},
+	4:  {6, 26},
+	5:  {6, 30},
+	6:  {6, 34},
+	7:  {6, 22, 38},
+	8:  {6, 24, 42},
+	9:  {6, 26, 46},
+	10: {6, 28, 50},
+	11: {6, 30, 54},
+	12: {6, 32, 58},
+	13: {6, 34, 62},
+	14: {6, 26, 46, 66},
+	15: {6, 26, 48, 70},
+	16: {6, 26, 50, 74},
+	17: {6, 30, 54, 78},
+	18: {6, 30, 56, 82},
+	19: {6, 30, 58, 86},
+	20: {6, 34, 62, 90},
+	21: {6, 28, 50, 72, 94},
+	22: {6, 26, 50, 74, 98},
+	23: {6, 30, 54, 78, 102},
+	24: {6, 28, 54, 80, 106},
+	25: {6, 32, 58, 84, 110},
+	26: {6, 30, 58, 86, 114},
+	27: {6, 34, 62, 90, 118},
+	28: {6, 26, 50, 74, 98, 122},
+	29: {6, 30, 54, 78, 102, 126},
+	30: {6, 26, 52, 78, 104, 130},
+	31: {6, 30, 56, 82, 108, 134},
+	32: {6, 34, 60, 86, 112, 138},
+	33: {6, 30, 58, 86, 114, 142},
+	34: {6, 34, 62, 90, 118, 146},
+	35: {6, 30, 54, 78, 102, 126, 150},
+	36: {6, 24, 50, 76, 102, 128, 154},
+	37: {6, 28, 54, 80, 106, 132, 158},
+	38: {6, 32, 58, 84, 110, 136, 162},
+	39: {6, 26, 54, 82, 110, 138, 166},
+	40: {6, 30, 58, 86, 114, 142, 170},
+}
+
+func TestAlignment(t *testing.T) {
+	for v := Version(1); v <= 40; v++ {
+		p, err := vplan(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Collect the centers: the middles of
+		// 5×5 squares of alignment pixels.
+		var got [][2]int
+		n := len(p.Pixel)
+		for y := 2; y+2 < n; y++ {
+			for x := 2; x+2 < n; x++ {
+				all := true
+				for dy := -2; dy <= 2; dy++ {
+					for dx := -2; dx <= 2; dx++ {
+						all = all && p.Pixel[y+dy][x+dx].Role() == Alignment
+					}
+				}
+				if all {
+					got = append(got, [2]int{y, x})
+				}
+			}
+		}
+
+		// Every pair of table coordinates is a center,
+		// except the three that overlap the finder patterns.
+		var want [][2]int
+		c := alignTable[v]
+		for _, y := range c {
+			for _, x := range c {
+				last := c[len(c)-1]
+				if x == 6 && y == 6 || x == 6 && y == last || x == last && y == 6 {
+					continue
+				}
+				want = append(want, [2]int{y, x})
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("version %d: alignment centers %v, want %v", v, got, want)
+		}
+	}
+}
+
+func TestDarkModule(t *testing.T) {
+	for v := Version(1); v <= 40; v++ {
+		for m := Mask(0); m < 8; m++ {
+			p, err := NewPlan(v, H, m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pix := p.Pixel[4*int(v)+9][8]
+			if pix.Role() != Dark || pix&Black == 0 {
+				t.Errorf("version %d mask %d: dark module is %v", v, m, pix)
+			}
+		}
+	}
+}
+
+func TestInterleave(t *testing.T) {
+	// 5-Q: blocks of 15, 15, 16, and 16 data codewords
+	// starting at 0, 15, 30, and 46, each with 18 check codewords.
+	p, err := NewPlan(5, Q, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := p.Interleave()
+	want := map[int][]int{
+		0:  {0, 15, 30, 46, 1},
+		58: {44, 60, 45, 61},
+		62: {62, 80, 98, 116, 63},
+	}
+	for i, w := range want {
+		if got := order[i : i+len(w)]; !reflect.DeepEqual(got, w) {
+			t.Errorf("5-Q order[%d:] = %v, want %v", i, got, w)
+		}
+	}
+
+	for v := Version(1); v <= 40; v++ {
+		for l := L; l <= H; l++ {
+			p, err := NewPlan(v, l, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			seen := make([]bool, p.DataBytes+p.CheckBytes)
+			for _, cw := range p.Interleave() {
+				seen[cw] = true
+			}
+			for cw, ok := range seen {
+				if !ok {
+					t.Errorf("%v-%v: codeword %d missing from order", v, l, cw)
+					break
+				}
+			}
+		}
+	}
+
+	tp, err := NewTemplate(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order := tp.Interleave(); order != nil {
+		t.Errorf("template order = %v, want nil", order)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for v := Version(1); v <= 40; v++ {
+		for l := L; l <= H; l++ {
+			p, err := NewPlan(v, l, Mask(v)%8)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bad := p.Validate(); bad != nil {
+				t.Errorf("%v-%v: %q", v, l, bad)
+			}
+		}
+	}
+
+	p, err := NewPlan(7, M, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Pixel[3][3] ^= Black                 // finder center
+	p.Pixel[6][10] ^= Black                // timing
+	p.Pixel[0][len(p.Pixel)-11] = Pixel(0) // version
+	p.Pixel[len(p.Pixel)-8][8] &^= Black   // dark module
+	if bad := p.Validate(); len(bad) != 4 {
+		t.Errorf("damaged plan: %q, want 4 violations", bad)
+	}
+}
+
+func TestNewPlanStrict(t *testing.T) {
+	for v := Version(1); v <= 40; v++ {
+		for l := L; l <= H; l++ {
+			if _, err := NewPlanStrict(v, l, Mask(l)); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+
+	for _, tt := range []struct {
+		v   Version
+		l   Level
+		m   Mask
+		err error
+	}{
+		{0, L, 0, ErrInvalidVersion},
+		{41, L, 0, ErrInvalidVersion},
+		{1, -1, 0, ErrInvalidLevel},
+		{1, H + 1, 0, ErrInvalidLevel},
+		{1, L, -1, ErrInvalidMask},
+		{1, L, 9, ErrInvalidMask},
+	} {
+		_, err := NewPlanStrict(tt.v, tt.l, tt.m)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("NewPlanStrict(%d, %d, %d) = %v, want %v", int(tt.v), int(tt.l), int(tt.m), err, tt.err)
+		}
+	}
+}
+
+func TestMask(t *testing.T) {
+	if s := Mask4.String(); s != "mask 4: (i/2 + j/3) mod 2 = 0" {
+		t.Errorf("Mask4.String() = %q", s)
+	}
+	if s := Mask(9).String(); s != "Mask(9)" {
+		t.Errorf("Mask(9).String() = %q", s)
+	}
+	for _, m := range []Mask{-1, 9} {
+		if _, err := NewPlan(1, L, m); err == nil {
+			t.Errorf("NewPlan accepted mask %d", int(m))
+		}
+	}
+}
+
+func TestMarshalPlan(t *testing.T) {
+	for _, v := range []Version{1, 7, 40} {
+		p, err := NewPlan(v, Q, Mask3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Pad = []byte{1, 2, 3}
+		p.Remainder = 5
+		data, err := p.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var q Plan
+		if err := q.UnmarshalBinary(data); err != nil {
+			t.Fatalf("version %d: %v", v, err)
+		}
+		if !reflect.DeepEqual(p, &q) {
+			t.Errorf("version %d: unmarshaled plan differs", v)
+		}
+		if err := q.UnmarshalBinary(data[:len(data)-1]); err == nil {
+			t.Errorf("version %d: truncated plan unmarshaled", v)
+		}
+		if v == 40 {
+			t.Logf("version 40 plan is %d bytes", len(data))
+		}
+	}
+}
+
+func TestUnmarshalCorrupt(t *testing.T) {
+	p, err := NewPlan(2, M, Mask1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var q Plan
+	for n := 0; n < len(data); n++ {
+		if err := q.UnmarshalBinary(data[:n]); err == nil {
+			t.Fatalf("plan truncated to %d of %d bytes unmarshaled", n, len(data))
+		}
+	}
+
+	// A header claiming a huge quiet zone, with no pixels after it,
+	// must fail before allocating the grid.
+	var b []byte
+	b = append(b, planMagic...)
+	b = append(b, 1, byte(L), 0) // version, level, mask
+	b = append(b, 0, 0, 0, 0, 0) // bytes, blocks, groups, pad
+	b = append(b, 0)             // remainder
+	b = append(b, 0x80, 0x80, 0x10, 0, 0x80, 0x80, 0x20)
+	if err := q.UnmarshalBinary(b); err == nil {
+		t.Errorf("plan with huge quiet zone unmarshaled")
+	}
+
+	bad := []func(p *Plan){
+		func(p *Plan) { p.Groups[0].DataBytes++ },
+		func(p *Plan) { p.Blocks++ },
+		func(p *Plan) { p.DataBytes, p.CheckBytes = 1000, 1000; p.Groups[0] = BlockGroup{1, 1000, 1000} },
+		func(p *Plan) { p.Remainder = 0x100 },
+		func(p *Plan) {
+			for _, pt := range p.DataModules() {
+				p.Pixel[pt.Y][pt.X] = Data.Pixel() | OffsetPixel(uint(8*(p.DataBytes+p.CheckBytes)))
+				return
+			}
+		},
+		func(p *Plan) { p.Pixel[0][0] |= OffsetPixel(3) },
+	}
+	for i, f := range bad {
+		q := p.DeepCopy()
+		f(q)
+		data, err := q.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r Plan
+		if err := r.UnmarshalBinary(data); err == nil {
+			t.Errorf("corrupt plan %d unmarshaled", i)
+		}
+	}
+
+	tp, _ := NewTemplate(3)
+	data, err = tp.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.UnmarshalBinary(data); err != nil || !reflect.DeepEqual(&q, tp) {
+		t.Errorf("template does not survive marshaling: %v", err)
+	}
+}
+
+func TestPlanShared(t *testing.T) {
+	p, err := NewPlan(3, M, Mask2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := p.DeepCopy()
+	if !reflect.DeepEqual(p, q) {
+		t.Fatalf("DeepCopy differs from original")
+	}
+
+	// Editing a plan must not affect later plans,
+	// which are copied from the same cached layout.
+	for y, row := range p.Pixel {
+		for x := range row {
+			p.Pixel[y][x] = 0
+		}
+	}
+	p.Groups[0].Blocks = 99
+	p2, err := NewPlan(3, M, Mask2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p2, q) {
+		t.Errorf("NewPlan result changed after editing an earlier plan")
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	for _, v := range []Version{1, 7, 40} {
+		tp, err := NewTemplate(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := NewPlan(v, H, Mask5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bad := tp.Validate(); bad != nil {
+			t.Errorf("version %d template: %q", v, bad)
+		}
+		for y, row := range tp.Pixel {
+			for x, pix := range row {
+				full := p.Pixel[y][x]
+				switch r := pix.Role(); {
+				case r == Format:
+					if pix&Black != 0 || full.Role() != Format {
+						t.Errorf("version %d: format pixel %d,%d is %v", v, x, y, pix)
+					}
+				case r == 0:
+					if fr := full.Role(); fr != Data && fr != Check && fr != Extra {
+						t.Errorf("version %d: free pixel %d,%d is %v in plan", v, x, y, full)
+					}
+				case pix != full:
+					t.Errorf("version %d: pixel %d,%d is %v, plan has %v", v, x, y, pix, full)
+				}
+			}
+		}
+	}
+}
+
+func TestQuietZone(t *testing.T) {
+	p, err := NewPlan(2, M, Mask5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := p.WithQuietZone(QuietZone)
+	if bad := q.Validate(); bad != nil {
+		t.Errorf("plan with quiet zone: %q", bad)
+	}
+	if len(p.Pixel) != 25 || len(q.Pixel) != 33 || q.QuietZone != 4 {
+		t.Fatalf("sizes %d, %d, quiet zone %d", len(p.Pixel), len(q.Pixel), q.QuietZone)
+	}
+
+	c, err := p.Encode(String("quiet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cq, err := q.Encode(String("quiet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < cq.Size; y++ {
+		for x := 0; x < cq.Size; x++ {
+			if cq.Black(x, y) != c.Black(x-4, y-4) {
+				t.Fatalf("module %d,%d differs", x, y)
+			}
+		}
+	}
+
+	data, err := q.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var u Plan
+	if err := u.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(q, &u) {
+		t.Errorf("unmarshaled plan with quiet zone differs")
+	}
+
+	q.Pixel[1][20] |= Black
+	if bad := q.Validate(); len(bad) != 1 {
+		t.Errorf("damaged quiet zone: %q, want 1 violation", bad)
+	}
+}
+
+func TestDataModules(t *testing.T) {
+	for v := Version(1); v <= 40; v++ {
+		p, err := NewPlan(v, Q, Mask1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		list := p.DataModules()
+		if len(list) != rawDataModules(v) {
+			t.Errorf("version %d: %d modules, want %d", v, len(list), rawDataModules(v))
+			continue
+		}
+		siz := len(p.Pixel)
+		if m := list[0]; m.X != siz-1 || m.Y != siz-1 || m.Bit != 8*p.Interleave()[0] {
+			t.Errorf("version %d: first module %+v", v, m)
+		}
+		order := p.Interleave()
+		for i, m := range list {
+			if p.Pixel[m.Y][m.X].Offset() != uint(m.Bit) {
+				t.Fatalf("version %d: module %+v has offset %d", v, m, p.Pixel[m.Y][m.X].Offset())
+			}
+			if i < 8*len(order) && m.Bit != 8*order[i/8]+i%8 {
+				t.Fatalf("version %d: module %d is bit %d, want %d", v, i, m.Bit, 8*order[i/8]+i%8)
+			}
+		}
+	}
+
+	p, _ := NewPlan(1, L, 0)
+	m := p.WithQuietZone(QuietZone).DataModules()
+	if m[0].X != 21+3 || m[0].Y != 21+3 {
+		t.Errorf("with quiet zone: first module %+v", m[0])
+	}
+}
+
+func TestDiffPlans(t *testing.T) {
+	a, _ := NewPlan(5, M, Mask2)
+	b, _ := NewPlan(5, M, Mask2)
+	d, err := DiffPlans(a, b)
+	if err != nil || d.Len() != 0 || d.String() != "no differences" {
+		t.Fatalf("identical plans: %v, %v", d, err)
+	}
+
+	// Changing the mask changes format and data pixels only.
+	c, _ := NewPlan(5, M, Mask3)
+	d, err = DiffPlans(a, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for r := range d.Changed {
+		if r != Format && r != Data && r != Check && r != Extra {
+			t.Errorf("mask change altered %v pixels", r)
+		}
+	}
+
+	// A code encoded from a plan matches its fixed pixels,
+	// except for damage.
+	code, err := a.Encode(String("diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err = a.DiffCode(code)
+	if err != nil || d.Len() != 0 {
+		t.Fatalf("code from plan: %v, %v", d, err)
+	}
+	code.Bitmap[6*code.Stride+10/8] ^= 1 << (7 - 10%8) // timing
+	code.Bitmap[8*code.Stride+0] ^= 1 << 7             // format
+	d, _ = a.DiffCode(code)
+	if s := d.String(); s != "timing:1 format:1" {
+		t.Errorf("damaged code: %s, want timing:1 format:1", s)
+	}
+
+	if _, err := DiffPlans(a, b.WithQuietZone(1)); err == nil {
+		t.Errorf("DiffPlans accepted plans of different sizes")
+	}
+}
+
+func TestCheckFormat(t *testing.T) {
+	for l := L; l <= H; l++ {
+		for m := Mask0; m <= Mask7; m++ {
+			p, err := NewPlan(8, l, m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.CheckFormat(); err != nil {
+				t.Errorf("%v/%d: %v", l, int(m), err)
+			}
+			if err := p.WithQuietZone(2).CheckFormat(); err != nil {
+				t.Errorf("%v/%d with quiet zone: %v", l, int(m), err)
+			}
+
+			// The coordinates match what the decoder reads.
+			c, err := p.Encode(String("format"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			first, second := FormatCoords(c.Size)
+			want := formatBits(l, m) ^ 0x5412
+			for i := 0; i < 15; i++ {
+				for _, pt := range []image.Point{first[i], second[i]} {
+					if c.Black(pt.X, pt.Y) != (want>>uint(i)&1 == 1) {
+						t.Fatalf("%v/%d: bit %d at %v is wrong in code", l, int(m), i, pt)
+					}
+				}
+			}
+		}
+	}
+
+	p, _ := NewPlan(3, Q, Mask6)
+	first, second := FormatCoords(len(p.Pixel))
+	p.Pixel[second[9].Y][second[9].X] ^= Black
+	if err := p.CheckFormat(); err == nil || !strings.Contains(err.Error(), "copy 2") {
+		t.Errorf("damaged second copy: %v", err)
+	}
+	p.Pixel[first[0].Y][first[0].X] = Data.Pixel()
+	if err := p.CheckFormat(); err == nil || !strings.Contains(err.Error(), "copy 1") {
+		t.Errorf("missing first copy pixel: %v", err)
+	}
+	tp, _ := NewTemplate(3)
+	if err := tp.CheckFormat(); err == nil {
+		t.Errorf("template has format information")
+	}
+}
+
+func TestSetMaskLevel(t *testing.T) {
+	for _, v := range []Version{1, 5, 7, 22} {
+		p, err := NewPlan(v, M, Mask3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q := p.WithQuietZone(QuietZone)
+		for l := L; l <= H; l++ {
+			for m := Mask0; m <= Mask7; m++ {
+				want, err := NewPlan(v, l, m)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := p.SetLevel(l); err != nil {
+					t.Fatal(err)
+				}
+				if err := p.SetMask(m); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(p, want) {
+					d, _ := DiffPlans(want, p)
+					t.Fatalf("%v-%v mask %d: plan differs from NewPlan: %v", v, l, int(m), d)
+				}
+				if err := q.SetMask(m); err != nil {
+					t.Fatal(err)
+				}
+				if err := q.SetLevel(l); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(q, want.WithQuietZone(QuietZone)) {
+					t.Fatalf("%v-%v mask %d with quiet zone: plan differs from NewPlan", v, l, int(m))
+				}
+			}
+		}
+	}
+
+	p, _ := NewPlan(1, L, 0)
+	if err := p.SetMask(8); !errors.Is(err, ErrInvalidMask) {
+		t.Errorf("SetMask(8) = %v", err)
+	}
+	if err := p.SetLevel(H + 1); !errors.Is(err, ErrInvalidLevel) {
+		t.Errorf("SetLevel(H+1) = %v", err)
+	}
+}
+
+func TestPlanBuilder(t *testing.T) {
+	for v := Version(1); v <= 40; v++ {
+		// AlignmentCenters matches Table E.1, less the three
+		// centers that would overlap the finder patterns.
+		var want []image.Point
+		at := alignTable[v]
+		for i, y := range at {
+			for j, x := range at {
+				if i == 0 && (j == 0 || j == len(at)-1) || i == len(at)-1 && j == 0 {
+					continue
+				}
+				want = append(want, image.Pt(x, y))
+			}
+		}
+		if got := AlignmentCenters(v); !reflect.DeepEqual(got, want) {
+			t.Errorf("AlignmentCenters(%d) = %v, want %v", v, got, want)
+		}
+
+		b := &PlanBuilder{Version: v, Level: Q, Mask: Mask4}
+		p, err := b.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		std, _ := NewPlan(v, Q, Mask4)
+		if !reflect.DeepEqual(p, std) {
+			t.Fatalf("version %d: default builder plan differs from NewPlan", v)
+		}
+	}
+
+	// Move the alignment pattern and reserve a corner.
+	b := &PlanBuilder{
+		Version:  2,
+		Level:    L,
+		Mask:     Mask1,
+		Align:    []image.Point{{12, 12}},
+		Reserved: []image.Rectangle{image.Rect(15, 15, 25, 25)},
+	}
+	p, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	std, _ := NewPlan(2, L, Mask1)
+	if p.DataBytes+p.CheckBytes >= std.DataBytes+std.CheckBytes || p.CheckBytes != std.CheckBytes {
+		t.Errorf("custom plan has %d+%d bytes, standard %d+%d", p.DataBytes, p.CheckBytes, std.DataBytes, std.CheckBytes)
+	}
+	if p.Pixel[12][12] != Alignment.Pixel()|Black || p.Pixel[18][18].Role() != Unused {
+		t.Errorf("custom plan: center %v, reserved %v", p.Pixel[12][12], p.Pixel[18][18])
+	}
+	c, err := p.Encode(String("custom"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := parseData(2, p.readBytes(c)[:p.DataBytes])
+	if err != nil || string(data) != "custom" {
+		t.Errorf("custom plan: read %q, %v", data, err)
+	}
+	for y := 15; y < 25; y++ {
+		for x := 15; x < 25; x++ {
+			if c.Black(x, y) {
+				t.Fatalf("reserved pixel %d,%d is black", x, y)
+			}
+		}
+	}
+
+	// A custom plan keeps its layout: SetLevel refuses to change it,
+	// and SetMask leaves the reserved region alone.
+	if !p.Custom {
+		t.Errorf("custom plan is not marked Custom")
+	}
+	q := p.DeepCopy()
+	if err := q.SetLevel(H); err == nil {
+		t.Errorf("SetLevel on custom plan succeeded")
+	}
+	if err := q.SetMask(Mask6); err != nil {
+		t.Fatal(err)
+	}
+	if q.DataBytes != p.DataBytes || q.Groups[0] != p.Groups[0] {
+		t.Errorf("custom plan layout changed: %d bytes %v, want %d bytes %v", q.DataBytes, q.Groups, p.DataBytes, p.Groups)
+	}
+	for y := 15; y < 25; y++ {
+		for x := 15; x < 25; x++ {
+			if q.Pixel[y][x].Role() != Unused {
+				t.Fatalf("reserved pixel %d,%d is %v after SetLevel, SetMask", x, y, q.Pixel[y][x])
+			}
+		}
+	}
+	data, err = q.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r Plan
+	if err := r.UnmarshalBinary(data); err != nil || !reflect.DeepEqual(&r, q) {
+		t.Errorf("custom plan does not survive marshaling: %v", err)
+	}
+
+	for _, bad := range []*PlanBuilder{
+		{Version: 2, Align: []image.Point{{4, 4}}},
+		{Version: 2, Align: []image.Point{{23, 12}}},
+		{Version: 2, Reserved: []image.Rectangle{image.Rect(0, 0, 3, 3)}},
+		{Version: 1, Level: H, Reserved: []image.Rectangle{image.Rect(9, 9, 21, 21)}},
+	} {
+		if _, err := bad.Build(); err == nil {
+			t.Errorf("Build(%+v) succeeded", bad)
+		}
+	}
+}
+
+func TestDataRegion(t *testing.T) {
+	for v := Version(1); v <= 40; v++ {
+		p, err := NewPlan(v, M, Mask0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := p.DataRegion()
+		raw := rawDataModules(v)
+		if d.Modules != raw || d.Remainder != raw%8 {
+			t.Errorf("version %d: %d modules, %d remainder, want %d, %d", v, d.Modules, d.Remainder, raw, raw%8)
+		}
+		cols, rows := 0, 0
+		for i := range d.Columns {
+			cols += d.Columns[i]
+			rows += d.Rows[i]
+		}
+		if cols != raw || rows != raw || d.Columns[6] != 0 || d.Rows[6] != 0 {
+			t.Errorf("version %d: columns sum to %d, rows to %d, timing column %d, row %d", v, cols, rows, d.Columns[6], d.Rows[6])
+		}
+	}
+
+	// Version 1: the rightmost column has data in all rows but the
+	// nine beside the top right finder pattern and format pixels.
+	p, _ := NewPlan(1, L, 0)
+	if d := p.DataRegion(); d.Columns[20] != 21-9 {
+		t.Errorf("version 1: column 20 has %d data modules, want 12", d.Columns[20])
+	}
+}
+
+func TestNewPlans(t *testing.T) {
+	for _, v := range []Version{1, 10, 40} {
+		plans, err := NewPlans(v, Q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for m, p := range plans {
+			want, _ := NewPlan(v, Q, Mask(m))
+			if !reflect.DeepEqual(p, want) {
+				t.Errorf("version %d: plans[%d] differs from NewPlan", v, m)
+			}
+		}
+		plans[0].Pixel[len(plans[0].Pixel)-1][0] ^= Black
+		if plans[1].Pixel[len(plans[1].Pixel)-1][0] != plans[2].Pixel[len(plans[2].Pixel)-1][0] {
+			t.Errorf("version %d: editing one plan altered another", v)
+		}
+	}
+	if _, err := NewPlans(41, L); !errors.Is(err, ErrInvalidVersion) {
+		t.Errorf("NewPlans(41, L) = %v", err)
+	}
+}
+
+func BenchmarkNewPlan8(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		for m := Mask0; m <= Mask7; m++ {
+			if _, err := NewPlan(40, M, m); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkNewPlans(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := NewPlans(40, M); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestMaskPeriod(t *testing.T) {
+	for m := Mask0; m <= Mask7; m++ {
+		for i := 0; i < 3*maskPeriod; i++ {
+			for j := 0; j < 3*maskPeriod; j++ {
+				if m.Invert(i, j) != maskTab[m][i%maskPeriod][j%maskPeriod] {
+					t.Fatalf("%v: period table wrong at %d,%d", m, i, j)
+				}
+			}
+		}
+	}
+}
+
+func TestMaskFunc(t *testing.T) {
+	// The formulas take (row, column).  The asymmetric masks tell
+	// the two apart: mask 1 depends only on the row, mask 2 only
+	// on the column, and mask 4 divides them differently.
+	for _, tt := range []struct {
+		m        Mask
+		row, col int
+		want     bool
+	}{
+		{Mask1, 0, 1, true},
+		{Mask1, 1, 0, false},
+		{Mask2, 1, 3, true},
+		{Mask2, 3, 1, false},
+		{Mask4, 0, 2, true},
+		{Mask4, 2, 0, false},
+	} {
+		if got := MaskFunc(tt.m)(tt.row, tt.col); got != tt.want {
+			t.Errorf("MaskFunc(%d)(%d, %d) = %v, want %v", int(tt.m), tt.row, tt.col, got, tt.want)
+		}
+	}
+
+	// Plans apply the mask with the same convention:
+	// Pixel[y][x] is row y, column x.
+	for m := Mask0; m <= Mask7; m++ {
+		p, _ := NewPlan(5, L, m)
+		f := MaskFunc(m)
+		for y, row := range p.Pixel {
+			for x, pix := range row {
+				if r := pix.Role(); (r == Data || r == Check) && (pix&Invert != 0) != f(y, x) {
+					t.Fatalf("mask %d: pixel row %d, column %d inverted wrongly", int(m), y, x)
+				}
+			}
+		}
+	}
+	if MaskFunc(MaskAuto) != nil || MaskFunc(-1) != nil {
+		t.Errorf("MaskFunc accepted invalid mask")
+	}
+}
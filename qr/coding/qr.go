@@ -6,9 +6,12 @@
 package coding
 
 import (
+	"errors"
 	"fmt"
+	"image"
 	"strconv"
 	"strings"
+	"sync"
 
 	"code.google.com/p/rsc/gf256"
 )
@@ -48,8 +51,25 @@ func (v Version) DataBytes(l Level) int {
 	return vt.bytes - lev.nblock*lev.check
 }
 
+// Correctable returns the number of damaged codewords that can be
+// corrected in each Reed-Solomon block of a QR code with the given
+// version and level.  In the smallest codes, a few check bytes are
+// reserved to detect misdecoding instead of correcting errors.
+func (v Version) Correctable(l Level) int {
+	p := 0
+	switch {
+	case v == 1 && l == L:
+		p = 3
+	case v == 1 && l == M, v == 2 && l == L:
+		p = 2
+	case v == 1, v == 3 && l == L:
+		p = 1
+	}
+	return (vtab[v].level[l].check - p) / 2
+}
+
 // Encoding implements a QR data encoding scheme.
-// The implementations--Numeric, Alphanumeric, and String--specify
+// The implementations--Numeric, Alphanumeric, String, Kanji, and Hanzi--specify
 // the character set and the mapping from UTF-8 to code bits.
 // The more restrictive the mode, the fewer code bits are needed.
 type Encoding interface {
@@ -216,6 +236,133 @@ func (s String) Encode(b *Bits, v Version) {
 	}
 }
 
+// Kanji is the encoding for Shift JIS double-byte characters.
+// The string holds the Shift JIS bytes, not UTF-8: each character
+// is a pair of bytes in the range 0x8140-0x9FFC or 0xE040-0xEBBF.
+type Kanji string
+
+func (s Kanji) String() string {
+	return fmt.Sprintf("Kanji(%#q)", string(s))
+}
+
+func (s Kanji) Check() error {
+	if len(s)%2 != 0 {
+		return fmt.Errorf("odd-length Shift JIS string %#q", string(s))
+	}
+	for i := 0; i < len(s); i += 2 {
+		c := uint(s[i])<<8 | uint(s[i+1])
+		if c < 0x8140 || 0x9ffc < c && c < 0xe040 || 0xebbf < c || c&0xff < 0x40 || c&0xff > 0xfc {
+			return fmt.Errorf("non-kanji string %#q", string(s))
+		}
+	}
+	return nil
+}
+
+var kanjiLen = [3]int{8, 10, 12}
+
+func (s Kanji) Bits(v Version) int {
+	return 4 + kanjiLen[v.sizeClass()] + 13*(len(s)/2)
+}
+
+func (s Kanji) Encode(b *Bits, v Version) {
+	b.Write(8, 4)
+	b.Write(uint(len(s)/2), kanjiLen[v.sizeClass()])
+	for i := 0; i+2 <= len(s); i += 2 {
+		c := uint(s[i])<<8 | uint(s[i+1])
+		if c >= 0xe040 {
+			c -= 0xc140
+		} else {
+			c -= 0x8140
+		}
+		b.Write(c>>8*0xc0+c&0xff, 13)
+	}
+}
+
+// Hanzi is the encoding for GB 2312 double-byte characters,
+// defined by the Chinese standard GB/T 18284 rather than ISO/IEC 18004;
+// readers outside China may not support it.  The string holds
+// the GB 2312 bytes, not UTF-8: each character is a pair of bytes,
+// the first in the range 0xA1-0xAA or 0xB0-0xFA and the second
+// in the range 0xA1-0xFE.
+type Hanzi string
+
+func (s Hanzi) String() string {
+	return fmt.Sprintf("Hanzi(%#q)", string(s))
+}
+
+func (s Hanzi) Check() error {
+	if len(s)%2 != 0 {
+		return fmt.Errorf("odd-length GB 2312 string %#q", string(s))
+	}
+	for i := 0; i < len(s); i += 2 {
+		c1, c2 := s[i], s[i+1]
+		if c1 < 0xa1 || 0xaa < c1 && c1 < 0xb0 || 0xfa < c1 || c2 < 0xa1 || 0xfe < c2 {
+			return fmt.Errorf("non-hanzi string %#q", string(s))
+		}
+	}
+	return nil
+}
+
+var hanziLen = kanjiLen
+
+func (s Hanzi) Bits(v Version) int {
+	return 4 + 4 + hanziLen[v.sizeClass()] + 13*(len(s)/2)
+}
+
+func (s Hanzi) Encode(b *Bits, v Version) {
+	b.Write(13, 4)
+	b.Write(1, 4) // subset: GB 2312
+	b.Write(uint(len(s)/2), hanziLen[v.sizeClass()])
+	for i := 0; i+2 <= len(s); i += 2 {
+		c := uint(s[i])<<8 | uint(s[i+1])
+		if c >= 0xb0a1 {
+			c -= 0xa6a1
+		} else {
+			c -= 0xa1a1
+		}
+		b.Write(c>>8*0x60+c&0xff, 13)
+	}
+}
+
+// ECI is an Extended Channel Interpretation designator.
+// It encodes no text; instead it tells the reader how to interpret
+// the bytes of the segments that follow, by assignment number:
+// 3 for ISO-8859-1, 20 for Shift JIS, 26 for UTF-8, and so on.
+type ECI int
+
+func (e ECI) String() string {
+	return fmt.Sprintf("ECI(%d)", int(e))
+}
+
+func (e ECI) Check() error {
+	if e < 0 || e > 999999 {
+		return fmt.Errorf("invalid ECI assignment number %d", int(e))
+	}
+	return nil
+}
+
+func (e ECI) Bits(v Version) int {
+	switch {
+	case e < 1<<7:
+		return 4 + 8
+	case e < 1<<14:
+		return 4 + 16
+	}
+	return 4 + 24
+}
+
+func (e ECI) Encode(b *Bits, v Version) {
+	b.Write(7, 4)
+	switch {
+	case e < 1<<7:
+		b.Write(uint(e), 8)
+	case e < 1<<14:
+		b.Write(2<<14|uint(e), 16)
+	default:
+		b.Write(6<<21|uint(e), 24)
+	}
+}
+
 // A Pixel describes a single pixel in a QR code.
 type Pixel uint32
 
@@ -261,11 +408,13 @@ const (
 	Alignment           // alignment squares (small)
 	Timing              // timing strip between position squares
 	Format              // format metadata
-	PVersion            // version pattern
+	PVersion            // version information (versions 7 and up)
 	Unused              // unused pixel
 	Data                // data bit
 	Check               // error correction check bit
-	Extra
+	Extra               // remainder bit after the last check byte
+	Dark                // the single dark module beside the format pixels
+	Quiet               // quiet zone around the symbol
 )
 
 var roles = []string{
@@ -279,10 +428,12 @@ var roles = []string{
 	"data",
 	"check",
 	"extra",
+	"dark",
+	"quiet",
 }
 
 func (r PixelRole) String() string {
-	if Position <= r && r <= Check {
+	if Position <= r && r <= Quiet {
 		return roles[r]
 	}
 	return strconv.Itoa(int(r))
@@ -324,6 +475,46 @@ func (c *Code) Black(x, y int) bool {
 // in the corners).  Valid masks are integers from 0 to 7.
 type Mask int
 
+// The masks.  In the formulas, i is the row and j the column
+// of a pixel, and the mask inverts the pixel if the formula holds.
+const (
+	Mask0 Mask = iota // (i+j) mod 2 = 0
+	Mask1             // i mod 2 = 0
+	Mask2             // j mod 3 = 0
+	Mask3             // (i+j) mod 3 = 0
+	Mask4             // (i/2 + j/3) mod 2 = 0
+	Mask5             // (i·j) mod 2 + (i·j) mod 3 = 0
+	Mask6             // ((i·j) mod 2 + (i·j) mod 3) mod 2 = 0
+	Mask7             // ((i·j) mod 3 + (i+j) mod 2) mod 2 = 0
+
+	// MaskAuto asks NewPlan for a plan whose mask is chosen
+	// when a code is encoded, by the plan's Selector.
+	MaskAuto Mask = 8
+)
+
+var maskFormula = []string{
+	"(i+j) mod 2 = 0",
+	"i mod 2 = 0",
+	"j mod 3 = 0",
+	"(i+j) mod 3 = 0",
+	"(i/2 + j/3) mod 2 = 0",
+	"(i·j) mod 2 + (i·j) mod 3 = 0",
+	"((i·j) mod 2 + (i·j) mod 3) mod 2 = 0",
+	"((i·j) mod 3 + (i+j) mod 2) mod 2 = 0",
+}
+
+// String returns the mask number and its formula,
+// such as "mask 1: i mod 2 = 0".
+func (m Mask) String() string {
+	if m == MaskAuto {
+		return "auto"
+	}
+	if m < 0 || int(m) >= len(maskFormula) {
+		return fmt.Sprintf("Mask(%d)", int(m))
+	}
+	return fmt.Sprintf("mask %d: %s", int(m), maskFormula[m])
+}
+
 // http://www.swetake.com/qr/qr5_en.html
 var mfunc = []func(int, int) bool{
 	func(i, j int) bool { return (i+j)%2 == 0 },
@@ -336,8 +527,22 @@ var mfunc = []func(int, int) bool{
 	func(i, j int) bool { return (i*j%3+(i+j)%2)%2 == 0 },
 }
 
+// MaskFunc returns the formula for mask m as a function of a pixel's
+// row and column, numbered from 0 at the top left, which the formulas
+// in ISO/IEC 18004 Table 10 call i and j.  The mask inverts the pixel
+// if the function returns true.  MaskFunc returns nil if m is not one
+// of the eight masks.
+func MaskFunc(m Mask) func(row, col int) bool {
+	if m < Mask0 || m > Mask7 {
+		return nil
+	}
+	return mfunc[m]
+}
+
+// Invert reports whether mask m inverts the pixel in row y, column x.
+// It is MaskFunc(m)(y, x), or false if m is not one of the eight masks.
 func (m Mask) Invert(y, x int) bool {
-	if m < 0 {
+	if m < 0 || m > Mask7 {
 		return false
 	}
 	return mfunc[m](y, x)
@@ -354,32 +559,346 @@ type Plan struct {
 	CheckBytes int // number of error correcting (checksum) bytes
 	Blocks     int // number of data blocks
 
+	// Groups describes the blocks: one group if all blocks are the
+	// same size, or two if the later blocks have an extra data byte.
+	// Encode interleaves the blocks in this order.
+	Groups []BlockGroup
+
 	Pixel [][]Pixel // pixel map
+
+	// Pad, if not nil, is the cycle of bytes that Encode uses
+	// to fill unused data capacity after the terminator.
+	// If Pad is nil, Encode uses the standard 0xEC, 0x11.
+	// Decoders ignore the pad bytes, so any values are valid.
+	Pad []byte
+
+	// Remainder holds the values of the remainder bits,
+	// the 0, 3, 4, or 7 pixels left over after the last
+	// check byte, with bit i holding the value of the
+	// Extra pixel with offset 8*(DataBytes+CheckBytes)+i.
+	// The standard says they should be zero, but decoders
+	// ignore them.
+	Remainder uint
+
+	// QuietZone is the width of the margin of Quiet pixels
+	// around the symbol in Pixel, or 0 if there is none.
+	// See WithQuietZone.
+	QuietZone int
+
+	// Custom reports whether the plan was made by PlanBuilder
+	// with a non-standard layout.  The data area of such a plan
+	// depends on the builder's alignment patterns and reserved
+	// regions, so SetLevel cannot change its level.
+	Custom bool
+
+	// Selector, if not nil, chooses the mask for each code
+	// encoded with a MaskAuto plan.  If Selector is nil,
+	// the plan uses EvaluateAll.  It is not marshaled.
+	Selector MaskSelector
 }
 
+// QuietZone is the width of the margin that ISO/IEC 18004
+// requires around a QR code, in modules.
+const QuietZone = 4
+
+// Errors returned by NewPlan and NewTemplate for out-of-range
+// arguments.  The returned errors wrap these and give the value.
+var (
+	ErrInvalidVersion = errors.New("invalid QR version")
+	ErrInvalidLevel   = errors.New("invalid QR level")
+	ErrInvalidMask    = errors.New("invalid QR mask")
+)
+
 // NewPlan returns a Plan for a QR code with the given
 // version, level, and mask.
+//
+// If mask is MaskAuto, the plan's format pixels are left white
+// and its data pixels unmasked, and Encode and EncodeCodewords
+// choose the mask for each code with ChooseMask.
 func NewPlan(version Version, level Level, mask Mask) (*Plan, error) {
-	p, err := vplan(version)
+	if (mask < Mask0 || mask > Mask7) && mask != MaskAuto {
+		return nil, fmt.Errorf("%w %d", ErrInvalidMask, int(mask))
+	}
+	if level < L || level > H {
+		return nil, fmt.Errorf("%w %d", ErrInvalidLevel, int(level))
+	}
+	base, err := basePlan(version, level)
 	if err != nil {
 		return nil, err
 	}
+	p := base.DeepCopy()
+	if mask == MaskAuto {
+		clearFormat(p)
+		p.Mask = MaskAuto
+		return p, nil
+	}
 	if err := fplan(level, mask, p); err != nil {
 		return nil, err
 	}
-	if err := lplan(version, level, p); err != nil {
+	if err := mplan(mask, p); err != nil {
 		return nil, err
 	}
-	if err := mplan(mask, p); err != nil {
+	return p, nil
+}
+
+// clearFormat makes the format pixels of p white.
+func clearFormat(p *Plan) {
+	for _, row := range p.Pixel {
+		for x, pix := range row {
+			if pix.Role() == Format {
+				row[x] = pix &^ (Black | Invert)
+			}
+		}
+	}
+}
+
+// NewPlans returns the eight plans for a QR code with the given
+// version and level, one for each mask: plans[m] has mask m.
+// The unmasked layout is computed once and shared as the source of
+// all eight copies, so only the format pixels and mask differ in the
+// work done for each plan.  The plans do not share storage: each can
+// be edited independently.
+func NewPlans(version Version, level Level) ([]*Plan, error) {
+	if level < L || level > H {
+		return nil, fmt.Errorf("%w %d", ErrInvalidLevel, int(level))
+	}
+	base, err := basePlan(version, level)
+	if err != nil {
+		return nil, err
+	}
+	plans := make([]*Plan, 8)
+	for m := range plans {
+		p := base.DeepCopy()
+		if err := fplan(level, Mask(m), p); err != nil {
+			return nil, err
+		}
+		if err := mplan(Mask(m), p); err != nil {
+			return nil, err
+		}
+		plans[m] = p
+	}
+	return plans, nil
+}
+
+// NewTemplate returns a plan for the given version holding only
+// the fixed patterns: finder, alignment, and timing patterns, the
+// version information, the dark module, and the format pixels,
+// which are reserved but left white.  All other pixels have role 0.
+// The template has no level and no mask (its Mask is -1), so its
+// byte counts are zero; it is for tools that lay out data themselves
+// or study the layout.
+func NewTemplate(version Version) (*Plan, error) {
+	p, err := vplan(version)
+	if err != nil {
+		return nil, err
+	}
+	if err := fplan(L, 0, p); err != nil {
+		return nil, err
+	}
+	clearFormat(p)
+	p.Mask = -1
+	return p, nil
+}
+
+// planCache holds the unmasked plan for each version and level
+// that has been requested.  Laying out the function patterns and
+// data pixels is most of the work of NewPlan, and it does not depend
+// on the mask, so NewPlan does it once and copies the result.
+// The cached plans are never modified.
+var planCache struct {
+	sync.Mutex
+	m map[planKey]*Plan
+}
+
+type planKey struct {
+	v Version
+	l Level
+}
+
+// basePlan returns the shared, unmasked plan for v and l.
+// The caller must not modify it.
+func basePlan(v Version, l Level) (*Plan, error) {
+	planCache.Lock()
+	defer planCache.Unlock()
+	if p := planCache.m[planKey{v, l}]; p != nil {
+		return p, nil
+	}
+	p, err := vplan(v)
+	if err != nil {
 		return nil, err
 	}
+	// The format pixels must be in place before lplan
+	// fills the remaining pixels with data; NewPlan
+	// rewrites them for the actual mask.
+	if err := fplan(l, 0, p); err != nil {
+		return nil, err
+	}
+	if err := lplan(v, l, p); err != nil {
+		return nil, err
+	}
+	if planCache.m == nil {
+		planCache.m = make(map[planKey]*Plan)
+	}
+	planCache.m[planKey{v, l}] = p
 	return p, nil
 }
 
+// DeepCopy returns a copy of p that shares no memory with it,
+// so that changes to one do not affect the other.
+func (p *Plan) DeepCopy() *Plan {
+	q := *p
+	q.Groups = append([]BlockGroup(nil), p.Groups...)
+	if p.Pad != nil {
+		q.Pad = append([]byte(nil), p.Pad...)
+	}
+	if p.Pixel != nil {
+		q.Pixel = grid(len(p.Pixel))
+		for y, row := range p.Pixel {
+			copy(q.Pixel[y], row)
+		}
+	}
+	return &q
+}
+
+// SetMask changes the plan's mask to m, rewriting the format pixels
+// and the mask applied to the data, check, and remainder pixels.
+// It is much cheaper than NewPlan, which makes it practical to try
+// all eight masks on the same plan.
+func (p *Plan) SetMask(m Mask) error {
+	if m < Mask0 || m > Mask7 {
+		return fmt.Errorf("%w %d", ErrInvalidMask, int(m))
+	}
+	if p.Level < L || p.Level > H {
+		return fmt.Errorf("%w %d", ErrInvalidLevel, int(p.Level))
+	}
+	sym := &Plan{Pixel: p.symbol()}
+	for _, row := range sym.Pixel {
+		for x, pix := range row {
+			if r := pix.Role(); (r == Data || r == Check || r == Extra) && pix&Invert != 0 {
+				row[x] ^= Black | Invert
+			}
+		}
+	}
+	if err := fplan(p.Level, m, sym); err != nil {
+		return err
+	}
+	if err := mplan(m, sym); err != nil {
+		return err
+	}
+	p.Mask = m
+	return nil
+}
+
+// SetLevel changes the plan's error correction level to l.
+// The level determines the block structure, so SetLevel copies
+// the data, check, and remainder pixels from the cached layout for
+// the new level and then reapplies the format and mask.  The other
+// pixels are left alone.  The plan must have a valid mask or MaskAuto.
+// SetLevel returns an error for a Custom plan; to change its level,
+// build it again with a different PlanBuilder.Level.
+func (p *Plan) SetLevel(l Level) error {
+	if l < L || l > H {
+		return fmt.Errorf("%w %d", ErrInvalidLevel, int(l))
+	}
+	if p.Custom {
+		return errors.New("cannot change the level of a custom plan")
+	}
+	if (p.Mask < Mask0 || p.Mask > Mask7) && p.Mask != MaskAuto {
+		return fmt.Errorf("%w %d", ErrInvalidMask, int(p.Mask))
+	}
+	base, err := basePlan(p.Version, l)
+	if err != nil {
+		return err
+	}
+	sym := p.symbol()
+	if len(sym) != len(base.Pixel) {
+		return fmt.Errorf("plan has %d rows, want %d for version %v", len(sym), len(base.Pixel), p.Version)
+	}
+	for y, row := range base.Pixel {
+		for x, pix := range row {
+			switch pix.Role() {
+			case Data, Check, Extra:
+				sym[y][x] = pix
+			}
+		}
+	}
+	p.Level = l
+	p.DataBytes = base.DataBytes
+	p.CheckBytes = base.CheckBytes
+	p.Blocks = base.Blocks
+	p.Groups = append([]BlockGroup(nil), base.Groups...)
+
+	if p.Mask == MaskAuto {
+		return nil
+	}
+
+	// The new data pixels are unmasked; SetMask must not undo
+	// a mask that is not there.
+	sym1 := &Plan{Pixel: sym}
+	if err := fplan(l, p.Mask, sym1); err != nil {
+		return err
+	}
+	return mplan(p.Mask, sym1)
+}
+
+// WithQuietZone returns a copy of p whose pixel map includes a margin
+// of n white Quiet pixels on every side, in addition to any margin p
+// already has.  Codes encoded from the copy include the margin, so
+// renderers that draw every pixel produce the quiet zone that readers
+// need; Decode skips the margin.  Use n = QuietZone for the margin
+// the standard requires.
+func (p *Plan) WithQuietZone(n int) *Plan {
+	q := p.DeepCopy()
+	if n <= 0 {
+		return q
+	}
+	siz := len(p.Pixel) + 2*n
+	q.Pixel = grid(siz)
+	for y, row := range q.Pixel {
+		for x := range row {
+			if y < n || y >= siz-n || x < n || x >= siz-n {
+				row[x] = Quiet.Pixel()
+			}
+		}
+	}
+	for y, row := range p.Pixel {
+		copy(q.Pixel[y+n][n:], row)
+	}
+	q.QuietZone += n
+	return q
+}
+
+// symbol returns the pixel map of p without its quiet zone.
+func (p *Plan) symbol() [][]Pixel {
+	n := p.QuietZone
+	if n == 0 {
+		return p.Pixel
+	}
+	m := p.Pixel[n : len(p.Pixel)-n]
+	s := make([][]Pixel, len(m))
+	for y, row := range m {
+		s[y] = row[n : len(row)-n]
+	}
+	return s
+}
+
+var stdPad = []byte{0xec, 0x11}
+
+// Pad appends n bits of padding: a terminator,
+// zero bits to reach a byte boundary, and then
+// the standard pad bytes 0xEC, 0x11, 0xEC, ...
 func (b *Bits) Pad(n int) {
+	b.PadWith(n, stdPad)
+}
+
+// PadWith is like Pad but uses the cycle of pad bytes in pad.
+func (b *Bits) PadWith(n int, pad []byte) {
 	if n < 0 {
 		panic("qr: invalid pad size")
 	}
+	if len(pad) == 0 {
+		panic("qr: empty pad cycle")
+	}
 	if n <= 4 {
 		b.Write(0, n)
 	} else {
@@ -387,19 +906,29 @@ func (b *Bits) Pad(n int) {
 		n -= 4
 		n -= -b.Bits() & 7
 		b.Write(0, -b.Bits()&7)
-		pad := n / 8
-		for i := 0; i < pad; i += 2 {
-			b.Write(0xec, 8)
-			if i+1 >= pad {
-				break
-			}
-			b.Write(0x11, 8)
+		for i := 0; i < n/8; i++ {
+			b.Write(uint(pad[i%len(pad)]), 8)
 		}
 	}
 }
 
 func (b *Bits) AddCheckBytes(v Version, l Level) {
+	vt := &vtab[v]
+	lev := &vt.level[l]
 	nd := v.DataBytes(l)
+	groups := []BlockGroup{{lev.nblock - nd%lev.nblock, nd / lev.nblock, lev.check}}
+	if nd%lev.nblock > 0 {
+		groups = append(groups, BlockGroup{nd % lev.nblock, nd/lev.nblock + 1, lev.check})
+	}
+	b.addCheckBytes(nd, groups)
+	if len(b.Bytes()) != vt.bytes {
+		panic("qr: internal error")
+	}
+}
+
+// addCheckBytes pads b to nd data bytes and appends the check bytes
+// for the blocks described by groups.
+func (b *Bits) addCheckBytes(nd int, groups []BlockGroup) {
 	if b.nbit < nd*8 {
 		b.Pad(nd*8 - b.nbit)
 	}
@@ -408,27 +937,27 @@ func (b *Bits) AddCheckBytes(v Version, l Level) {
 	}
 
 	dat := b.Bytes()
-	vt := &vtab[v]
-	lev := &vt.level[l]
-	db := nd / lev.nblock
-	extra := nd % lev.nblock
-	chk := make([]byte, lev.check)
-	rs := gf256.NewRSEncoder(Field, lev.check)
-	for i := 0; i < lev.nblock; i++ {
-		if i == lev.nblock-extra {
-			db++
+	for _, g := range groups {
+		chk := make([]byte, g.CheckBytes)
+		rs := gf256.NewRSEncoder(Field, g.CheckBytes)
+		for i := 0; i < g.Blocks; i++ {
+			rs.ECC(dat[:g.DataBytes], chk)
+			b.Append(chk)
+			dat = dat[g.DataBytes:]
 		}
-		rs.ECC(dat[:db], chk)
-		b.Append(chk)
-		dat = dat[db:]
 	}
+}
 
-	if len(b.Bytes()) != vt.bytes {
-		panic("qr: internal error")
+func (p *Plan) Encode(text ...Encoding) (*Code, error) {
+	bytes, err := p.codewords(text)
+	if err != nil {
+		return nil, err
 	}
+	return p.placeMasked(bytes)
 }
 
-func (p *Plan) Encode(text ...Encoding) (*Code, error) {
+// codewords returns the data and check codewords for text.
+func (p *Plan) codewords(text []Encoding) ([]byte, error) {
 	var b Bits
 	for _, t := range text {
 		if err := t.Check(); err != nil {
@@ -439,11 +968,120 @@ func (p *Plan) Encode(text ...Encoding) (*Code, error) {
 	if b.Bits() > p.DataBytes*8 {
 		return nil, fmt.Errorf("cannot encode %d bits into %d-bit code", b.Bits(), p.DataBytes*8)
 	}
-	b.AddCheckBytes(p.Version, p.Level)
-	bytes := b.Bytes()
+	if p.Pad != nil && b.Bits() < p.DataBytes*8 {
+		b.PadWith(p.DataBytes*8-b.Bits(), p.Pad)
+	}
+	b.addCheckBytes(p.DataBytes, p.Groups)
+	return b.Bytes(), nil
+}
+
+// EncodeCodewords is like Encode but takes the data codewords
+// already assembled: mode indicators, data, terminator, and padding.
+// It adds the check codewords, interleaves, places, and masks them.
+// There must be exactly p.DataBytes codewords.
+func (p *Plan) EncodeCodewords(data []byte) (*Code, error) {
+	if len(data) != p.DataBytes {
+		return nil, fmt.Errorf("have %d data codewords, want %d", len(data), p.DataBytes)
+	}
+	var b Bits
+	b.Append(data)
+	b.addCheckBytes(p.DataBytes, p.Groups)
+	return p.placeMasked(b.Bytes())
+}
 
-	// Now we have the checksum bytes and the data bytes.
-	// Construct the actual code.
+// ChooseMask returns the mask that p.Selector, or EvaluateAll if
+// Selector is nil, chooses for the code holding text.  The plan's
+// own mask does not matter.
+func (p *Plan) ChooseMask(text ...Encoding) (Mask, error) {
+	bytes, err := p.codewords(text)
+	if err != nil {
+		return 0, err
+	}
+	return p.selectMask(bytes)
+}
+
+// selectMask returns the mask chosen by p's selector
+// for the code holding the data and check codewords.
+func (p *Plan) selectMask(bytes []byte) (Mask, error) {
+	sel := p.Selector
+	if sel == nil {
+		sel = EvaluateAll
+	}
+	m := sel.SelectMask(p.candidates(bytes))
+	if m < Mask0 || m > Mask7 {
+		return 0, fmt.Errorf("mask selector chose %w %d", ErrInvalidMask, int(m))
+	}
+	return m, nil
+}
+
+// candidates returns a function that returns the code holding the
+// data and check codewords with mask m, without quiet zone.
+// The function is safe to call from multiple goroutines.
+func (p *Plan) candidates(bytes []byte) func(Mask) *Code {
+	// Lay out the unmasked code once; each mask then
+	// flips data pixels and sets the format pixels.
+	q := p.DeepCopy()
+	q.Pixel, q.QuietZone = q.symbol(), 0
+	for _, row := range q.Pixel {
+		for x, pix := range row {
+			if r := pix.Role(); (r == Data || r == Check || r == Extra) && pix&Invert != 0 {
+				row[x] ^= Black | Invert
+			}
+		}
+	}
+	clearFormat(q)
+	base := q.place(bytes)
+	first, second := FormatCoords(len(q.Pixel))
+
+	return func(m Mask) *Code {
+		if m < Mask0 || m > Mask7 {
+			return nil
+		}
+		c := &Code{Size: base.Size, Stride: base.Stride}
+		c.Bitmap = append([]byte(nil), base.Bitmap...)
+		flip := func(x, y int) {
+			c.Bitmap[y*c.Stride+x/8] ^= 1 << uint(7-x&7)
+		}
+		tab := &maskTab[m]
+		for y, row := range q.Pixel {
+			trow := &tab[y%maskPeriod]
+			for x, pix := range row {
+				if r := pix.Role(); (r == Data || r == Check || r == Extra) && trow[x%maskPeriod] {
+					flip(x, y)
+				}
+			}
+		}
+		fb := formatBits(q.Level, m) ^ 0x5412
+		for i := uint(0); i < 15; i++ {
+			if fb>>i&1 == 1 {
+				flip(first[i].X, first[i].Y)
+				flip(second[i].X, second[i].Y)
+			}
+		}
+		return c
+	}
+}
+
+// placeMasked is like place but first chooses
+// the mask if the plan's mask is MaskAuto.
+func (p *Plan) placeMasked(bytes []byte) (*Code, error) {
+	if p.Mask != MaskAuto {
+		return p.place(bytes), nil
+	}
+	m, err := p.selectMask(bytes)
+	if err != nil {
+		return nil, err
+	}
+	q := p.DeepCopy()
+	if err := q.SetMask(m); err != nil {
+		return nil, err
+	}
+	return q.place(bytes), nil
+}
+
+// place returns the code with the data and check bytes
+// laid out according to the plan.
+func (p *Plan) place(bytes []byte) *Code {
 	c := &Code{Size: len(p.Pixel), Stride: (len(p.Pixel) + 7) &^ 7}
 	c.Bitmap = make([]byte, c.Stride*c.Size)
 	crow := c.Bitmap
@@ -455,6 +1093,11 @@ func (p *Plan) Encode(text ...Encoding) (*Code, error) {
 				if bytes[o/8]&(1<<uint(7-o&7)) != 0 {
 					pix ^= Black
 				}
+			case Extra:
+				o := pix.Offset() - uint(len(bytes)*8)
+				if p.Remainder&(1<<o) != 0 {
+					pix ^= Black
+				}
 			}
 			if pix&Black != 0 {
 				crow[x/8] |= 1 << uint(7-x&7)
@@ -462,7 +1105,15 @@ func (p *Plan) Encode(text ...Encoding) (*Code, error) {
 		}
 		crow = crow[c.Stride:]
 	}
-	return c, nil
+	return c
+}
+
+// A BlockGroup describes a run of error correction blocks
+// of the same size.
+type BlockGroup struct {
+	Blocks     int // number of blocks in the group
+	DataBytes  int // data bytes per block
+	CheckBytes int // check bytes per block
 }
 
 // A version describes metadata associated with a version.
@@ -534,10 +1185,47 @@ func grid(siz int) [][]Pixel {
 
 // vplan creates a Plan for the given version.
 func vplan(v Version) (*Plan, error) {
-	p := &Plan{Version: v}
 	if v < 1 || v > 40 {
-		return nil, fmt.Errorf("invalid QR version %d", int(v))
+		return nil, fmt.Errorf("%w %d", ErrInvalidVersion, int(v))
 	}
+	return vplanAlign(v, AlignmentCenters(v)), nil
+}
+
+// AlignmentCenters returns the centers of the alignment patterns
+// in a symbol of version v, in row-major order.
+func AlignmentCenters(v Version) []image.Point {
+	if v < MinVersion || v > MaxVersion {
+		return nil
+	}
+	siz := 17 + int(v)*4
+	info := &vtab[v]
+	var list []image.Point
+	for y := 4; y+5 < siz; {
+		for x := 4; x+5 < siz; {
+			// don't overwrite position boxes
+			if (x < 7 && y < 7) || (x < 7 && y+5 >= siz-7) || (x+5 >= siz-7 && y < 7) {
+			} else {
+				list = append(list, image.Pt(x+2, y+2))
+			}
+			if x == 4 {
+				x = info.apos
+			} else {
+				x += info.astride
+			}
+		}
+		if y == 4 {
+			y = info.apos
+		} else {
+			y += info.astride
+		}
+	}
+	return list
+}
+
+// vplanAlign creates a Plan for the given version
+// with alignment patterns centered at the given points.
+func vplanAlign(v Version, align []image.Point) *Plan {
+	p := &Plan{Version: v}
 	siz := 17 + int(v)*4
 	m := grid(siz)
 	p.Pixel = m
@@ -559,25 +1247,8 @@ func vplan(v Version) (*Plan, error) {
 	posBox(m, 0, siz-7)
 
 	// Alignment boxes.
-	info := &vtab[v]
-	for x := 4; x+5 < siz; {
-		for y := 4; y+5 < siz; {
-			// don't overwrite timing markers
-			if (x < 7 && y < 7) || (x < 7 && y+5 >= siz-7) || (x+5 >= siz-7 && y < 7) {
-			} else {
-				alignBox(m, x, y)
-			}
-			if y == 4 {
-				y = info.apos
-			} else {
-				y += info.astride
-			}
-		}
-		if x == 4 {
-			x = info.apos
-		} else {
-			x += info.astride
-		}
+	for _, c := range align {
+		alignBox(m, c.X-2, c.Y-2)
 	}
 
 	// Version pattern.
@@ -598,26 +1269,17 @@ func vplan(v Version) (*Plan, error) {
 	}
 
 	// One lonely black pixel
-	m[siz-8][8] = Unused.Pixel() | Black
+	m[siz-8][8] = Dark.Pixel() | Black
 
-	return p, nil
+	return p
 }
 
 // fplan adds the format pixels
 func fplan(l Level, m Mask, p *Plan) error {
 	// Format pixels.
-	fb := uint32(l^1) << 13 // level: L=01, M=00, Q=11, H=10
-	fb |= uint32(m) << 10   // mask
-	const formatPoly = 0x537
-	rem := fb
-	for i := 14; i >= 10; i-- {
-		if rem&(1<<uint(i)) != 0 {
-			rem ^= formatPoly << uint(i-10)
-		}
-	}
-	fb |= rem
+	fb := formatBits(l, m)
 	invert := uint32(0x5412)
-	siz := len(p.Pixel)
+	first, second := FormatCoords(len(p.Pixel))
 	for i := uint(0); i < 15; i++ {
 		pix := Format.Pixel() + OffsetPixel(i)
 		if (fb>>i)&1 == 1 {
@@ -626,136 +1288,242 @@ func fplan(l Level, m Mask, p *Plan) error {
 		if (invert>>i)&1 == 1 {
 			pix ^= Invert | Black
 		}
-		// top left
+		p.Pixel[first[i].Y][first[i].X] = pix
+		p.Pixel[second[i].Y][second[i].X] = pix
+	}
+	return nil
+}
+
+// FormatCoords returns the coordinates of the two copies of the
+// format information in a symbol of the given size, without quiet
+// zone.  Element i of each array holds bit i of the 15-bit format
+// sequence, counting from the least significant.  The first copy
+// surrounds the top left finder pattern; the second is split between
+// the top right and bottom left finder patterns.
+func FormatCoords(size int) (first, second [15]image.Point) {
+	for i := 0; i < 15; i++ {
 		switch {
 		case i < 6:
-			p.Pixel[i][8] = pix
+			first[i] = image.Pt(8, i)
 		case i < 8:
-			p.Pixel[i+1][8] = pix
+			first[i] = image.Pt(8, i+1) // skip horizontal timing strip
 		case i < 9:
-			p.Pixel[8][7] = pix
+			first[i] = image.Pt(7, 8)
 		default:
-			p.Pixel[8][14-i] = pix
+			first[i] = image.Pt(14-i, 8)
 		}
-		// bottom right
-		switch {
-		case i < 8:
-			p.Pixel[8][siz-1-int(i)] = pix
-		default:
-			p.Pixel[siz-1-int(14-i)][8] = pix
+		if i < 8 {
+			second[i] = image.Pt(size-1-i, 8)
+		} else {
+			second[i] = image.Pt(8, size-15+i)
 		}
 	}
-	return nil
+	return
+}
+
+// zigzag calls f for each pixel of a symbol of the given size
+// in the order that data is placed: sweep up a pair of columns,
+// then down the next pair, visiting the right then the left pixel
+// of each row and skipping the vertical timing strip.
+// See Figure 2 of http://www.pclviewer.com/rs2/qrtopology.htm
+func zigzag(siz int, f func(x, y int)) {
+	for x := siz; x > 0; {
+		for y := siz - 1; y >= 0; y-- {
+			f(x-1, y)
+			f(x-2, y)
+		}
+		x -= 2
+		if x == 7 { // vertical timing strip
+			x--
+		}
+		for y := 0; y < siz; y++ {
+			f(x-1, y)
+			f(x-2, y)
+		}
+		x -= 2
+	}
+}
+
+// A DataModule gives the location of one data, check, or remainder
+// bit in a plan's pixel map.  Bit is the bit's offset in the codeword
+// sequence, as returned by Pixel.Offset: bit 8*k+i is bit i, counting
+// from the most significant, of codeword k, where the data codewords
+// come first, then the check codewords, then the remainder bits.
+type DataModule struct {
+	X, Y int
+	Bit  int
+}
+
+// DataModules returns the plan's data, check, and remainder pixels
+// in the order the standard places them in the symbol.
+// Coordinates include any quiet zone.
+func (p *Plan) DataModules() []DataModule {
+	m := p.symbol()
+	n := p.QuietZone
+	var list []DataModule
+	zigzag(len(m), func(x, y int) {
+		switch pix := m[y][x]; pix.Role() {
+		case Data, Check, Extra:
+			list = append(list, DataModule{x + n, y + n, int(pix.Offset())})
+		}
+	})
+	return list
+}
+
+// A DataRegion summarizes the pixels of a plan that hold data,
+// check, and remainder bits: the pixels whose colors an encoder,
+// rather than the standard, controls.
+type DataRegion struct {
+	Modules   int   // number of data, check, and remainder pixels
+	Remainder int   // number of remainder pixels
+	Columns   []int // Columns[x] is the number of those pixels in column x
+	Rows      []int // Rows[y] is the number of those pixels in row y
+}
+
+// DataRegion returns the geometry of the plan's data region.
+// Columns and rows are indexed as in Pixel, including any quiet zone.
+func (p *Plan) DataRegion() DataRegion {
+	d := DataRegion{
+		Columns: make([]int, len(p.Pixel)),
+		Rows:    make([]int, len(p.Pixel)),
+	}
+	for y, row := range p.Pixel {
+		for x, pix := range row {
+			switch pix.Role() {
+			case Extra:
+				d.Remainder++
+				fallthrough
+			case Data, Check:
+				d.Modules++
+				d.Columns[x]++
+				d.Rows[y]++
+			}
+		}
+	}
+	return d
 }
 
 // lplan edits a version-only Plan to add information
 // about the error correction levels.
 func lplan(v Version, l Level, p *Plan) error {
+	lev := &vtab[v].level[l]
+	return layout(p, l, vtab[v].bytes, lev.nblock, lev.check)
+}
+
+// layout fills the unassigned pixels of p with total codewords
+// divided into nblock blocks of ne check bytes each, followed by
+// remainder bits.
+func layout(p *Plan, l Level, total, nblock, ne int) error {
 	p.Level = l
 
-	nblock := vtab[v].level[l].nblock
-	ne := vtab[v].level[l].check
-	nde := (vtab[v].bytes - ne*nblock) / nblock
-	extra := (vtab[v].bytes - ne*nblock) % nblock
+	nde := (total - ne*nblock) / nblock
+	extra := (total - ne*nblock) % nblock
+	if nde < 1 {
+		return fmt.Errorf("no room for data in %d codewords with %d blocks of %d check bytes", total, nblock, ne)
+	}
 	dataBits := (nde*nblock + extra) * 8
 	checkBits := ne * nblock * 8
 
-	p.DataBytes = vtab[v].bytes - ne*nblock
+	p.DataBytes = total - ne*nblock
 	p.CheckBytes = ne * nblock
 	p.Blocks = nblock
+	p.Groups = []BlockGroup{{nblock - extra, nde, ne}}
+	if extra > 0 {
+		p.Groups = append(p.Groups, BlockGroup{extra, nde + 1, ne})
+	}
 
-	// Make data + checksum pixels.
-	data := make([]Pixel, dataBits)
-	for i := range data {
-		data[i] = Data.Pixel() | OffsetPixel(uint(i))
-	}
-	check := make([]Pixel, checkBits)
-	for i := range check {
-		check[i] = Check.Pixel() | OffsetPixel(uint(i+dataBits))
-	}
-
-	// Split into blocks.
-	dataList := make([][]Pixel, nblock)
-	checkList := make([][]Pixel, nblock)
-	for i := 0; i < nblock; i++ {
-		// The last few blocks have an extra data byte (8 pixels).
-		nd := nde
-		if i >= nblock-extra {
-			nd++
-		}
-		dataList[i], data = data[0:nd*8], data[nd*8:]
-		checkList[i], check = check[0:ne*8], check[ne*8:]
-	}
-	if len(data) != 0 || len(check) != 0 {
-		panic("data/check math")
-	}
-
-	// Build up bit sequence, taking first byte of each block,
-	// then second byte, and so on.  Then checksums.
-	bits := make([]Pixel, dataBits+checkBits)
-	dst := bits
-	for i := 0; i < nde+1; i++ {
-		for _, b := range dataList {
-			if i*8 < len(b) {
-				copy(dst, b[i*8:(i+1)*8])
-				dst = dst[8:]
-			}
+	// Make data + checksum pixels, in the order
+	// they appear in the symbol.
+	bits := make([]Pixel, 0, dataBits+checkBits)
+	for _, cw := range p.Interleave() {
+		role := Data
+		if cw >= p.DataBytes {
+			role = Check
 		}
-	}
-	for i := 0; i < ne; i++ {
-		for _, b := range checkList {
-			if i*8 < len(b) {
-				copy(dst, b[i*8:(i+1)*8])
-				dst = dst[8:]
-			}
+		for i := 0; i < 8; i++ {
+			bits = append(bits, role.Pixel()|OffsetPixel(uint(8*cw+i)))
 		}
 	}
-	if len(dst) != 0 {
-		panic("dst math")
-	}
 
-	// Sweep up pair of columns,
-	// then down, assigning to right then left pixel.
-	// Repeat.
-	// See Figure 2 of http://www.pclviewer.com/rs2/qrtopology.htm
 	siz := len(p.Pixel)
 	rem := make([]Pixel, 7)
 	for i := range rem {
-		rem[i] = Extra.Pixel()
+		rem[i] = Extra.Pixel() | OffsetPixel(uint(dataBits+checkBits+i))
 	}
 	src := append(bits, rem...)
-	for x := siz; x > 0; {
-		for y := siz - 1; y >= 0; y-- {
-			if p.Pixel[y][x-1].Role() == 0 {
-				p.Pixel[y][x-1], src = src[0], src[1:]
-			}
-			if p.Pixel[y][x-2].Role() == 0 {
-				p.Pixel[y][x-2], src = src[0], src[1:]
-			}
+	zigzag(siz, func(x, y int) {
+		if p.Pixel[y][x].Role() == 0 {
+			p.Pixel[y][x], src = src[0], src[1:]
 		}
-		x -= 2
-		if x == 7 { // vertical timing strip
-			x--
+	})
+	return nil
+}
+
+// Interleave returns the order in which the codewords appear in
+// the symbol.  Encode computes the data codewords block by block,
+// followed by the check codewords block by block; the symbol holds
+// codeword order[0] first, then order[1], and so on.  The order takes
+// the first data codeword of each block, then the second, and so on,
+// and then the check codewords in the same way.  A plan with no
+// blocks, such as a template from NewTemplate, has no codewords,
+// and Interleave returns nil.
+func (p *Plan) Interleave() []int {
+	if len(p.Groups) == 0 || p.Blocks == 0 {
+		return nil
+	}
+	var start, size []int // data codewords in each block
+	n := 0
+	for _, g := range p.Groups {
+		for i := 0; i < g.Blocks; i++ {
+			start = append(start, n)
+			size = append(size, g.DataBytes)
+			n += g.DataBytes
 		}
-		for y := 0; y < siz; y++ {
-			if p.Pixel[y][x-1].Role() == 0 {
-				p.Pixel[y][x-1], src = src[0], src[1:]
+	}
+	order := make([]int, 0, p.DataBytes+p.CheckBytes)
+	for i := 0; i < size[len(size)-1]; i++ {
+		for b := range start {
+			if i < size[b] {
+				order = append(order, start[b]+i)
 			}
-			if p.Pixel[y][x-2].Role() == 0 {
-				p.Pixel[y][x-2], src = src[0], src[1:]
+		}
+	}
+	nc := p.CheckBytes / p.Blocks
+	for i := 0; i < nc; i++ {
+		for b := range start {
+			order = append(order, p.DataBytes+b*nc+i)
+		}
+	}
+	return order
+}
+
+// maskPeriod is a period, in both rows and columns, of every mask.
+const maskPeriod = 12
+
+// maskTab[m][i][j] caches mfunc[m](i, j) for one period of each mask.
+var maskTab [8][maskPeriod][maskPeriod]bool
+
+func init() {
+	for m, f := range mfunc {
+		for i := 0; i < maskPeriod; i++ {
+			for j := 0; j < maskPeriod; j++ {
+				maskTab[m][i][j] = f(i, j)
 			}
 		}
-		x -= 2
 	}
-	return nil
 }
 
 // mplan edits a version+level-only Plan to add the mask.
 func mplan(m Mask, p *Plan) error {
 	p.Mask = m
+	if m < 0 {
+		return nil
+	}
+	tab := &maskTab[m]
 	for y, row := range p.Pixel {
+		trow := &tab[y%maskPeriod]
 		for x, pix := range row {
-			if r := pix.Role(); (r == Data || r == Check || r == Extra) && p.Mask.Invert(y, x) {
+			if r := pix.Role(); (r == Data || r == Check || r == Extra) && trow[x%maskPeriod] {
 				row[x] ^= Black | Invert
 			}
 		}
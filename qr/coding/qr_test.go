@@ -79,6 +79,9 @@ Pixel:
 				want |= pix & Invert
 			default:
 				want = Unused.Pixel()
+				if pix.Role() == Dark {
+					want = Dark.Pixel()
+				}
 			}
 			if keypix&libqrencode.Black != 0 {
 				want |= Black
@@ -0,0 +1,190 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coding
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// A MaskSelector chooses the mask for a code encoded
+// with a MaskAuto plan.
+type MaskSelector interface {
+	// SelectMask returns the mask to use.  Calling code(m)
+	// returns the code, without quiet zone, that mask m would
+	// produce, or nil if m is not a valid mask.  Each call costs
+	// about as much as encoding a code.  It is safe to call code
+	// from multiple goroutines.
+	SelectMask(code func(Mask) *Code) Mask
+}
+
+// A MaskSelectorFunc is an adapter that allows the use
+// of an ordinary function as a MaskSelector, such as one
+// scoring the candidate codes by a custom objective.
+type MaskSelectorFunc func(code func(Mask) *Code) Mask
+
+// SelectMask returns f(code).
+func (f MaskSelectorFunc) SelectMask(code func(Mask) *Code) Mask {
+	return f(code)
+}
+
+// EvaluateAll is the standard selector.  It scores the code for
+// each of the eight masks using Code.Penalty and picks the mask
+// with the lowest score, preferring lower-numbered masks in a tie.
+var EvaluateAll MaskSelector = evaluateAll{}
+
+type evaluateAll struct{}
+
+func (evaluateAll) SelectMask(code func(Mask) *Code) Mask {
+	best, bestScore := Mask0, -1
+	for m := Mask0; m <= Mask7; m++ {
+		if s := code(m).Penalty(); bestScore < 0 || s < bestScore {
+			best, bestScore = m, s
+		}
+	}
+	return best
+}
+
+// PickFirst returns a selector that scores the masks in order and
+// stops at the first whose penalty is at most limit, falling back to
+// the lowest-scoring mask if none is that good.  It trades the best
+// score for speed: with a generous limit it usually evaluates only
+// one or two masks.
+func PickFirst(limit int) MaskSelector {
+	return pickFirst(limit)
+}
+
+type pickFirst int
+
+func (limit pickFirst) SelectMask(code func(Mask) *Code) Mask {
+	best, bestScore := Mask0, -1
+	for m := Mask0; m <= Mask7; m++ {
+		s := code(m).Penalty()
+		if s <= int(limit) {
+			return m
+		}
+		if bestScore < 0 || s < bestScore {
+			best, bestScore = m, s
+		}
+	}
+	return best
+}
+
+// Fixed returns a selector that always picks m
+// without evaluating any codes.
+func Fixed(m Mask) MaskSelector {
+	return fixed(m)
+}
+
+type fixed Mask
+
+func (m fixed) SelectMask(func(Mask) *Code) Mask {
+	return Mask(m)
+}
+
+// SetCustomMask masks the plan's data and check pixels with f instead
+// of a standard mask: the pixel in column x and row y is inverted if
+// f(x, y) is true.  Decoders only know the eight standard masks, so
+// SetCustomMask writes into the format pixels the standard mask m
+// that agrees with f on the most data and check pixels, and it uses m
+// for the remainder pixels.  Each pixel where f and m disagree reads
+// as a bit error, which the error correction must absorb.
+// SetCustomMask returns m and the number of codewords with at least
+// one such error; the codes remain readable only while each block has
+// no more damaged codewords than Version.Correctable allows.
+// Custom masks are for generative art that can accept the lost margin.
+func (p *Plan) SetCustomMask(f func(x, y int) bool) (m Mask, damaged int, err error) {
+	if p.Level < L || p.Level > H {
+		return 0, 0, fmt.Errorf("%w %d", ErrInvalidLevel, int(p.Level))
+	}
+	sym := p.symbol()
+
+	// Count agreement with each standard mask.
+	var agree [8]int
+	for y, row := range sym {
+		for x, pix := range row {
+			if r := pix.Role(); r != Data && r != Check {
+				continue
+			}
+			want := f(x, y)
+			for m := range agree {
+				if maskTab[m][y%maskPeriod][x%maskPeriod] == want {
+					agree[m]++
+				}
+			}
+		}
+	}
+	for m1 := Mask1; m1 <= Mask7; m1++ {
+		if agree[m1] > agree[m] {
+			m = m1
+		}
+	}
+
+	// Unmask, then apply f to data and check pixels and m to the rest.
+	q := &Plan{Level: p.Level, Pixel: sym}
+	if err := q.SetMask(m); err != nil {
+		return 0, 0, err
+	}
+	bad := make(map[uint]bool)
+	for y, row := range sym {
+		for x, pix := range row {
+			if r := pix.Role(); r != Data && r != Check {
+				continue
+			}
+			if (pix&Invert != 0) != f(x, y) {
+				row[x] ^= Black | Invert
+				bad[pix.Offset()/8] = true
+			}
+		}
+	}
+	p.Mask = m
+	return m, len(bad), nil
+}
+
+// EvaluateParallel returns a selector that scores the masks like
+// EvaluateAll, and chooses the same mask, but scores them in up to
+// workers goroutines at once.  If workers is less than 1, it uses
+// runtime.GOMAXPROCS(0).  Scoring the eight codes dominates the cost
+// of encoding a large code, so with several cores this can cut the
+// time to encode one; compare BenchmarkEvaluateAll and
+// BenchmarkEvaluateParallel.  With one core it is a little slower.
+func EvaluateParallel(workers int) MaskSelector {
+	return evaluateParallel(workers)
+}
+
+type evaluateParallel int
+
+func (workers evaluateParallel) SelectMask(code func(Mask) *Code) Mask {
+	n := int(workers)
+	if n < 1 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	var scores [8]int
+	masks := make(chan Mask, len(scores))
+	for m := Mask0; m <= Mask7; m++ {
+		masks <- m
+	}
+	close(masks)
+	var wg sync.WaitGroup
+	for i := 0; i < n && i < len(scores); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for m := range masks {
+				scores[m] = code(m).Penalty()
+			}
+		}()
+	}
+	wg.Wait()
+
+	best := Mask0
+	for m := Mask1; m <= Mask7; m++ {
+		if scores[m] < scores[best] {
+			best = m
+		}
+	}
+	return best
+}
@@ -0,0 +1,238 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coding
+
+import (
+	"fmt"
+	"image"
+	"strings"
+)
+
+// Validate checks the plan's function patterns against ISO/IEC 18004:
+// the size of the grid, the three finder patterns, the timing strips,
+// the number of alignment patterns, the two copies of the 15 format
+// pixels, the dark module, and, for version 7 and up, the two copies
+// of the 18 version pixels.  If the plan has a quiet zone, Validate
+// checks that it is all white Quiet pixels.  It returns a description
+// of each violation, or nil if there are none.  Plans made by NewPlan
+// always pass; Validate is for plans that have been edited, decoded,
+// or built by hand.
+func (p *Plan) Validate() []string {
+	var bad []string
+	report := func(format string, args ...interface{}) {
+		bad = append(bad, fmt.Sprintf(format, args...))
+	}
+
+	if n := p.QuietZone; n != 0 {
+		siz := 17 + 4*int(p.Version) + 2*n
+		if n < 0 || len(p.Pixel) != siz {
+			report("grid has %d rows, want %d with quiet zone %d", len(p.Pixel), siz, n)
+			return bad
+		}
+	Quiet:
+		for y, row := range p.Pixel {
+			if len(row) != siz {
+				report("row %d has %d pixels, want %d", y, len(row), siz)
+				return bad
+			}
+			for x, pix := range row {
+				inside := n <= y && y < siz-n && n <= x && x < siz-n
+				if !inside && pix != Quiet.Pixel() {
+					report("quiet zone is damaged at %d,%d", x, y)
+					break Quiet
+				}
+			}
+		}
+		q := *p
+		q.Pixel = p.symbol()
+		q.QuietZone = 0
+		return append(bad, q.Validate()...)
+	}
+
+	siz := 17 + 4*int(p.Version)
+	if p.Version < MinVersion || p.Version > MaxVersion {
+		report("invalid version %d", int(p.Version))
+		return bad
+	}
+	if len(p.Pixel) != siz {
+		report("grid has %d rows, want %d", len(p.Pixel), siz)
+		return bad
+	}
+	for y, row := range p.Pixel {
+		if len(row) != siz {
+			report("row %d has %d pixels, want %d", y, len(row), siz)
+			return bad
+		}
+	}
+
+	// Finder patterns.
+	for _, c := range [][2]int{{0, 0}, {siz - 7, 0}, {0, siz - 7}} {
+		x, y := c[0], c[1]
+	Finder:
+		for dy := 0; dy < 7; dy++ {
+			for dx := 0; dx < 7; dx++ {
+				pix := p.Pixel[y+dy][x+dx]
+				black := dx == 0 || dx == 6 || dy == 0 || dy == 6 || 2 <= dx && dx <= 4 && 2 <= dy && dy <= 4
+				if pix.Role() != Position || (pix&Black != 0) != black {
+					report("finder pattern at %d,%d is damaged at %d,%d", x, y, x+dx, y+dy)
+					break Finder
+				}
+			}
+		}
+	}
+
+	// Timing strips.
+	for i := 8; i < siz-8; i++ {
+		for _, pix := range []Pixel{p.Pixel[6][i], p.Pixel[i][6]} {
+			if pix.Role() == Alignment {
+				continue // alignment patterns cover the strips
+			}
+			if pix.Role() != Timing || (pix&Black != 0) != (i%2 == 0) {
+				report("timing strip is damaged at position %d", i)
+				break
+			}
+		}
+	}
+
+	// Counts of other function pixels.
+	var count [Dark + 1]int
+	for _, row := range p.Pixel {
+		for _, pix := range row {
+			if r := pix.Role(); r <= Dark {
+				count[r]++
+			}
+		}
+	}
+	na := 0
+	if p.Version > 1 {
+		n := int(p.Version)/7 + 2 // alignment coordinates
+		na = n*n - 3
+	}
+	if count[Alignment] != 25*na {
+		report("have %d alignment pixels, want %d for %d patterns", count[Alignment], 25*na, na)
+	}
+	if count[Format] != 2*15 {
+		report("have %d format pixels, want %d", count[Format], 2*15)
+	}
+	nv := 0
+	if p.Version >= 7 {
+		nv = 2 * 18
+	}
+	if count[PVersion] != nv {
+		report("have %d version pixels, want %d", count[PVersion], nv)
+	}
+	if pix := p.Pixel[siz-8][8]; pix.Role() != Dark || pix&Black == 0 || count[Dark] != 1 {
+		report("dark module at 8,%d is missing or misplaced", siz-8)
+	}
+	return bad
+}
+
+// CheckFormat reads the two copies of the format information from
+// the plan's pixels, at the coordinates given by FormatCoords, and
+// checks that each holds the masked 15-bit sequence for the plan's
+// level and mask.  Readers reject codes with a damaged format area,
+// so tools that edit plans can use CheckFormat to catch mistakes.
+func (p *Plan) CheckFormat() error {
+	if p.Level < L || p.Level > H || p.Mask < Mask0 || p.Mask > Mask7 {
+		return fmt.Errorf("plan has no format information for level %v, mask %d", p.Level, int(p.Mask))
+	}
+	m := p.symbol()
+	n := p.QuietZone
+	want := formatBits(p.Level, p.Mask) ^ 0x5412
+	first, second := FormatCoords(len(m))
+	for c, pts := range [][15]image.Point{first, second} {
+		var got uint32
+		for i, pt := range pts {
+			pix := m[pt.Y][pt.X]
+			if pix.Role() != Format || pix.Offset() != uint(i) {
+				return fmt.Errorf("format copy %d: pixel %d,%d is %v, want format bit %d", c+1, pt.X+n, pt.Y+n, pix, i)
+			}
+			if pix&Black != 0 {
+				got |= 1 << uint(i)
+			}
+		}
+		if got != want {
+			return fmt.Errorf("format copy %d is %#04x, want %#04x for level %v, mask %d", c+1, got, want, p.Level, int(p.Mask))
+		}
+	}
+	return nil
+}
+
+// NewPlanStrict is like NewPlan but also checks the plan it returns:
+// the plan must pass Validate, and its data, check, and remainder
+// pixels must match the counts derived independently from the
+// formulas in ISO/IEC 18004 rather than from the tables NewPlan uses.
+// It is slower than NewPlan and meant for tests and for callers that
+// edit the tables.
+func NewPlanStrict(version Version, level Level, mask Mask) (*Plan, error) {
+	p, err := NewPlan(version, level, mask)
+	if err != nil {
+		return nil, err
+	}
+	bad := p.Validate()
+	report := func(format string, args ...interface{}) {
+		bad = append(bad, fmt.Sprintf(format, args...))
+	}
+
+	var count [Dark + 1]int
+	seen := make(map[uint]bool)
+	for _, row := range p.Pixel {
+		for _, pix := range row {
+			r := pix.Role()
+			if r > Dark {
+				report("pixel has invalid role %d", int(r))
+				continue
+			}
+			count[r]++
+			if r == Data || r == Check || r == Extra {
+				if seen[pix.Offset()] {
+					report("bit offset %d is placed twice", pix.Offset())
+				}
+				seen[pix.Offset()] = true
+			}
+		}
+	}
+	raw := rawDataModules(version)
+	if n := count[Data] + count[Check] + count[Extra]; n != raw {
+		report("have %d data modules, want %d", n, raw)
+	}
+	if n := p.DataBytes + p.CheckBytes; n != raw/8 {
+		report("have %d codewords, want %d", n, raw/8)
+	}
+	if count[Data] != 8*p.DataBytes {
+		report("have %d data pixels for %d data bytes", count[Data], p.DataBytes)
+	}
+	if count[Check] != 8*p.CheckBytes {
+		report("have %d check pixels for %d check bytes", count[Check], p.CheckBytes)
+	}
+	if count[Extra] != raw%8 {
+		report("have %d remainder pixels, want %d", count[Extra], raw%8)
+	}
+	if count[0] != 0 {
+		report("%d pixels have no role", count[0])
+	}
+	if bad != nil {
+		return nil, fmt.Errorf("QR plan %v-%v fails checks: %s", version, level, strings.Join(bad, "; "))
+	}
+	return p, nil
+}
+
+// rawDataModules returns the number of modules left for data,
+// check, and remainder bits in a code of version v: the area less
+// the finder patterns and their separators, the timing strips, the
+// alignment patterns, the format and version information, and the
+// dark module.
+func rawDataModules(v Version) int {
+	n := int(v)
+	raw := (16*n+128)*n + 64
+	if n >= 2 {
+		na := n/7 + 2
+		raw -= (25*na-10)*na - 55
+		if n >= 7 {
+			raw -= 2 * 18
+		}
+	}
+	return raw
+}
@@ -0,0 +1,70 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// A Delta lists the modules that differ between two codes.
+type Delta struct {
+	A, B    *Code
+	Changed []image.Point // in row-major order
+}
+
+// Diff compares the codes a and b, which must be the same size.
+func Diff(a, b *Code) (*Delta, error) {
+	if a.Size != b.Size {
+		return nil, fmt.Errorf("qr: cannot diff codes of size %d and %d", a.Size, b.Size)
+	}
+	d := &Delta{A: a, B: b}
+	for y := 0; y < a.Size; y++ {
+		for x := 0; x < a.Size; x++ {
+			if a.Black(x, y) != b.Black(x, y) {
+				d.Changed = append(d.Changed, image.Pt(x, y))
+			}
+		}
+	}
+	return d, nil
+}
+
+var (
+	diffDark  = color.RGBA{0x40, 0x40, 0x40, 0xff}
+	diffLight = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	diffLost  = color.RGBA{0xe0, 0x20, 0x20, 0xff} // dark in A, light in B
+	diffGain  = color.RGBA{0x20, 0xa0, 0x20, 0xff} // light in A, dark in B
+)
+
+// Image returns a picture of the difference, with A's scale and
+// a 4-module quiet zone.  Unchanged modules are drawn in gray and
+// white; modules dark only in A are red and modules dark only in B
+// are green.
+func (d *Delta) Image() *image.RGBA {
+	scale := d.A.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+	n := d.A.Size + 8
+	m := image.NewRGBA(image.Rect(0, 0, n*scale, n*scale))
+	for y := 0; y < n*scale; y++ {
+		for x := 0; x < n*scale; x++ {
+			mx, my := x/scale-4, y/scale-4
+			a, b := d.A.Black(mx, my), d.B.Black(mx, my)
+			col := diffLight
+			switch {
+			case a && b:
+				col = diffDark
+			case a:
+				col = diffLost
+			case b:
+				col = diffGain
+			}
+			m.SetRGBA(x, y, col)
+		}
+	}
+	return m
+}
@@ -0,0 +1,50 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"image"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a, err := Encode("hello, world", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &Code{Bitmap: append([]byte(nil), a.Bitmap...), Size: a.Size, Stride: a.Stride, Scale: 2}
+	b.Bitmap[10*b.Stride+1] ^= 0x40 // module 9, 10
+	b.Bitmap[12*b.Stride] ^= 0x01   // module 7, 12
+
+	d, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []image.Point{{9, 10}, {7, 12}}
+	if len(d.Changed) != len(want) || d.Changed[0] != want[0] || d.Changed[1] != want[1] {
+		t.Errorf("Changed = %v, want %v", d.Changed, want)
+	}
+
+	a.Scale = 2
+	m := d.Image()
+	for _, p := range want {
+		got := m.RGBAAt((p.X+4)*2, (p.Y+4)*2)
+		wantc := diffGain
+		if a.Black(p.X, p.Y) {
+			wantc = diffLost
+		}
+		if got != wantc {
+			t.Errorf("module %v drawn as %v, want %v", p, got, wantc)
+		}
+	}
+
+	c, err := Encode("a much longer text that needs a bigger code", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Diff(a, c); err == nil {
+		t.Errorf("Diff of different sizes succeeded")
+	}
+}
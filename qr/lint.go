@@ -0,0 +1,95 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"code.google.com/p/rsc/qr/coding"
+)
+
+// maxLintVersion is the largest version Lint accepts at level M
+// before suggesting a shorter payload.  Larger codes have modules
+// too small for phone cameras at ordinary print sizes.
+const maxLintVersion = 10
+
+// lookalikes maps characters that are easily pasted by accident
+// to the plain characters they resemble.
+var lookalikes = map[rune]string{
+	'\u00a0': " ",  // no-break space
+	'\u200b': "",   // zero width space
+	'\ufeff': "",   // byte order mark
+	'\u2010': "-",  // hyphen
+	'\u2013': "-",  // en dash
+	'\u2014': "-",  // em dash
+	'\u2018': "'",  // left single quotation mark
+	'\u2019': "'",  // right single quotation mark
+	'\u201c': "\"", // left double quotation mark
+	'\u201d': "\"", // right double quotation mark
+}
+
+// Lint inspects text before encoding and returns warnings about
+// common mistakes: insecure http URLs, surrounding white space,
+// URLs whose scheme and host are lower case when upper case would
+// allow the smaller alphanumeric mode, payloads too long to scan
+// comfortably, and characters that force 8-bit byte mode when
+// the rest of the text would fit a smaller mode.
+// It returns nil if it finds nothing to report.
+func Lint(text string) []string {
+	var warn []string
+	if strings.TrimSpace(text) != text {
+		warn = append(warn, "text has leading or trailing white space")
+	}
+
+	if u, err := url.Parse(text); err == nil && u.Host != "" && u.User == nil {
+		switch strings.ToLower(u.Scheme) {
+		case "http":
+			warn = append(warn, "URL uses http; use https if the site supports it")
+			fallthrough
+		case "https":
+			if up, ok := FoldURL(text); ok {
+				saved := coding.String(text).Bits(coding.MinVersion) - coding.Alpha(up).Bits(coding.MinVersion)
+				warn = append(warn, fmt.Sprintf("URL would use alphanumeric mode, saving %d bits, as %s", saved, up))
+			}
+		}
+	}
+
+	p, err := choose(text, M)
+	if err != nil {
+		warn = append(warn, "text is too long to encode")
+	} else if p.Version > maxLintVersion {
+		warn = append(warn, fmt.Sprintf("text needs version %d at level M; codes above version %d are hard to scan", p.Version, maxLintVersion))
+	}
+
+	// Characters forcing byte mode.
+	plain := text
+	seen := make(map[rune]bool)
+	for _, r := range text {
+		if s, ok := lookalikes[r]; ok && !seen[r] {
+			seen[r] = true
+			warn = append(warn, fmt.Sprintf("text contains %U, which looks like %q", r, s))
+			plain = strings.Replace(plain, string(r), s, -1)
+		}
+	}
+	if coding.Alpha(text).Check() != nil && coding.Alpha(plain).Check() == nil {
+		warn = append(warn, "replacing look-alike characters would allow alphanumeric mode")
+	} else if strings.IndexFunc(plain, unicode.IsLower) < 0 {
+		var odd []string
+		seen := make(map[rune]bool)
+		for _, r := range plain {
+			if !seen[r] && coding.Alpha(string(r)).Check() != nil {
+				seen[r] = true
+				odd = append(odd, fmt.Sprintf("%q", r))
+			}
+		}
+		if 0 < len(odd) && len(odd) <= 2 {
+			warn = append(warn, fmt.Sprintf("only %s keeps the text from using alphanumeric mode", strings.Join(odd, " and ")))
+		}
+	}
+	return warn
+}
@@ -0,0 +1,39 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"strings"
+	"testing"
+)
+
+var lintTests = []struct {
+	text string
+	want []string // substrings of the warnings, in order
+}{
+	{"https://swtch.com/qr", nil},
+	{"HTTPS://SWTCH.COM/QR", nil},
+	{"hello, world", nil},
+	{"http://swtch.com/qr", []string{"uses http"}},
+	{"https://Swtch.com/", []string{"alphanumeric mode, saving 44 bits, as HTTPS://SWTCH.COM/"}},
+	{"https://swtch.com/qr\n", []string{"white space"}},
+	{"HELLO WORLD!", []string{`only '!' keeps`}},
+	{"ORDER 12–B", []string{"U+2013", "replacing look-alike"}},
+	{"https://swtch.com/" + strings.Repeat("x", 300), []string{"needs version 13"}},
+	{strings.Repeat("x", 3000), []string{"too long"}},
+}
+
+func TestLint(t *testing.T) {
+	for _, tt := range lintTests {
+		warn := Lint(tt.text)
+		ok := len(warn) == len(tt.want)
+		for i := 0; ok && i < len(warn); i++ {
+			ok = strings.Contains(warn[i], tt.want[i])
+		}
+		if !ok {
+			t.Errorf("Lint(%q) = %q, want %q", tt.text, warn, tt.want)
+		}
+	}
+}
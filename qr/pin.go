@@ -0,0 +1,275 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"code.google.com/p/rsc/qr/coding"
+)
+
+// A Pin records every choice Encode made for a code: the version,
+// the mask, and how the text was split into segments.  Replaying a
+// pin produces the same symbol even if a later version of this
+// package would choose differently, so artwork that has been printed
+// can be regenerated exactly.  Pins are meant to be stored, and
+// their fields marshal to JSON as is.
+type Pin struct {
+	Version  int       `json:"version"`
+	Level    Level     `json:"level"`
+	Mask     int       `json:"mask"`
+	Segments []Segment `json:"segments"`
+}
+
+// A Segment is a run of text encoded in a single mode.
+type Segment struct {
+	Mode Mode   `json:"mode"`
+	Text string `json:"text"`
+}
+
+// A Mode is a segment's data encoding.
+type Mode string
+
+const (
+	Numeric      Mode = "numeric"
+	Alphanumeric Mode = "alphanumeric"
+	Byte         Mode = "byte"
+	Kanji        Mode = "kanji" // Text holds Shift JIS, not UTF-8
+	Hanzi        Mode = "hanzi" // Text holds GB 2312, not UTF-8
+	ECI          Mode = "eci"   // Text holds the decimal assignment number
+)
+
+// RuneMode returns the most compact mode that can encode r.
+func RuneMode(r rune) Mode {
+	switch {
+	case '0' <= r && r <= '9':
+		return Numeric
+	case 'A' <= r && r <= 'Z', strings.ContainsRune(" $%*+-./:", r):
+		return Alphanumeric
+	}
+	return Byte
+}
+
+// DetectMode returns the most compact mode that can encode
+// all of s as a single segment.  It never returns Kanji or Hanzi,
+// because s is taken to be UTF-8.
+func DetectMode(s string) Mode {
+	m := Numeric
+	for _, r := range s {
+		switch RuneMode(r) {
+		case Byte:
+			return Byte
+		case Alphanumeric:
+			m = Alphanumeric
+		}
+	}
+	return m
+}
+
+// An Option overrides one of the choices Encode makes.
+// Options apply to the pin after the version is chosen.
+type Option func(*Pin) error
+
+// WithMask returns an option that makes Encode use mask m,
+// from 0 to 7, instead of the mask with the lowest penalty.
+// It is for reproducing the output of other encoders exactly.
+func WithMask(m int) Option {
+	return func(p *Pin) error {
+		if m < 0 || m > 7 {
+			return fmt.Errorf("qr: invalid mask %d", m)
+		}
+		p.Mask = m
+		return nil
+	}
+}
+
+// EncodePinned is like Encode but also returns the pin
+// recording how the code was made.
+func EncodePinned(text string, level Level, opts ...Option) (*Code, *Pin, error) {
+	p, err := choose(text, level, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	c, err := p.Replay()
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, p, nil
+}
+
+// EncodeSegments returns an encoding of the segments, in order,
+// at the given error correction level.  It lets the caller choose
+// where modes change, for example an alphanumeric prefix followed
+// by byte data, instead of encoding the whole text in one mode.
+func EncodeSegments(segs []Segment, level Level) (*Code, error) {
+	p, err := fit(level, segs)
+	if err != nil {
+		return nil, err
+	}
+	return p.Replay()
+}
+
+// EstimateBits returns the number of bits that seg takes in a code
+// of the given version, including its mode indicator and character
+// count, without building a code.
+func EstimateBits(seg Segment, version int) (int, error) {
+	v := coding.Version(version)
+	if v < coding.MinVersion || v > coding.MaxVersion {
+		return 0, fmt.Errorf("qr: invalid version %d", version)
+	}
+	e, err := seg.encoding()
+	if err != nil {
+		return 0, err
+	}
+	return e.Bits(v), nil
+}
+
+// SmallestVersion returns the smallest version that holds the
+// segments at the given error correction level.  It tries each
+// version in turn, since the character count fields, and so the
+// size of the segments, grow at versions 10 and 27.
+func SmallestVersion(segs []Segment, level Level) (int, error) {
+	p, err := fit(level, segs)
+	if err != nil {
+		return 0, err
+	}
+	return p.Version, nil
+}
+
+// Replay encodes the pinned segments using exactly the pinned
+// version, level, and mask.  It returns an error if they do not fit,
+// rather than choosing anything else.
+func (p *Pin) Replay() (*Code, error) {
+	if p.Level < L || p.Level > H {
+		return nil, fmt.Errorf("qr: invalid pinned level %d", int(p.Level))
+	}
+	v := coding.Version(p.Version)
+	if v < coding.MinVersion || v > coding.MaxVersion {
+		return nil, fmt.Errorf("qr: invalid pinned version %d", p.Version)
+	}
+	if p.Mask < 0 || p.Mask > 7 {
+		return nil, fmt.Errorf("qr: invalid pinned mask %d", p.Mask)
+	}
+	enc, err := encodings(p.Segments)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := coding.NewPlan(v, coding.Level(p.Level), coding.Mask(p.Mask))
+	if err != nil {
+		return nil, err
+	}
+	cc, err := plan.Encode(enc...)
+	if err != nil {
+		return nil, fmt.Errorf("qr: replaying pin: %v", err)
+	}
+	// Check that the format bits announce the pinned level and mask.
+	if l, m, n, err := coding.FormatErrors(cc); err != nil || l != coding.Level(p.Level) || int(m) != p.Mask || n != 0 {
+		return nil, fmt.Errorf("qr: internal error: format bits read as level %v, mask %d, %d errors, %v", l, int(m), n, err)
+	}
+	return &Code{cc.Bitmap, cc.Size, cc.Stride, 8}, nil
+}
+
+// Text returns the concatenated text of the segments.
+// ECI designators are not text and are left out.
+func (p *Pin) Text() string {
+	s := ""
+	for _, seg := range p.Segments {
+		if seg.Mode != ECI {
+			s += seg.Text
+		}
+	}
+	return s
+}
+
+// An InvalidCharError reports a character that a segment's mode
+// cannot encode.  Segments check the characters of Numeric and
+// Alphanumeric text and the two-byte codes of Kanji and Hanzi text;
+// ToShiftJIS reports characters with no Kanji code.  Kanji and Hanzi
+// text holds Shift JIS or GB 2312 bytes rather than UTF-8, so for an
+// invalid code, or an odd byte at the end, Rune is utf8.RuneError.
+type InvalidCharError struct {
+	Index int  // byte offset of the character in the text
+	Rune  rune // the character
+	Mode  Mode // the segment's mode
+}
+
+func (e *InvalidCharError) Error() string {
+	return fmt.Sprintf("qr: %s mode cannot encode %q at offset %d", e.Mode, e.Rune, e.Index)
+}
+
+// encodings returns the encodings of segs.  An InvalidCharError's
+// Index is the offset in the text of all the segments, as returned
+// by Pin.Text, not just in the segment that holds the character.
+func encodings(segs []Segment) ([]coding.Encoding, error) {
+	var enc []coding.Encoding
+	off := 0
+	for _, s := range segs {
+		e, err := s.encoding()
+		if err, ok := err.(*InvalidCharError); ok {
+			err.Index += off
+			return nil, err
+		}
+		if err != nil {
+			return nil, err
+		}
+		enc = append(enc, e)
+		if s.Mode != ECI {
+			off += len(s.Text)
+		}
+	}
+	return enc, nil
+}
+
+func (s Segment) encoding() (coding.Encoding, error) {
+	switch s.Mode {
+	case Numeric, Alphanumeric:
+		for i, r := range s.Text {
+			if rank(RuneMode(r)) > rank(s.Mode) {
+				return nil, &InvalidCharError{i, r, s.Mode}
+			}
+		}
+	case Kanji, Hanzi:
+		for i := 0; i < len(s.Text); i += 2 {
+			if i+2 > len(s.Text) {
+				return nil, &InvalidCharError{i, utf8.RuneError, s.Mode}
+			}
+			var e coding.Encoding = coding.Kanji(s.Text[i : i+2])
+			if s.Mode == Hanzi {
+				e = coding.Hanzi(s.Text[i : i+2])
+			}
+			if e.Check() != nil {
+				return nil, &InvalidCharError{i, utf8.RuneError, s.Mode}
+			}
+		}
+	}
+	var e coding.Encoding
+	switch s.Mode {
+	case Numeric:
+		e = coding.Num(s.Text)
+	case Alphanumeric:
+		e = coding.Alpha(s.Text)
+	case Byte:
+		e = coding.String(s.Text)
+	case Kanji:
+		e = coding.Kanji(s.Text)
+	case Hanzi:
+		e = coding.Hanzi(s.Text)
+	case ECI:
+		n, err := strconv.Atoi(s.Text)
+		if err != nil {
+			return nil, fmt.Errorf("qr: invalid ECI segment %q", s.Text)
+		}
+		e = coding.ECI(n)
+	default:
+		return nil, fmt.Errorf("qr: unknown segment mode %q", s.Mode)
+	}
+	if err := e.Check(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
@@ -0,0 +1,254 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"unicode/utf8"
+
+	"code.google.com/p/rsc/qr/coding"
+)
+
+func TestPin(t *testing.T) {
+	c, p, err := EncodePinned("HELLO WORLD", Q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Pin{Version: 1, Level: Q, Mask: 0, Segments: []Segment{{Alphanumeric, "HELLO WORLD"}}}
+	if p.Version != want.Version || p.Level != want.Level || p.Mask != want.Mask ||
+		len(p.Segments) != 1 || p.Segments[0] != want.Segments[0] {
+		t.Fatalf("pin = %+v, want %+v", p, want)
+	}
+
+	js, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p1 Pin
+	if err := json.Unmarshal(js, &p1); err != nil {
+		t.Fatal(err)
+	}
+	c1, err := p1.Replay()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(c.Bitmap, c1.Bitmap) || p1.Text() != "HELLO WORLD" {
+		t.Errorf("replayed pin %s differs from original", js)
+	}
+
+	// A different mask must be honored, not replaced.
+	p1.Mask = 3
+	c2, err := p1.Replay()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(c.Bitmap, c2.Bitmap) {
+		t.Errorf("replay ignored pinned mask")
+	}
+
+	for _, bad := range []Pin{
+		{Version: 0, Level: L, Segments: want.Segments},
+		{Version: 1, Level: L, Mask: 8, Segments: want.Segments},
+		{Version: 1, Level: L, Segments: []Segment{{"morse", "x"}}},
+		{Version: 1, Level: L, Segments: []Segment{{Kanji, "x"}}},
+		{Version: 1, Level: L, Segments: []Segment{{Numeric, "12a"}}},
+		{Version: 1, Level: H, Segments: []Segment{{Byte, "this text is too long for version 1"}}},
+	} {
+		if _, err := bad.Replay(); err == nil {
+			t.Errorf("Replay(%+v) succeeded", bad)
+		}
+	}
+}
+
+func TestEncodeECI(t *testing.T) {
+	const text = "h\u00e9llo, w\u00f6rld"
+	c, err := EncodeECI(text, M, 26)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := coding.Decode(&coding.Code{Bitmap: c.Bitmap, Size: c.Size, Stride: c.Stride})
+	if err != nil || string(out) != text {
+		t.Fatalf("Decode(EncodeECI(%q)) = %q, %v", text, out, err)
+	}
+	if _, err := EncodeECI(text, M, -1); err == nil {
+		t.Errorf("EncodeECI with assignment -1 succeeded")
+	}
+}
+
+var detectTests = []struct {
+	s    string
+	mode Mode
+}{
+	{"", Numeric},
+	{"0123", Numeric},
+	{"HELLO WORLD", Alphanumeric},
+	{"12:34", Alphanumeric},
+	{"Hello", Byte},
+	{"café", Byte},
+}
+
+func TestDetectMode(t *testing.T) {
+	for _, tt := range detectTests {
+		if m := DetectMode(tt.s); m != tt.mode {
+			t.Errorf("DetectMode(%q) = %s, want %s", tt.s, m, tt.mode)
+		}
+	}
+	if m := RuneMode('$'); m != Alphanumeric {
+		t.Errorf("RuneMode('$') = %s, want %s", m, Alphanumeric)
+	}
+}
+
+var charsetTests = []struct {
+	text string
+	cs   Charset
+	want string // decoded bytes, or "" for an error
+}{
+	{"hello", Latin1, "hello"},
+	{"café", Latin1, "caf\xe9"},
+	{"café", UTF8, "caf\xc3\xa9"},
+	{"日本", Latin1, ""},
+	{"日本", UTF8, "日本"},
+	{"\xff", UTF8, ""},
+}
+
+func TestEncodeCharset(t *testing.T) {
+	for _, tt := range charsetTests {
+		c, err := EncodeCharset(tt.text, L, tt.cs)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("EncodeCharset(%q, %d) succeeded, want error", tt.text, tt.cs)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("EncodeCharset(%q, %d): %v", tt.text, tt.cs, err)
+			continue
+		}
+		out, err := coding.Decode(&coding.Code{Bitmap: c.Bitmap, Size: c.Size, Stride: c.Stride})
+		if err != nil || string(out) != tt.want {
+			t.Errorf("Decode(EncodeCharset(%q, %d)) = %q, %v, want %q", tt.text, tt.cs, out, err, tt.want)
+		}
+	}
+}
+
+func TestEncodeSegments(t *testing.T) {
+	segs := []Segment{{Alphanumeric, "HTTPS://EXAMPLE.COM/"}, {Byte, "?id=x7"}, {Numeric, "0123456789"}}
+	c, err := EncodeSegments(segs, M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := coding.Decode(&coding.Code{Bitmap: c.Bitmap, Size: c.Size, Stride: c.Stride})
+	if want := "HTTPS://EXAMPLE.COM/?id=x7" + "0123456789"; err != nil || string(out) != want {
+		t.Fatalf("Decode(EncodeSegments) = %q, %v, want %q", out, err, want)
+	}
+	if _, err := EncodeSegments([]Segment{{Numeric, "12a"}}, M); err == nil {
+		t.Errorf("EncodeSegments accepted letters in a numeric segment")
+	}
+}
+
+func TestInvalidChar(t *testing.T) {
+	segs := []Segment{{ECI, "26"}, {Byte, "id="}, {Numeric, "12"}, {Alphanumeric, "ABéC"}}
+	_, err := EncodeSegments(segs, M)
+	e, ok := err.(*InvalidCharError)
+	if !ok {
+		t.Fatalf("EncodeSegments error = %v, want *InvalidCharError", err)
+	}
+	if want := (InvalidCharError{7, 'é', Alphanumeric}); *e != want {
+		t.Errorf("EncodeSegments error = %+v, want %+v", *e, want)
+	}
+
+	for _, tt := range []struct {
+		segs []Segment
+		want InvalidCharError
+	}{
+		{[]Segment{{Numeric, "12"}, {Numeric, "3x"}}, InvalidCharError{3, 'x', Numeric}},
+		{[]Segment{{Byte, "k:"}, {Kanji, "\x93\x5f\x00\x41"}}, InvalidCharError{4, utf8.RuneError, Kanji}},
+		{[]Segment{{Kanji, "\x93\x5f\xe4"}}, InvalidCharError{2, utf8.RuneError, Kanji}},
+		{[]Segment{{Alphanumeric, "H"}, {Hanzi, "\xb0\xa1\xab\xa1"}}, InvalidCharError{3, utf8.RuneError, Hanzi}},
+		{[]Segment{{Hanzi, "\xb0"}}, InvalidCharError{0, utf8.RuneError, Hanzi}},
+	} {
+		_, err := EncodeSegments(tt.segs, M)
+		if e, ok := err.(*InvalidCharError); !ok || *e != tt.want {
+			t.Errorf("EncodeSegments(%q) error = %v, want %+v", tt.segs, err, tt.want)
+		}
+	}
+}
+
+var estimateTests = []struct {
+	seg     Segment
+	version int
+	bits    int
+}{
+	{Segment{Numeric, "01234567"}, 1, 4 + 10 + 27},
+	{Segment{Alphanumeric, "AC-42"}, 1, 4 + 9 + 28},
+	{Segment{Byte, "hello"}, 10, 4 + 16 + 40},
+	{Segment{Numeric, "1"}, 27, 4 + 14 + 4},
+	{Segment{ECI, "26"}, 1, 12},
+}
+
+func TestEstimateBits(t *testing.T) {
+	for _, tt := range estimateTests {
+		n, err := EstimateBits(tt.seg, tt.version)
+		if err != nil || n != tt.bits {
+			t.Errorf("EstimateBits(%v, %d) = %d, %v, want %d", tt.seg, tt.version, n, err, tt.bits)
+		}
+	}
+	if _, err := EstimateBits(Segment{Numeric, "x"}, 1); err == nil {
+		t.Errorf("EstimateBits accepted invalid segment")
+	}
+	if _, err := EstimateBits(Segment{Numeric, "1"}, 41); err == nil {
+		t.Errorf("EstimateBits accepted version 41")
+	}
+}
+
+func TestPinMask(t *testing.T) {
+	// Encode picks the mask with the lowest penalty.
+	for _, text := range []string{"HELLO WORLD", "0123456789", "https://golang.org/"} {
+		c, pin, err := EncodePinned(text, M)
+		if err != nil {
+			t.Fatal(err)
+		}
+		penalty := func(c *Code) int {
+			return (&coding.Code{Bitmap: c.Bitmap, Size: c.Size, Stride: c.Stride}).Penalty()
+		}
+		for m := 0; m < 8; m++ {
+			p := *pin
+			p.Mask = m
+			c1, err := p.Replay()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if penalty(c1) < penalty(c) {
+				t.Errorf("%q: chose mask %d with penalty %d, but mask %d has %d", text, pin.Mask, penalty(c), m, penalty(c1))
+			}
+		}
+	}
+}
+
+func TestWithMask(t *testing.T) {
+	for m := 0; m < 8; m++ {
+		c, pin, err := EncodePinned("HELLO WORLD", Q, WithMask(m))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pin.Mask != m {
+			t.Errorf("WithMask(%d): pin has mask %d", m, pin.Mask)
+		}
+		cc := &coding.Code{Bitmap: c.Bitmap, Size: c.Size, Stride: c.Stride}
+		l, m1, _, err := coding.FormatErrors(cc)
+		if err != nil || l != coding.Q || int(m1) != m {
+			t.Errorf("WithMask(%d): format reads %v, %d, %v", m, l, int(m1), err)
+		}
+		data, err := coding.Decode(cc)
+		if err != nil || string(data) != "HELLO WORLD" {
+			t.Errorf("WithMask(%d): Decode = %q, %v", m, data, err)
+		}
+	}
+	if _, err := Encode("x", L, WithMask(8)); err == nil {
+		t.Errorf("WithMask(8) accepted")
+	}
+}
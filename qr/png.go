@@ -9,8 +9,11 @@ package qr
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/hex"
 	"hash"
 	"hash/crc32"
+	"strconv"
+	"unicode/utf8"
 )
 
 // PNG returns a PNG image displaying the code.
@@ -21,7 +24,49 @@ import (
 // on c.Image().
 func (c *Code) PNG() []byte {
 	var p pngWriter
-	return p.encode(c)
+	return p.encode(c, nil)
+}
+
+// PNGMetadata describes the contents of a code, for recording
+// in images so that asset-management systems can index them
+// without decoding them.  It is unrelated to Code.Metadata,
+// which describes the code for package barcode.
+type PNGMetadata struct {
+	Payload string
+	Level   Level
+}
+
+// PNGWithMetadata is like PNG but also records m and the code's
+// version in the image: QR-Version and QR-Level in tEXt chunks,
+// and QR-Payload as UTF-8 in an iTXt chunk.  A payload that is not
+// valid UTF-8 is recorded in hexadecimal as QR-Payload-Hex instead.
+// If m is nil, PNGWithMetadata is the same as PNG.
+func (c *Code) PNGWithMetadata(m *PNGMetadata) []byte {
+	if m == nil {
+		return c.PNG()
+	}
+	var text []pngChunk
+	add := func(key, val string) {
+		text = append(text, pngChunk{"tEXt", []byte(key + "\x00" + val)})
+	}
+	add("QR-Version", strconv.Itoa((c.Size-17)/4))
+	if L <= m.Level && m.Level <= H {
+		add("QR-Level", string("LMQH"[m.Level]))
+	}
+	if utf8.ValidString(m.Payload) {
+		// Uncompressed, with no language tag or translated keyword.
+		text = append(text, pngChunk{"iTXt", []byte("QR-Payload\x00\x00\x00\x00\x00" + m.Payload)})
+	} else {
+		add("QR-Payload-Hex", hex.EncodeToString([]byte(m.Payload)))
+	}
+	var p pngWriter
+	return p.encode(c, text)
+}
+
+// A pngChunk is an extra chunk for pngWriter to write.
+type pngChunk struct {
+	name string
+	data []byte
 }
 
 type pngWriter struct {
@@ -34,7 +79,8 @@ type pngWriter struct {
 
 var pngHeader = []byte("\x89PNG\r\n\x1a\n")
 
-func (w *pngWriter) encode(c *Code) []byte {
+// encode encodes c, adding the extra chunks after the comment.
+func (w *pngWriter) encode(c *Code, extra []pngChunk) []byte {
 	scale := c.Scale
 	siz := c.Size
 
@@ -55,6 +101,9 @@ func (w *pngWriter) encode(c *Code) []byte {
 
 	// Comment
 	w.writeChunk("tEXt", comment)
+	for _, ch := range extra {
+		w.writeChunk(ch.name, ch.data)
+	}
 
 	// Data
 	w.zlib.writeCode(c)
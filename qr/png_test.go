@@ -6,6 +6,8 @@ package qr
 
 import (
 	"bytes"
+	"encoding/binary"
+	"hash/crc32"
 	"image"
 	"image/color"
 	"image/png"
@@ -47,6 +49,65 @@ func TestPNG(t *testing.T) {
 	}
 }
 
+// textChunks returns the keyword and text of each tEXt and iTXt chunk
+// in the PNG data, checking the chunk CRCs.
+func textChunks(t *testing.T, data []byte) map[string]string {
+	m := make(map[string]string)
+	data = data[len(pngHeader):]
+	for len(data) >= 12 {
+		n := binary.BigEndian.Uint32(data)
+		name, body := string(data[4:8]), data[8:8+n]
+		if crc := binary.BigEndian.Uint32(data[8+n:]); crc != crc32.ChecksumIEEE(data[4:8+n]) {
+			t.Fatalf("bad CRC in %s chunk", name)
+		}
+		data = data[12+n:]
+		i := bytes.IndexByte(body, 0)
+		switch name {
+		case "tEXt":
+			m[string(body[:i])] = string(body[i+1:])
+		case "iTXt":
+			// Skip flag, method, language tag, and translated keyword.
+			rest := body[i+3:]
+			rest = rest[bytes.IndexByte(rest, 0)+1:]
+			rest = rest[bytes.IndexByte(rest, 0)+1:]
+			m[string(body[:i])] = string(rest)
+		}
+	}
+	return m
+}
+
+func TestPNGWithMetadata(t *testing.T) {
+	c, err := Encode("héllo, world", Q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := c.PNGWithMetadata(&PNGMetadata{Payload: "héllo, world", Level: Q})
+	if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	text := textChunks(t, data)
+	want := map[string]string{
+		"Software":   "QR-PNG http://qr.swtch.com/",
+		"QR-Version": "2",
+		"QR-Level":   "Q",
+		"QR-Payload": "héllo, world",
+	}
+	for k, v := range want {
+		if text[k] != v {
+			t.Errorf("%s = %q, want %q", k, text[k], v)
+		}
+	}
+
+	text = textChunks(t, c.PNGWithMetadata(&PNGMetadata{Payload: "\xff\x00", Level: Q}))
+	if text["QR-Payload-Hex"] != "ff00" || text["QR-Payload"] != "" {
+		t.Errorf("binary payload recorded as %q", text)
+	}
+
+	if !bytes.Equal(c.PNGWithMetadata(nil), c.PNG()) {
+		t.Errorf("PNGWithMetadata(nil) differs from PNG()")
+	}
+}
+
 func BenchmarkPNG(b *testing.B) {
 	c, err := Encode("0123456789012345678901234567890123456789", L)
 	if err != nil {
@@ -9,9 +9,16 @@ package qr
 
 import (
 	"errors"
+	"fmt"
 	"image"
 	"image/color"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"strconv"
+	"unicode/utf8"
 
+	"code.google.com/p/rsc/barcode"
 	"code.google.com/p/rsc/qr/coding"
 )
 
@@ -27,18 +34,213 @@ const (
 )
 
 // Encode returns an encoding of text at the given error correction level.
-func Encode(text string, level Level) (*Code, error) {
+// Bytes outside ASCII are written as is, and readers disagree about
+// how to interpret them; use EncodeCharset for non-ASCII text.
+func Encode(text string, level Level, opts ...Option) (*Code, error) {
+	c, _, err := EncodePinned(text, level, opts...)
+	return c, err
+}
+
+// EncodeECI is like Encode but begins the code with an ECI designator,
+// so that strict readers know how to interpret the text's bytes.
+// The usual assignment number for Go strings is 26, UTF-8.
+func EncodeECI(text string, level Level, eci int) (*Code, error) {
+	p, err := fit(level, []Segment{{ECI, strconv.Itoa(eci)}, {DetectMode(text), text}})
+	if err != nil {
+		return nil, err
+	}
+	return p.Replay()
+}
+
+// A Charset says how EncodeCharset writes non-ASCII text.
+type Charset int
+
+const (
+	// Latin1 transcodes the text to ISO-8859-1, which the QR
+	// standard defines as the interpretation of byte data.
+	// Text with characters outside ISO-8859-1 cannot be encoded.
+	Latin1 Charset = iota
+
+	// UTF8 writes the text as UTF-8, preceded by an ECI
+	// designator (assignment number 26) if it is not ASCII.
+	UTF8
+)
+
+// EncodeCharset is like Encode but writes non-ASCII text
+// in the given character set, so that readers interpret it correctly.
+func EncodeCharset(text string, level Level, cs Charset) (*Code, error) {
+	if !utf8.ValidString(text) {
+		return nil, errors.New("qr: text is not valid UTF-8")
+	}
+	ascii := true
+	for i := 0; i < len(text); i++ {
+		if text[i] >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return Encode(text, level)
+	}
+	switch cs {
+	case Latin1:
+		b := make([]byte, 0, len(text))
+		for _, r := range text {
+			if r > 0xff {
+				return nil, fmt.Errorf("qr: %q is not in ISO-8859-1", r)
+			}
+			b = append(b, byte(r))
+		}
+		return Encode(string(b), level)
+	case UTF8:
+		return EncodeECI(text, level, 26)
+	}
+	return nil, fmt.Errorf("qr: unknown charset %d", int(cs))
+}
+
+// EncodeBytes returns an encoding of data in byte mode at the
+// given error correction level.  Unlike Encode, it never chooses
+// another mode, even if data happens to be all digits, so readers
+// return exactly the bytes written.
+func EncodeBytes(data []byte, level Level) (*Code, error) {
+	return EncodeSegments([]Segment{{Byte, string(data)}}, level)
+}
+
+// EncodeHeader returns an encoding of a text header followed by
+// binary data, such as "ID:12345" and a CBOR message.  The header
+// uses its smallest mode and the data byte mode, each in its own
+// segment, so readers return the header bytes and then the data.
+func EncodeHeader(header string, data []byte, level Level) (*Code, error) {
+	return EncodeSegments([]Segment{{DetectMode(header), header}, {Byte, string(data)}}, level)
+}
+
+// EncodeNumber returns an encoding of the decimal digits of n
+// in numeric mode at the given error correction level.
+func EncodeNumber(n uint64, level Level) (*Code, error) {
+	return EncodeSegments([]Segment{{Numeric, strconv.FormatUint(n, 10)}}, level)
+}
+
+// EncodeBigNumber is like EncodeNumber but for large numbers,
+// such as serial numbers, up to the 7089 digits that a version 40
+// code at level L holds.  The number must not be negative.
+func EncodeBigNumber(n *big.Int, level Level) (*Code, error) {
+	if n.Sign() < 0 {
+		return nil, errors.New("qr: cannot encode negative number")
+	}
+	return EncodeSegments([]Segment{{Numeric, n.String()}}, level)
+}
+
+// EncodeReader returns an encoding, in byte mode, of the data read
+// from r, which must hold at most limit bytes.  The limit is lowered
+// to the most bytes that a code at the given level can hold.
+// A negative limit is an error.
+func EncodeReader(r io.Reader, limit int, level Level) (*Code, error) {
+	if limit < 0 {
+		return nil, fmt.Errorf("qr: negative limit %d", limit)
+	}
+	if max := Capacity(coding.MaxVersion, level, Byte); limit > max {
+		limit = max
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > limit {
+		return nil, fmt.Errorf("qr: input longer than %d bytes", limit)
+	}
+	return EncodeBytes(data, level)
+}
+
+// EncodeCodewords returns a code of the given version and level
+// holding data, which must be the complete data codewords for that
+// version and level, bit stream and padding already assembled.
+// It is for callers that build their own bit streams.
+func EncodeCodewords(data []byte, version int, level Level) (*Code, error) {
+	v := coding.Version(version)
+	if v < coding.MinVersion || v > coding.MaxVersion {
+		return nil, fmt.Errorf("qr: invalid version %d", version)
+	}
+	p, err := coding.NewPlan(v, coding.Level(level), coding.MaskAuto)
+	if err != nil {
+		return nil, err
+	}
+	cc, err := p.EncodeCodewords(data)
+	if err != nil {
+		return nil, fmt.Errorf("qr: %v", err)
+	}
+	return &Code{cc.Bitmap, cc.Size, cc.Stride, 8}, nil
+}
+
+// Capacity returns the number of characters that a code of the
+// given version and level can hold in a single segment of the given mode.
+// For Byte mode it is a number of bytes, and for Kanji and Hanzi
+// a number of double-byte characters.  It returns 0 for an invalid version or mode.
+func Capacity(version int, level Level, mode Mode) int {
+	v := coding.Version(version)
+	if v < coding.MinVersion || v > coding.MaxVersion || level < L || level > H {
+		return 0
+	}
+	e, err := Segment{mode, ""}.encoding()
+	if err != nil || mode == ECI {
+		return 0
+	}
+	hdr := e.Bits(v) // mode indicator and character count
+	n := DataCapacity(version, level)*8 - hdr
+	var c int
+	switch mode {
+	case Numeric:
+		c = n / 10 * 3
+		switch {
+		case n%10 >= 7:
+			c += 2
+		case n%10 >= 4:
+			c++
+		}
+	case Alphanumeric:
+		c = n / 11 * 2
+		if n%11 >= 6 {
+			c++
+		}
+	case Byte:
+		c = n / 8
+	case Kanji, Hanzi:
+		c = n / 13
+	}
+	nc := hdr - 4 // character count bits
+	if mode == Hanzi {
+		nc -= 4 // subset indicator
+	}
+	if max := 1<<uint(nc) - 1; c > max {
+		c = max
+	}
+	return c
+}
+
+// DataCapacity returns the number of data bytes, including
+// mode indicators and character counts, that a code of the given
+// version and level can hold.  It returns 0 for an invalid version.
+func DataCapacity(version int, level Level) int {
+	v := coding.Version(version)
+	if v < coding.MinVersion || v > coding.MaxVersion || level < L || level > H {
+		return 0
+	}
+	return v.DataBytes(coding.Level(level))
+}
+
+// choose makes the choices for encoding text at the given level.
+func choose(text string, level Level, opts ...Option) (*Pin, error) {
 	// Pick data encoding, smallest first.
 	// We could split the string and use different encodings
 	// but that seems like overkill for now.
-	var enc coding.Encoding
-	switch {
-	case coding.Num(text).Check() == nil:
-		enc = coding.Num(text)
-	case coding.Alpha(text).Check() == nil:
-		enc = coding.Alpha(text)
-	default:
-		enc = coding.String(text)
+	return fit(level, []Segment{{DetectMode(text), text}}, opts...)
+}
+
+// fit chooses the smallest version that holds segs at the given level,
+// applies the options, and then chooses the mask if no option did.
+func fit(level Level, segs []Segment, opts ...Option) (*Pin, error) {
+	enc, err := encodings(segs)
+	if err != nil {
+		return nil, err
 	}
 
 	// Pick size.
@@ -48,24 +250,35 @@ func Encode(text string, level Level) (*Code, error) {
 		if v > coding.MaxVersion {
 			return nil, errors.New("text too long to encode as QR")
 		}
-		if enc.Bits(v) <= v.DataBytes(l)*8 {
+		n := 0
+		for _, e := range enc {
+			n += e.Bits(v)
+		}
+		if n <= v.DataBytes(l)*8 {
 			break
 		}
 	}
 
-	// Build and execute plan.
-	p, err := coding.NewPlan(v, l, 0)
-	if err != nil {
-		return nil, err
-	}
-	cc, err := p.Encode(enc)
-	if err != nil {
-		return nil, err
+	p := &Pin{Version: int(v), Level: level, Mask: -1, Segments: segs}
+	for _, opt := range opts {
+		if err := opt(p); err != nil {
+			return nil, err
+		}
 	}
 
-	// TODO: Pick appropriate mask.
-
-	return &Code{cc.Bitmap, cc.Size, cc.Stride, 8}, nil
+	// Pick the mask with the lowest penalty.
+	if p.Mask < 0 {
+		plan, err := coding.NewPlan(v, l, coding.MaskAuto)
+		if err != nil {
+			return nil, err
+		}
+		m, err := plan.ChooseMask(enc...)
+		if err != nil {
+			return nil, err
+		}
+		p.Mask = int(m)
+	}
+	return p, nil
 }
 
 // A Code is a square pixel grid.
@@ -83,6 +296,19 @@ func (c *Code) Black(x, y int) bool {
 		c.Bitmap[y*c.Stride+x/8]&(1<<uint(7-x&7)) != 0
 }
 
+// Bounds returns the extent of the code in modules.
+// Together with Black and Metadata, it implements barcode.Barcode.
+func (c *Code) Bounds() image.Rectangle {
+	return image.Rect(0, 0, c.Size, c.Size)
+}
+
+var _ barcode.Barcode = (*Code)(nil)
+
+// Metadata describes the code for package barcode.
+func (c *Code) Metadata() barcode.Metadata {
+	return barcode.Metadata{Symbology: "QR", QuietZone: 4}
+}
+
 // Image returns an Image displaying the code.
 func (c *Code) Image() image.Image {
 	return &codeImage{c}
@@ -0,0 +1,151 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"code.google.com/p/rsc/qr/coding"
+)
+
+// Capacities from ISO/IEC 18004 Table 7.
+var capacityTests = []struct {
+	version int
+	level   Level
+	mode    Mode
+	n       int
+}{
+	{1, L, Numeric, 41},
+	{1, L, Alphanumeric, 25},
+	{1, L, Byte, 17},
+	{1, L, Kanji, 10},
+	{1, L, Hanzi, 10},
+	{40, L, Hanzi, 1817},
+	{1, H, Numeric, 17},
+	{10, M, Byte, 213},
+	{2, M, Numeric, 63},
+	{2, M, Alphanumeric, 38},
+	{2, M, Kanji, 16},
+	{40, L, Numeric, 7089},
+	{40, L, Alphanumeric, 4296},
+	{40, L, Byte, 2953},
+	{40, H, Kanji, 784},
+	{0, L, Byte, 0},
+	{1, L, ECI, 0},
+}
+
+func TestCapacity(t *testing.T) {
+	for _, tt := range capacityTests {
+		if n := Capacity(tt.version, tt.level, tt.mode); n != tt.n {
+			t.Errorf("Capacity(%d, %v, %s) = %d, want %d", tt.version, tt.level, tt.mode, n, tt.n)
+		}
+	}
+	if n := DataCapacity(40, L); n != 2956 {
+		t.Errorf("DataCapacity(40, L) = %d, want 2956", n)
+	}
+}
+
+func TestSmallestVersion(t *testing.T) {
+	for v := 1; v <= 40; v++ {
+		for _, mode := range []Mode{Numeric, Alphanumeric, Byte} {
+			n := Capacity(v, M, mode)
+			text := strings.Repeat("1", n)
+			if got, err := SmallestVersion([]Segment{{mode, text}}, M); err != nil || got > v {
+				t.Errorf("SmallestVersion(%d %s) = %d, %v, want at most %d", n, mode, got, err, v)
+			}
+			if v < 40 {
+				got, err := SmallestVersion([]Segment{{mode, text + "1"}}, M)
+				if err != nil || got <= v {
+					t.Errorf("SmallestVersion(%d %s) = %d, %v, want more than %d", n+1, mode, got, err, v)
+				}
+			}
+		}
+	}
+	if _, err := SmallestVersion([]Segment{{Byte, strings.Repeat("x", 3000)}}, L); err == nil {
+		t.Errorf("SmallestVersion accepted 3000 bytes")
+	}
+}
+
+func TestEncodeReader(t *testing.T) {
+	data := strings.Repeat("\x00\xff binary ", 10)
+	c, err := EncodeReader(strings.NewReader(data), 100, M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := coding.Decode(&coding.Code{Bitmap: c.Bitmap, Size: c.Size, Stride: c.Stride})
+	if err != nil || string(out) != data {
+		t.Fatalf("Decode(EncodeReader) = %q, %v, want %q", out, err, data)
+	}
+	if _, err := EncodeReader(strings.NewReader(data), 99, M); err == nil {
+		t.Errorf("EncodeReader read past its limit")
+	}
+	if _, err := EncodeReader(strings.NewReader(strings.Repeat("x", 3000)), 1<<20, L); err == nil {
+		t.Errorf("EncodeReader accepted 3000 bytes")
+	}
+	if _, err := EncodeReader(strings.NewReader(data), -1, M); err == nil {
+		t.Errorf("EncodeReader accepted a negative limit")
+	}
+}
+
+func TestEncodeBytes(t *testing.T) {
+	for _, data := range [][]byte{{}, {0, 1, 2, 0xff}, []byte("0123456789")} {
+		c, err := EncodeBytes(data, H)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := coding.Decode(&coding.Code{Bitmap: c.Bitmap, Size: c.Size, Stride: c.Stride})
+		if err != nil || string(out) != string(data) {
+			t.Errorf("Decode(EncodeBytes(%x)) = %x, %v", data, out, err)
+		}
+	}
+}
+
+func TestEncodeHeader(t *testing.T) {
+	data := []byte{0xa1, 0x01, 0x43, 0x00, 0xff, 0x10}
+	c, err := EncodeHeader("ID:12345", data, M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := coding.Decode(&coding.Code{Bitmap: c.Bitmap, Size: c.Size, Stride: c.Stride})
+	if want := "ID:12345" + string(data); err != nil || string(out) != want {
+		t.Errorf("Decode(EncodeHeader) = %q, %v, want %q", out, err, want)
+	}
+}
+
+func TestEncodeNumber(t *testing.T) {
+	decode := func(c *Code) string {
+		out, err := coding.Decode(&coding.Code{Bitmap: c.Bitmap, Size: c.Size, Stride: c.Stride})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(out)
+	}
+
+	c, err := EncodeNumber(18446744073709551615, Q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := decode(c); out != "18446744073709551615" {
+		t.Errorf("EncodeNumber decoded as %q", out)
+	}
+
+	n := new(big.Int).Exp(big.NewInt(10), big.NewInt(100), nil)
+	c, err = EncodeBigNumber(n, M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := decode(c); out != n.String() {
+		t.Errorf("EncodeBigNumber decoded as %q", out)
+	}
+	if _, err := EncodeBigNumber(big.NewInt(-1), M); err == nil {
+		t.Errorf("EncodeBigNumber accepted -1")
+	}
+	n.Exp(big.NewInt(10), big.NewInt(7089), nil)
+	if _, err := EncodeBigNumber(n, L); err == nil {
+		t.Errorf("EncodeBigNumber accepted 7090 digits")
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package qrtest provides utilities for testing code that makes QR codes.
+//
+// It defines a text form for codes, one line per row with # for
+// a dark module and . for a light one, and helpers to compare codes
+// against golden files in that form, so that tests can pin the
+// exact output of an encoder across library upgrades.
+package qrtest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"code.google.com/p/rsc/qr"
+)
+
+// Text returns the text form of c.
+func Text(c *qr.Code) string {
+	var b bytes.Buffer
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.Black(x, y) {
+				b.WriteByte('#')
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// ParseText parses the text form of a code.
+// The result has a Scale of 8, like the codes made by qr.Encode.
+func ParseText(s string) (*qr.Code, error) {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	n := len(lines)
+	c := &qr.Code{Size: n, Stride: (n + 7) / 8, Scale: 8}
+	c.Bitmap = make([]byte, c.Stride*n)
+	for y, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		if len(line) != n {
+			return nil, fmt.Errorf("qrtest: line %d has %d modules, want %d", y+1, len(line), n)
+		}
+		for x := 0; x < n; x++ {
+			switch line[x] {
+			case '#':
+				c.Bitmap[y*c.Stride+x/8] |= 1 << uint(7-x&7)
+			case '.':
+			default:
+				return nil, fmt.Errorf("qrtest: line %d: invalid module %q", y+1, line[x])
+			}
+		}
+	}
+	return c, nil
+}
+
+// Diff returns a description of the differences between want and got,
+// or the empty string if they are the same.  The description shows
+// got in text form with each wrong module marked: X for a module
+// that should be dark and o for one that should be light.
+func Diff(want, got *qr.Code) string {
+	d, err := qr.Diff(want, got)
+	if err != nil {
+		return fmt.Sprintf("size is %d, want %d\n", got.Size, want.Size)
+	}
+	if len(d.Changed) == 0 {
+		return ""
+	}
+	rows := strings.Split(Text(got), "\n")
+	for _, p := range d.Changed {
+		row := []byte(rows[p.Y])
+		if want.Black(p.X, p.Y) {
+			row[p.X] = 'X'
+		} else {
+			row[p.X] = 'o'
+		}
+		rows[p.Y] = string(row)
+	}
+	return fmt.Sprintf("%d modules differ (X should be dark, o should be light):\n%s", len(d.Changed), strings.Join(rows, "\n"))
+}
+
+// CompareGolden compares c against the code in the golden file.
+// If update is true, it writes c to the file instead, creating
+// any missing directories.  Tests typically set update from a
+// command-line flag.
+func CompareGolden(file string, c *qr.Code, update bool) error {
+	if update {
+		if err := os.MkdirAll(filepath.Dir(file), 0777); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(file, []byte(Text(c)), 0666)
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	want, err := ParseText(string(data))
+	if err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+	if d := Diff(want, c); d != "" {
+		return fmt.Errorf("%s: %s", file, d)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qrtest
+
+import (
+	"errors"
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"code.google.com/p/rsc/qr"
+)
+
+func TestText(t *testing.T) {
+	c, err := qr.Encode("hello, world", qr.L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := Text(c)
+	if !strings.HasPrefix(s, "#######.") {
+		t.Errorf("Text does not start with a position box:\n%s", s)
+	}
+	c1, err := ParseText(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := Diff(c, c1); d != "" {
+		t.Errorf("ParseText(Text(c)) differs from c:\n%s", d)
+	}
+	if _, err := ParseText("#.\n#\n"); err == nil {
+		t.Errorf("ParseText accepted ragged input")
+	}
+	if _, err := ParseText("#.\n#x\n"); err == nil {
+		t.Errorf("ParseText accepted invalid module")
+	}
+}
+
+func TestGolden(t *testing.T) {
+	dir, err := ioutil.TempDir("", "qrtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "sub", "hello.txt")
+
+	c, err := qr.Encode("hello, world", qr.M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CompareGolden(file, c, false); err == nil {
+		t.Errorf("CompareGolden with missing file succeeded")
+	}
+	if err := CompareGolden(file, c, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := CompareGolden(file, c, false); err != nil {
+		t.Errorf("CompareGolden after update: %v", err)
+	}
+
+	c.Bitmap[c.Stride*10+1] ^= 0x80
+	err = CompareGolden(file, c, false)
+	if err == nil || !strings.Contains(err.Error(), "1 modules differ") {
+		t.Errorf("CompareGolden with flipped module: %v", err)
+	}
+}
+
+// sampler is a ReferenceDecoder that reads module centers
+// and returns the text form, or a fixed error.
+type sampler struct {
+	size int
+	err  error
+}
+
+func (s sampler) Name() string { return "sampler" }
+
+func (s sampler) Decode(m image.Image) ([]byte, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	scale := m.Bounds().Dx() / (s.size + 8)
+	c := &qr.Code{Size: s.size, Stride: (s.size + 7) / 8}
+	c.Bitmap = make([]byte, c.Stride*c.Size)
+	for y := 0; y < s.size; y++ {
+		for x := 0; x < s.size; x++ {
+			r, _, _, _ := m.At((x+4)*scale+scale/2, (y+4)*scale+scale/2).RGBA()
+			if r < 0x8000 {
+				c.Bitmap[y*c.Stride+x/8] |= 1 << uint(7-x&7)
+			}
+		}
+	}
+	return []byte(Text(c)), nil
+}
+
+func TestCrossCheck(t *testing.T) {
+	c, err := qr.Encode("hello, world", qr.M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte(Text(c))
+	if err := CrossCheck(c, want, sampler{size: c.Size}); err != nil {
+		t.Error(err)
+	}
+	err = CrossCheck(c, want, sampler{size: c.Size}, sampler{err: errors.New("no code found")})
+	if err == nil || !strings.Contains(err.Error(), "no code found") {
+		t.Errorf("CrossCheck with failing decoder: %v", err)
+	}
+	if err := CrossCheck(c, []byte("other"), sampler{size: c.Size}); err == nil {
+		t.Errorf("CrossCheck with wrong text succeeded")
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qrtest
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"code.google.com/p/rsc/qr"
+)
+
+// A ReferenceDecoder is an independent QR decoder,
+// used to check that codes made by this package can be read.
+//
+// Adapters for zbarimg and ZXing are available when building
+// with the zbar and zxing tags; see NewZbar and NewZXing.
+type ReferenceDecoder interface {
+	Name() string
+	Decode(m image.Image) ([]byte, error)
+}
+
+// CrossCheck renders c as a PNG image and checks that each
+// decoder reads it as want.  It reports every failure, not just
+// the first.
+func CrossCheck(c *qr.Code, want []byte, decs ...ReferenceDecoder) error {
+	m, err := png.Decode(bytes.NewReader(c.PNG()))
+	if err != nil {
+		return err
+	}
+	var errs []string
+	for _, d := range decs {
+		got, err := d.Decode(m)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Sprintf("%s: %v", d.Name(), err))
+		case !bytes.Equal(got, want):
+			errs = append(errs, fmt.Sprintf("%s: decoded %q, want %q", d.Name(), got, want))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("qrtest: cross-check failed:\n\t%s", strings.Join(errs, "\n\t"))
+	}
+	return nil
+}
+
+// writeTemp writes m to a temporary PNG file for an external decoder.
+// The caller must call cleanup when done with the file.
+func writeTemp(m image.Image) (file string, cleanup func(), err error) {
+	f, err := ioutil.TempFile("", "qrtest")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() { os.Remove(f.Name()) }
+	if err := png.Encode(f, m); err != nil {
+		f.Close()
+		cleanup()
+		return "", nil, err
+	}
+	if err := f.Close(); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return f.Name(), cleanup, nil
+}
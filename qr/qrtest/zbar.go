@@ -0,0 +1,41 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build zbar
+// +build zbar
+
+package qrtest
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"os/exec"
+)
+
+// NewZbar returns a ReferenceDecoder that runs the zbarimg
+// command from the ZBar project, which must be in the PATH.
+func NewZbar() ReferenceDecoder {
+	return zbar{}
+}
+
+type zbar struct{}
+
+func (zbar) Name() string { return "zbarimg" }
+
+func (zbar) Decode(m image.Image) ([]byte, error) {
+	file, cleanup, err := writeTemp(m)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	var stderr bytes.Buffer
+	cmd := exec.Command("zbarimg", "--raw", "-q", "-Sbinary", file)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%v: %s", err, stderr.Bytes())
+	}
+	return out, nil
+}
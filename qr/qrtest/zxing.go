@@ -0,0 +1,57 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build zxing
+// +build zxing
+
+package qrtest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"os/exec"
+)
+
+// NewZXing returns a ReferenceDecoder that runs the ZXing
+// command-line runner with java.  The class path holding the
+// ZXing core and javase jars is taken from $ZXING_CLASSPATH.
+func NewZXing() ReferenceDecoder {
+	return zxing{os.Getenv("ZXING_CLASSPATH")}
+}
+
+type zxing struct {
+	classpath string
+}
+
+func (zxing) Name() string { return "zxing" }
+
+func (z zxing) Decode(m image.Image) ([]byte, error) {
+	if z.classpath == "" {
+		return nil, errors.New("$ZXING_CLASSPATH not set")
+	}
+	file, cleanup, err := writeTemp(m)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	var stderr bytes.Buffer
+	cmd := exec.Command("java", "-cp", z.classpath, "com.google.zxing.client.j2se.CommandLineRunner",
+		"--pure_barcode", file)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%v: %s", err, stderr.Bytes())
+	}
+	// The output includes "Raw result:\n<text>\nParsed result:".
+	const raw, parsed = "Raw result:\n", "\nParsed result:"
+	i := bytes.Index(out, []byte(raw))
+	j := bytes.Index(out, []byte(parsed))
+	if i < 0 || j < i+len(raw) {
+		return nil, fmt.Errorf("no result in output: %s", out)
+	}
+	return out[i+len(raw) : j], nil
+}
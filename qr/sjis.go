@@ -0,0 +1,91 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"sync"
+	"unicode/utf8"
+)
+
+var (
+	sjisOnce sync.Once
+	sjisCode map[rune]uint16 // inverse of sjisTable
+)
+
+// sjisLead returns the lead byte for entry i of sjisTable.
+func sjisLead(i int) byte {
+	if i < 0x1f {
+		return byte(0x81 + i)
+	}
+	return byte(0xe0 + i - 0x1f)
+}
+
+func loadSJIS() {
+	sjisCode = make(map[rune]uint16)
+	for i, row := range sjisTable {
+		t := 0x40
+		for _, r := range row {
+			if r != utf8.RuneError {
+				sjisCode[r] = uint16(sjisLead(i))<<8 | uint16(t)
+			}
+			if t++; t == 0x7f {
+				t++
+			}
+		}
+	}
+}
+
+// ToShiftJIS converts text from UTF-8 to Shift JIS, for use as the
+// text of a Kanji segment.  If a character has no double-byte
+// Shift JIS code, ToShiftJIS returns an InvalidCharError for it.
+func ToShiftJIS(text string) (string, error) {
+	sjisOnce.Do(loadSJIS)
+	b := make([]byte, 0, len(text))
+	for i, r := range text {
+		c, ok := sjisCode[r]
+		if !ok {
+			return "", &InvalidCharError{i, r, Kanji}
+		}
+		b = append(b, byte(c>>8), byte(c))
+	}
+	return string(b), nil
+}
+
+// KanjiSegments divides text into Kanji segments, converted to
+// Shift JIS, for the characters that have double-byte Shift JIS
+// codes, and segments in the smallest other mode for the rest.
+// It also returns the non-ASCII characters that had no Shift JIS
+// code and so fell back to byte mode, each listed once.
+func KanjiSegments(text string) (segs []Segment, fallback []rune) {
+	sjisOnce.Do(loadSJIS)
+	seen := make(map[rune]bool)
+	start, kanji := 0, false
+	flush := func(end int) {
+		if start == end {
+			return
+		}
+		s := text[start:end]
+		if kanji {
+			s, _ = ToShiftJIS(s)
+			segs = append(segs, Segment{Kanji, s})
+		} else {
+			segs = append(segs, Segment{DetectMode(s), s})
+		}
+		start = end
+	}
+	for i, r := range text {
+		_, k := sjisCode[r]
+		if k != kanji {
+			flush(i)
+			kanji = k
+		}
+		if !k && r >= utf8.RuneSelf && !seen[r] {
+			seen[r] = true
+			fallback = append(fallback, r)
+		}
+	}
+	flush(len(text))
+	return segs, fallback
+}
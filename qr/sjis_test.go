@@ -0,0 +1,47 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"testing"
+
+	"code.google.com/p/rsc/qr/coding"
+)
+
+func TestToShiftJIS(t *testing.T) {
+	s, err := ToShiftJIS("点茗　ア")
+	if want := "\x93\x5f\xe4\xaa\x81\x40\x83\x41"; err != nil || s != want {
+		t.Errorf("ToShiftJIS = %q, %v, want %q", s, err, want)
+	}
+	_, err = ToShiftJIS("点a")
+	if e, ok := err.(*InvalidCharError); !ok || *e != (InvalidCharError{3, 'a', Kanji}) {
+		t.Errorf("ToShiftJIS(%q) error = %v", "点a", err)
+	}
+}
+
+func TestKanjiSegments(t *testing.T) {
+	segs, fallback := KanjiSegments("QR点茗 café é")
+	want := []Segment{{Alphanumeric, "QR"}, {Kanji, "\x93\x5f\xe4\xaa"}, {Byte, " café é"}}
+	if len(segs) != len(want) {
+		t.Fatalf("KanjiSegments = %q, want %q", segs, want)
+	}
+	for i := range segs {
+		if segs[i] != want[i] {
+			t.Errorf("KanjiSegments[%d] = %q, want %q", i, segs[i], want[i])
+		}
+	}
+	if len(fallback) != 1 || fallback[0] != 'é' {
+		t.Errorf("KanjiSegments fallback = %q, want %q", fallback, "é")
+	}
+
+	c, err := EncodeSegments(segs, M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := coding.Decode(&coding.Code{Bitmap: c.Bitmap, Size: c.Size, Stride: c.Stride})
+	if want := "QR\x93\x5f\xe4\xaa café é"; err != nil || string(out) != want {
+		t.Errorf("Decode(EncodeSegments(KanjiSegments)) = %q, %v, want %q", out, err, want)
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import "code.google.com/p/rsc/qr/coding"
+
+// A SegmentationStrategy says how Split divides text into segments.
+type SegmentationStrategy int
+
+const (
+	// Greedy merges runs of characters from left to right,
+	// keeping a run in its own segment only when that saves bits.
+	// It takes time linear in the length of the text and is usually
+	// within a few bits of optimal.
+	Greedy SegmentationStrategy = iota
+
+	// Optimal finds the segmentation using the fewest bits,
+	// by dynamic programming over the modes and the characters
+	// left over from the last full numeric or alphanumeric group.
+	// It takes about four times as long as Greedy; see
+	// BenchmarkSplitOptimal.
+	Optimal
+)
+
+// Split divides text into Numeric, Alphanumeric, and Byte segments
+// for a code of the given version, using strategy s.
+// The version matters because it sets the size of each segment's
+// character count.
+func Split(text string, version int, s SegmentationStrategy) []Segment {
+	v := coding.Version(version)
+	if v < coding.MinVersion {
+		v = coding.MinVersion
+	}
+	if v > coding.MaxVersion {
+		v = coding.MaxVersion
+	}
+	if s == Optimal {
+		return splitOptimal(text, v)
+	}
+	return splitGreedy(text, v)
+}
+
+// EncodeSplit is like Encode but uses Split to divide the text
+// into segments of different modes, which can make a smaller code
+// for text that mixes digits, capitals, and other characters.
+func EncodeSplit(text string, level Level, s SegmentationStrategy) (*Code, error) {
+	// The best split depends on the version, but only through
+	// the character count sizes, which change at versions 10 and 27.
+	classes := []int{1, 10, 27}
+	var err error
+	for i, c := range classes {
+		var p *Pin
+		p, err = fit(level, Split(text, c, s))
+		if err == nil && (i+1 == len(classes) || p.Version < classes[i+1]) {
+			return p.Replay()
+		}
+	}
+	return nil, err
+}
+
+// modeOrder lists the modes Split uses, from most to least restrictive.
+var modeOrder = []Mode{Numeric, Alphanumeric, Byte}
+
+// rank returns the index of m in modeOrder.
+func rank(m Mode) int {
+	for i, m1 := range modeOrder {
+		if m1 == m {
+			return i
+		}
+	}
+	return len(modeOrder) - 1
+}
+
+// bits returns the number of bits needed to encode s in version v.
+// It handles only the modes in modeOrder and does not check the text,
+// so that the splitters can call it often.
+func (s Segment) bits(v coding.Version) int {
+	switch s.Mode {
+	case Numeric:
+		return coding.Num(s.Text).Bits(v)
+	case Alphanumeric:
+		return coding.Alpha(s.Text).Bits(v)
+	}
+	return coding.String(s.Text).Bits(v)
+}
+
+func splitGreedy(text string, v coding.Version) []Segment {
+	// Segments are consecutive slices of text; last is where the
+	// final one starts.  Each run of characters with the same mode
+	// is merged into the final segment or starts a new one.
+	var segs []Segment
+	last := 0
+	add := func(start, end int, mode Mode) {
+		run := Segment{mode, text[start:end]}
+		if n := len(segs); n > 0 {
+			prev := segs[n-1]
+			if rank(mode) < rank(prev.Mode) {
+				mode = prev.Mode
+			}
+			merged := Segment{mode, text[last:end]}
+			if merged.bits(v) <= prev.bits(v)+run.bits(v) {
+				segs[n-1] = merged
+				return
+			}
+		}
+		segs = append(segs, run)
+		last = start
+	}
+
+	start, mode := 0, Mode("")
+	for i, r := range text {
+		m := RuneMode(r)
+		if i > start && m != mode {
+			add(start, i, mode)
+			start = i
+		}
+		mode = m
+	}
+	if start < len(text) {
+		add(start, len(text), mode)
+	}
+	return segs
+}
+
+func splitOptimal(text string, v coding.Version) []Segment {
+	if text == "" {
+		return nil
+	}
+
+	// Numeric mode packs three characters into 10 bits, with 4 or 7
+	// bits for a final group of one or two, and alphanumeric packs two
+	// into 11 bits, with 6 for a final one.  So the cost of the next
+	// character depends on how many characters the current segment
+	// has past its last full group.  The states of the search are
+	// the mode and that number: step[s] is the bits that a character
+	// in state s adds, and next[s] is the state after it.
+	type state struct {
+		mode int // index in modeOrder
+		step int
+		next int
+	}
+	states := []state{
+		{0, 4, 1}, {0, 3, 2}, {0, 3, 0}, // numeric, 0, 1, or 2 characters past a group
+		{1, 6, 4}, {1, 5, 3}, // alphanumeric, 0 or 1
+		{2, 8, 5}, // byte
+	}
+	const nstate = 6
+	var head [3]int
+	for m, mode := range modeOrder {
+		head[m] = Segment{mode, ""}.bits(v)
+	}
+	first := [3]int{0, 3, 5} // state at the start of a segment in each mode
+
+	// cost[i][s] is the fewest bits needed to encode text[:i] ending
+	// in state s, and from[i][s] the state before text[i-1] in that
+	// encoding, or -1 if text[i-1] starts the text.  Bytes of a
+	// multibyte UTF-8 sequence are always Byte mode, so no sequence
+	// is split.
+	const inf = 1 << 30
+	cost := make([][nstate]int, len(text)+1)
+	from := make([][nstate]int, len(text)+1)
+	for s := range cost[0] {
+		cost[0][s] = inf
+	}
+	for i := 0; i < len(text); i++ {
+		need := rank(RuneMode(rune(text[i])))
+		for s := range cost[i+1] {
+			cost[i+1][s] = inf
+		}
+		relax := func(prev, s, c int) {
+			if st := states[s].next; c < cost[i+1][st] {
+				cost[i+1][st], from[i+1][st] = c, prev
+			}
+		}
+		for m := need; m < len(modeOrder); m++ {
+			// Start a new segment.
+			s := first[m]
+			if i == 0 {
+				relax(-1, s, head[m]+states[s].step)
+			}
+			for p := range states {
+				if cost[i][p] < inf && states[p].mode != m {
+					relax(p, s, cost[i][p]+head[m]+states[s].step)
+				}
+			}
+		}
+		// Continue the current segment.
+		for p, st := range states {
+			if cost[i][p] < inf && st.mode >= need {
+				relax(p, p, cost[i][p]+st.step)
+			}
+		}
+	}
+
+	// Walk back from the cheapest final state.
+	s := 0
+	for s1 := range states {
+		if cost[len(text)][s1] < cost[len(text)][s] {
+			s = s1
+		}
+	}
+	var segs []Segment
+	end := len(text)
+	for i := len(text); i > 0; i-- {
+		p := from[i][s]
+		if p < 0 || states[p].mode != states[s].mode {
+			segs = append(segs, Segment{modeOrder[states[s].mode], text[i-1 : end]})
+			end = i - 1
+		}
+		s = p
+	}
+	for i, j := 0, len(segs)-1; i < j; i, j = i+1, j-1 {
+		segs[i], segs[j] = segs[j], segs[i]
+	}
+	return segs
+}
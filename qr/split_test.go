@@ -0,0 +1,126 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"code.google.com/p/rsc/qr/coding"
+)
+
+var splitTests = []string{
+	"",
+	"0123456789",
+	"HELLO WORLD",
+	"hello, world",
+	"ID:0012345678901234567890 (batch A7)",
+	"https://example.com/p?id=31415926535897932384626",
+	"ABC123abc123ABCDEFGHIJ0123456789xyz",
+	"café 12345678901234 CAFÉ",
+}
+
+func segBits(segs []Segment, v int) int {
+	n := 0
+	for _, s := range segs {
+		n += s.bits(coding.Version(v))
+	}
+	return n
+}
+
+func TestSplit(t *testing.T) {
+	for _, text := range splitTests {
+		for _, v := range []int{1, 10, 27} {
+			single := segBits([]Segment{{DetectMode(text), text}}, v)
+			greedy := Split(text, v, Greedy)
+			optimal := Split(text, v, Optimal)
+			for _, segs := range [][]Segment{greedy, optimal} {
+				if got := (&Pin{Segments: segs}).Text(); got != text {
+					t.Errorf("Split(%q, %d) = %q, lost text", text, v, segs)
+				}
+			}
+			g, o := segBits(greedy, v), segBits(optimal, v)
+			if g > single || o > g {
+				t.Errorf("Split(%q, %d): single %d bits, greedy %d, optimal %d", text, v, single, g, o)
+			}
+		}
+
+		for _, s := range []SegmentationStrategy{Greedy, Optimal} {
+			c, err := EncodeSplit(text, M, s)
+			if err != nil {
+				t.Errorf("EncodeSplit(%q, %d): %v", text, s, err)
+				continue
+			}
+			out, err := coding.Decode(&coding.Code{Bitmap: c.Bitmap, Size: c.Size, Stride: c.Stride})
+			if err != nil || string(out) != text {
+				t.Errorf("Decode(EncodeSplit(%q, %d)) = %q, %v", text, s, out, err)
+			}
+		}
+	}
+}
+
+// bruteSplit returns the fewest bits needed for text in version v,
+// trying every division into segments and every mode for each.
+func bruteSplit(text string, v int) int {
+	// best[i] is the fewest bits needed for text[i:].
+	best := make([]int, len(text)+1)
+	for i := len(text) - 1; i >= 0; i-- {
+		best[i] = -1
+		need := 0
+		for j := i + 1; j <= len(text); j++ {
+			if r := rank(RuneMode(rune(text[j-1]))); r > need {
+				need = r
+			}
+			for m := need; m < len(modeOrder); m++ {
+				n := Segment{modeOrder[m], text[i:j]}.bits(coding.Version(v)) + best[j]
+				if best[i] < 0 || n < best[i] {
+					best[i] = n
+				}
+			}
+		}
+	}
+	return best[0]
+}
+
+func TestSplitOptimalBrute(t *testing.T) {
+	// Strings on which costing characters in fractions of a bit,
+	// ignoring the rounding of partial groups, loses a bit.
+	texts := []string{
+		"236 83b9AAB8434869164985C",
+		"b69743489:3069236810B14a:: A2a7a 4",
+	}
+	r := rand.New(rand.NewSource(1))
+	const chars = "0123456789ABC: abc"
+	for len(texts) < 1000 {
+		b := make([]byte, 1+r.Intn(40))
+		for i := range b {
+			b[i] = chars[r.Intn(len(chars))]
+		}
+		texts = append(texts, string(b))
+	}
+	for _, text := range texts {
+		for _, v := range []int{1, 10, 27} {
+			segs := Split(text, v, Optimal)
+			if got, want := segBits(segs, v), bruteSplit(text, v); got != want {
+				t.Fatalf("Split(%q, %d, Optimal) = %q, %d bits, want %d", text, v, segs, got, want)
+			}
+		}
+	}
+}
+
+var benchText = strings.Repeat("https://example.com/item/0012345678901234?ref=A7B9 ", 20)
+
+func BenchmarkSplitGreedy(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Split(benchText, 27, Greedy)
+	}
+}
+
+func BenchmarkSplitOptimal(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Split(benchText, 27, Optimal)
+	}
+}
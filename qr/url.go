@@ -0,0 +1,40 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"net/url"
+	"strings"
+)
+
+// FoldURL returns the URL text with its scheme and host upper cased,
+// and true, if that lets the whole URL use alphanumeric mode.
+// Scanners and browsers treat the scheme and host case-insensitively,
+// so the folded URL leads to the same place; the rest of the URL
+// is left alone, since paths and queries may be case-sensitive.
+// If folding would not help, FoldURL returns text and false.
+func FoldURL(text string) (string, bool) {
+	u, err := url.Parse(text)
+	if err != nil || u.Host == "" || u.User != nil || DetectMode(text) != Byte {
+		return text, false
+	}
+	n := len(u.Scheme) + len("://") + len(u.Host)
+	if !strings.HasPrefix(strings.ToLower(text), strings.ToLower(u.Scheme)+"://") || len(text) < n {
+		return text, false
+	}
+	up := strings.ToUpper(text[:n]) + text[n:]
+	if DetectMode(up) != Alphanumeric {
+		return text, false
+	}
+	return up, true
+}
+
+// EncodeURL is like Encode but first applies FoldURL to text.
+// The result folded reports whether it did.
+func EncodeURL(text string, level Level) (c *Code, folded bool, err error) {
+	text, folded = FoldURL(text)
+	c, err = Encode(text, level)
+	return c, folded, err
+}
@@ -0,0 +1,40 @@
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import "testing"
+
+var foldTests = []struct {
+	in, out string
+	ok      bool
+}{
+	{"https://example.com/A1", "HTTPS://EXAMPLE.COM/A1", true},
+	{"http://Example.COM:8080/", "HTTP://EXAMPLE.COM:8080/", true},
+	{"https://example.com/a1", "https://example.com/a1", false}, // path is case-sensitive
+	{"HTTPS://EXAMPLE.COM/", "HTTPS://EXAMPLE.COM/", false},     // already alphanumeric
+	{"https://user@example.com/", "https://user@example.com/", false},
+	{"hello, world", "hello, world", false},
+}
+
+func TestFoldURL(t *testing.T) {
+	for _, tt := range foldTests {
+		out, ok := FoldURL(tt.in)
+		if out != tt.out || ok != tt.ok {
+			t.Errorf("FoldURL(%q) = %q, %v, want %q, %v", tt.in, out, ok, tt.out, tt.ok)
+		}
+	}
+
+	c, folded, err := EncodeURL("https://example.com/X", L)
+	if err != nil || !folded {
+		t.Fatalf("EncodeURL = %v, %v", folded, err)
+	}
+	c1, err := Encode("HTTPS://EXAMPLE.COM/X", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(c.Bitmap) != string(c1.Bitmap) {
+		t.Errorf("EncodeURL did not encode the folded URL")
+	}
+}
@@ -13,6 +13,7 @@ import (
 
 	"code.google.com/p/freetype-go/freetype"
 	"code.google.com/p/rsc/appfs/fs"
+	"code.google.com/p/rsc/barcode"
 	"code.google.com/p/rsc/qr"
 	"code.google.com/p/rsc/qr/coding"
 )
@@ -498,8 +499,12 @@ func Encode(w http.ResponseWriter, req *http.Request) {
 		panic(err)
 	}
 
-	c := &qr.Code{Bitmap: cc.Bitmap, Size: cc.Size, Stride: cc.Stride, Scale: 8}
+	c := &qr.Code{Bitmap: cc.Bitmap, Size: cc.Size, Stride: cc.Stride}
+	data, err := barcode.PNG(c, 8)
+	if err != nil {
+		panic(err)
+	}
 	w.Header().Set("Content-Type", "image/png")
 	w.Header().Set("Cache-Control", "public, max-age=3600")
-	w.Write(c.PNG())
+	w.Write(data)
 }